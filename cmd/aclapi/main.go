@@ -14,6 +14,7 @@ import (
 
 	"github.com/PythonHacker24/linux-acl-management-aclapi/config"
 	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/grpcserver"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/metrics"
 	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/utils"
 )
 
@@ -30,7 +31,10 @@ func exec() error {
 	/* setting up cobra for cli interactions */
 	var (
 		configPath string
-		rootCmd    = &cobra.Command{
+
+		/* only the root command starts the daemon, subcommands exit on their own */
+		serve   bool
+		rootCmd = &cobra.Command{
 			Use:   "aclapi <command> <subcommand>",
 			Short: "API Daemon for linux acl management",
 			Example: heredoc.Doc(`
@@ -42,6 +46,7 @@ func exec() error {
 				} else {
 					fmt.Printf("No config file provided.\n\n")
 				}
+				serve = true
 			},
 		}
 	)
@@ -49,12 +54,20 @@ func exec() error {
 	/* adding --config argument */
 	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Path to config file")
 
+	/* adding audit log commands */
+	rootCmd.AddCommand(newAuditCmd(&configPath))
+
 	/* Execute the command */
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Printf("arguments error: %s", err.Error())
 		os.Exit(1)
 	}
 
+	/* help and completion commands end here */
+	if !serve {
+		return nil
+	}
+
 	/*
 		load config file
 		if there is an error in loading the config file, then it will exit with code 1
@@ -99,6 +112,7 @@ func run(ctx context.Context) error {
 		zap.L().Error("Failed to initialize gRPC server",
 			zap.Error(err),
 		)
+		return err
 	}
 
 	/* creating the gRPC listener */
@@ -123,10 +137,42 @@ func run(ctx context.Context) error {
 		}
 	}()
 
+	/* optional Prometheus endpoint, local only by default */
+	var metricsServer *metrics.Server
+	if config.APIDConfig.Metrics.Enabled {
+		metricsServer, err = metrics.Listen(config.APIDConfig.Metrics.Listen)
+		if err != nil {
+			zap.L().Error("Failed to start metrics endpoint",
+				zap.Error(err),
+			)
+			return err
+		}
+		go metricsServer.Serve()
+	}
+
+	/* health checks start reporting once the server is listening */
+	grpcServer.Health.Start()
+
+	if grpcServer.Watcher != nil {
+		grpcServer.Watcher.Start()
+	}
+
+	if grpcServer.Drift != nil {
+		grpcServer.Drift.Start()
+	}
+
 	<-ctx.Done()
 
 	zap.L().Info("Shutting down gRPC server")
 
+	/* stop attracting new requests before draining in-flight ones */
+	grpcServer.Health.Drain()
+
+	/* watch streams never end on their own, graceful stop would wait for them */
+	if grpcServer.Events != nil {
+		grpcServer.Events.Close()
+	}
+
 	/* attempting to gracefully shutdown gRPC server */
 	gracefulStop := make(chan struct{})
 	go func() {
@@ -143,5 +189,28 @@ func run(ctx context.Context) error {
 		grpcServer.GRPC.Stop()
 	}
 
+	/* the scanner and the watcher use the backend, stop them first */
+	if grpcServer.Drift != nil {
+		grpcServer.Drift.Close()
+	}
+
+	if grpcServer.Watcher != nil {
+		grpcServer.Watcher.Close()
+	}
+
+	/* no more requests can arrive, release the aclcore and directory connections */
+	grpcServer.Backend.Close()
+	grpcServer.Directory.Close()
+
+	if grpcServer.Audit != nil {
+		grpcServer.Audit.Close()
+	}
+
+	if metricsServer != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		metricsServer.Shutdown(shutdownCtx)
+	}
+
 	return nil
 }
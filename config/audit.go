@@ -0,0 +1,39 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+)
+
+/* tamper-evident audit log of ACL changes */
+type Audit struct {
+	Enabled        bool   `yaml:"enabled,omitempty"`
+	File           string `yaml:"file,omitempty"`
+	KeyFile        string `yaml:"key_file,omitempty"`
+	CredentialName string `yaml:"credential_name,omitempty"`
+}
+
+/* normalization function */
+func (a *Audit) Normalize() error {
+
+	/* kept apart from the rotated daemon logs by default */
+	if a.File == "" {
+		a.File = "/var/lib/aclapi/audit.log"
+	}
+
+	if !filepath.IsAbs(a.File) {
+		return errors.New("audit file must be an absolute path")
+	}
+
+	/* systemd credential holding the key of the hash chain */
+	if a.CredentialName == "" {
+		a.CredentialName = "aclapi-audit-key"
+	}
+
+	/* key file used when not started with systemd credentials */
+	if a.KeyFile == "" {
+		a.KeyFile = "/etc/laclm/aclapi-audit.key"
+	}
+
+	return nil
+}
@@ -8,9 +8,17 @@ var (
 
 /* config struct for aclapi */
 type ADConfig struct {
-	DConfig	DConfig	`yaml:"daemon,omitempty"`
-	Logging	Logging	`yaml:"logs,omitempty"`
-	Server	Server	`yaml:"server,omitempty"`
+	DConfig   DConfig   `yaml:"daemon,omitempty"`
+	Logging   Logging   `yaml:"logs,omitempty"`
+	Server    Server    `yaml:"server,omitempty"`
+	Directory Directory `yaml:"directory,omitempty"`
+	Metrics   Metrics   `yaml:"metrics,omitempty"`
+	Audit     Audit     `yaml:"audit,omitempty"`
+	Events    Events    `yaml:"events,omitempty"`
+	Watcher   Watcher   `yaml:"watcher,omitempty"`
+	Drift     Drift     `yaml:"drift,omitempty"`
+	RateLimit RateLimit `yaml:"rate_limit,omitempty"`
+	Locks     Locks     `yaml:"locks,omitempty"`
 }
 
 /* complete config normalizer function */
@@ -28,5 +36,42 @@ func (c *ADConfig) Normalize() error {
 		return fmt.Errorf("server configuration error: %w", err)
 	}
 
+	if err := c.Directory.Normalize(); err != nil {
+		return fmt.Errorf("directory configuration error: %w", err)
+	}
+
+	if err := c.Metrics.Normalize(); err != nil {
+		return fmt.Errorf("metrics configuration error: %w", err)
+	}
+
+	if err := c.Audit.Normalize(); err != nil {
+		return fmt.Errorf("audit configuration error: %w", err)
+	}
+
+	if err := c.Events.Normalize(); err != nil {
+		return fmt.Errorf("events configuration error: %w", err)
+	}
+
+	if err := c.Watcher.Normalize(); err != nil {
+		return fmt.Errorf("watcher configuration error: %w", err)
+	}
+
+	if err := c.Drift.Normalize(); err != nil {
+		return fmt.Errorf("drift configuration error: %w", err)
+	}
+
+	if err := c.RateLimit.Normalize(); err != nil {
+		return fmt.Errorf("rate_limit configuration error: %w", err)
+	}
+
+	if err := c.Locks.Normalize(); err != nil {
+		return fmt.Errorf("locks configuration error: %w", err)
+	}
+
+	/* the expected ACLs are the ones recorded in the audit log */
+	if c.Drift.Enabled && !c.Audit.Enabled {
+		return fmt.Errorf("drift configuration error: drift detection needs the audit log to be enabled")
+	}
+
 	return nil
 }
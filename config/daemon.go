@@ -1,23 +1,261 @@
 package config
 
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclcore"
+)
+
 /* daemon config */
 type DConfig struct {
-	DebugMode	bool		`yaml:"debug_mode,omitempty"`
-	SocketPath  string		`yaml:"socket_path,omitempty"`
+	DebugMode       bool          `yaml:"debug_mode,omitempty"`
+	Backend         string        `yaml:"backend,omitempty"`
+	NoDirectReads   bool          `yaml:"no_direct_reads,omitempty"`
+	SocketPath      string        `yaml:"socket_path,omitempty"`
+	PoolSize        int           `yaml:"pool_size,omitempty"`
+	PoolIdleTimeout time.Duration `yaml:"pool_idle_timeout,omitempty"`
+	Timeouts        CoreTimeouts  `yaml:"timeouts,omitempty"`
+	Retry           CoreRetry     `yaml:"retry,omitempty"`
+	CircuitBreaker  CoreBreaker   `yaml:"circuit_breaker,omitempty"`
+	Peer            CorePeer      `yaml:"peer,omitempty"`
+	Auth            CoreAuth      `yaml:"auth,omitempty"`
+}
+
+/* ACL backends selectable with daemon.backend */
+const (
+	/* delegate every operation to the aclcore root daemon */
+	BackendCore = "aclcore"
+
+	/* edit the ACL xattrs directly with aclapi's own privileges (development) */
+	BackendXattr = "xattr"
+)
+
+/* HMAC signing of messages sent to aclcore */
+type CoreAuth struct {
+	Enabled        bool   `yaml:"enabled,omitempty"`
+	KeyFile        string `yaml:"key_file,omitempty"`
+	CredentialName string `yaml:"credential_name,omitempty"`
+}
+
+/* expected identity of aclcore */
+type CorePeer struct {
+	Disabled        bool    `yaml:"disabled,omitempty"`
+	UID             uint32  `yaml:"uid,omitempty"`
+	GID             int64   `yaml:"gid,omitempty"`
+	CheckSocketFile bool    `yaml:"check_socket_file,omitempty"`
+	SocketOwnerUID  *uint32 `yaml:"socket_owner_uid,omitempty"`
+	SocketMaxMode   string  `yaml:"socket_max_mode,omitempty"`
+}
+
+/* retry policy for aclcore operations that are idempotent or never reached aclcore */
+type CoreRetry struct {
+	MaxAttempts    int           `yaml:"max_attempts,omitempty"`
+	InitialBackoff time.Duration `yaml:"initial_backoff,omitempty"`
+	MaxBackoff     time.Duration `yaml:"max_backoff,omitempty"`
+}
+
+/* circuit breaker guarding aclcore */
+type CoreBreaker struct {
+	FailureThreshold int           `yaml:"failure_threshold,omitempty"`
+	ProbeInterval    time.Duration `yaml:"probe_interval,omitempty"`
+}
+
+/* timeouts of aclcore interactions (client deadlines still apply if shorter) */
+type CoreTimeouts struct {
+	Connect    time.Duration            `yaml:"connect,omitempty"`
+	Default    time.Duration            `yaml:"default,omitempty"`
+	Operations map[string]time.Duration `yaml:"operations,omitempty"`
 }
 
 /* normalization function */
 func (d *DConfig) Normalize() error {
 
-	/* 
+	/*
 		debug_mode is false by default
 		daemon will run on production mode by default
 	*/
 
+	/* apply changes through the aclcore root daemon by default */
+	if d.Backend == "" {
+		d.Backend = BackendCore
+	}
+
+	if d.Backend != BackendCore && d.Backend != BackendXattr {
+		return fmt.Errorf("unknown backend %q (expected %q or %q)", d.Backend, BackendCore, BackendXattr)
+	}
+
 	/* if SocketPath is empty, use "/var/run/laclm-daemon.sock" as default */
 	if d.SocketPath == "" {
 		d.SocketPath = "/var/run/laclm-daemon.sock"
 	}
 
+	/* keep at most 8 connections to aclcore open by default */
+	if d.PoolSize == 0 {
+		d.PoolSize = 8
+	}
+
+	if d.PoolSize < 0 {
+		return errors.New("pool_size must be positive")
+	}
+
+	/* close connections idle for more than a minute by default */
+	if d.PoolIdleTimeout == 0 {
+		d.PoolIdleTimeout = time.Minute
+	}
+
+	if err := d.Timeouts.Normalize(); err != nil {
+		return err
+	}
+
+	if err := d.Retry.Normalize(); err != nil {
+		return err
+	}
+
+	if err := d.CircuitBreaker.Normalize(); err != nil {
+		return err
+	}
+
+	if err := d.Peer.Normalize(); err != nil {
+		return err
+	}
+
+	return d.Auth.Normalize()
+}
+
+/* normalization function */
+func (t *CoreTimeouts) Normalize() error {
+
+	/* connecting and the hello exchange must finish within 5 seconds by default */
+	if t.Connect == 0 {
+		t.Connect = 5 * time.Second
+	}
+
+	/* operations without their own timeout get 30 seconds by default */
+	if t.Default == 0 {
+		t.Default = 30 * time.Second
+	}
+
+	if t.Connect < 0 {
+		return errors.New("connect timeout must be positive")
+	}
+
+	if t.Default < 0 {
+		return errors.New("default timeout must be positive")
+	}
+
+	for op, timeout := range t.Operations {
+		if !aclcore.Op(op).Timed() {
+			return fmt.Errorf("unknown operation %q in timeouts", op)
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("timeout of operation %q must be positive", op)
+		}
+	}
+
+	return nil
+}
+
+/* normalization function */
+func (r *CoreRetry) Normalize() error {
+
+	/* try idempotent operations up to 3 times by default */
+	if r.MaxAttempts == 0 {
+		r.MaxAttempts = 3
+	}
+
+	if r.MaxAttempts < 0 {
+		return errors.New("retry max_attempts must be positive")
+	}
+
+	/* back off 100ms, doubling up to 2 seconds by default */
+	if r.InitialBackoff == 0 {
+		r.InitialBackoff = 100 * time.Millisecond
+	}
+
+	if r.MaxBackoff == 0 {
+		r.MaxBackoff = 2 * time.Second
+	}
+
+	if r.MaxBackoff < r.InitialBackoff {
+		return errors.New("retry max_backoff must not be smaller than initial_backoff")
+	}
+
+	return nil
+}
+
+/* normalization function */
+func (b *CoreBreaker) Normalize() error {
+
+	/* open the circuit after 5 consecutive connection failures by default */
+	if b.FailureThreshold == 0 {
+		b.FailureThreshold = 5
+	}
+
+	if b.FailureThreshold < 0 {
+		return errors.New("circuit_breaker failure_threshold must be positive")
+	}
+
+	/* probe aclcore every 2 seconds while the circuit is open by default */
+	if b.ProbeInterval == 0 {
+		b.ProbeInterval = 2 * time.Second
+	}
+
+	return nil
+}
+
+/* normalization function */
+func (p *CorePeer) Normalize() error {
+
+	/*
+		uid and gid default to 0, aclcore is expected to run as root
+		a negative gid accepts any group
+	*/
+
+	/* the socket file is expected to be owned by the aclcore uid */
+	if p.SocketOwnerUID == nil {
+		uid := p.UID
+		p.SocketOwnerUID = &uid
+	}
+
+	/* aclcore shares its socket with the laclm group only by default */
+	if p.SocketMaxMode == "" {
+		p.SocketMaxMode = "0660"
+	}
+
+	if _, err := strconv.ParseUint(p.SocketMaxMode, 8, 32); err != nil {
+		return fmt.Errorf("invalid socket_max_mode %q (expected octal permissions)", p.SocketMaxMode)
+	}
+
+	return nil
+}
+
+/* maximum permissions of the socket file */
+func (p *CorePeer) MaxMode() os.FileMode {
+	mode, _ := strconv.ParseUint(p.SocketMaxMode, 8, 32)
+	return os.FileMode(mode).Perm()
+}
+
+/* normalization function */
+func (a *CoreAuth) Normalize() error {
+
+	/* signing is disabled by default */
+	if !a.Enabled {
+		return nil
+	}
+
+	/* name of the systemd credential (LoadCredential=) holding the key */
+	if a.CredentialName == "" {
+		a.CredentialName = "aclcore-hmac-key"
+	}
+
+	/* key file used when not started with systemd credentials */
+	if a.KeyFile == "" {
+		a.KeyFile = "/etc/laclm/aclcore-hmac.key"
+	}
+
 	return nil
 }
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+)
+
+/* principal directory config (where users and groups are resolved from) */
+type Directory struct {
+	Backend string         `yaml:"backend,omitempty"`
+	LDAP    LDAP           `yaml:"ldap,omitempty"`
+	Cache   DirectoryCache `yaml:"cache,omitempty"`
+}
+
+/* LDAP directory parameters */
+type LDAP struct {
+	URL                string        `yaml:"url,omitempty"`
+	BindDN             string        `yaml:"bind_dn,omitempty"`
+	BindPassword       string        `yaml:"bind_password,omitempty"`
+	BindPasswordFile   string        `yaml:"bind_password_file,omitempty"`
+	UserBaseDNs        []string      `yaml:"user_base_dns,omitempty"`
+	GroupBaseDNs       []string      `yaml:"group_base_dns,omitempty"`
+	UserFilter         string        `yaml:"user_filter,omitempty"`
+	GroupFilter        string        `yaml:"group_filter,omitempty"`
+	UserIDFilter       string        `yaml:"user_id_filter,omitempty"`
+	GroupIDFilter      string        `yaml:"group_id_filter,omitempty"`
+	StartTLS           bool          `yaml:"start_tls,omitempty"`
+	TLSCACertFile      string        `yaml:"tls_ca_cert_file,omitempty"`
+	InsecureSkipVerify bool          `yaml:"insecure_skip_verify,omitempty"`
+	Timeout            time.Duration `yaml:"timeout,omitempty"`
+	PoolSize           int           `yaml:"pool_size,omitempty"`
+}
+
+/* lookup cache parameters */
+type DirectoryCache struct {
+	Disabled    bool          `yaml:"disabled,omitempty"`
+	TTL         time.Duration `yaml:"ttl,omitempty"`
+	NegativeTTL time.Duration `yaml:"negative_ttl,omitempty"`
+	MaxEntries  int           `yaml:"max_entries,omitempty"`
+}
+
+/* normalization function */
+func (d *Directory) Normalize() error {
+
+	/* resolve principals through the local NSS stack by default */
+	if d.Backend == "" {
+		d.Backend = "nss"
+	}
+
+	switch d.Backend {
+	case "nss":
+	case "ldap":
+		if err := d.LDAP.Normalize(); err != nil {
+			return fmt.Errorf("ldap configuration error: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown directory backend %q (expected \"nss\" or \"ldap\")", d.Backend)
+	}
+
+	return d.Cache.Normalize()
+}
+
+/* normalization function */
+func (l *LDAP) Normalize() error {
+
+	if l.URL == "" {
+		return errors.New(heredoc.Doc(`
+			LDAP URL not provided in the config file
+
+			Please check the docs for more information: 
+		`))
+	}
+
+	if len(l.UserBaseDNs) == 0 || len(l.GroupBaseDNs) == 0 {
+		return errors.New(heredoc.Doc(`
+			LDAP user and group base DNs must be provided in the config file
+
+			Please check the docs for more information: 
+		`))
+	}
+
+	if l.BindPassword != "" && l.BindPasswordFile != "" {
+		return errors.New("bind_password and bind_password_file are mutually exclusive")
+	}
+
+	/* RFC 2307 (posixAccount/posixGroup) schema is assumed by default */
+	if l.UserFilter == "" {
+		l.UserFilter = "(&(objectClass=posixAccount)(uid=%s))"
+	}
+
+	if l.GroupFilter == "" {
+		l.GroupFilter = "(&(objectClass=posixGroup)(cn=%s))"
+	}
+
+	/* filters used when an entity is given as a numeric uid/gid */
+	if l.UserIDFilter == "" {
+		l.UserIDFilter = "(&(objectClass=posixAccount)(uidNumber=%s))"
+	}
+
+	if l.GroupIDFilter == "" {
+		l.GroupIDFilter = "(&(objectClass=posixGroup)(gidNumber=%s))"
+	}
+
+	/* set default timeout to 5 seconds */
+	if l.Timeout == 0 {
+		l.Timeout = 5 * time.Second
+	}
+
+	/* up to 4 concurrent searches by default */
+	if l.PoolSize <= 0 {
+		l.PoolSize = 4
+	}
+
+	return nil
+}
+
+/* normalization function */
+func (c *DirectoryCache) Normalize() error {
+
+	/* set default TTL to 5 minutes */
+	if c.TTL == 0 {
+		c.TTL = 5 * time.Minute
+	}
+
+	/* unknown principals are remembered for a shorter time */
+	if c.NegativeTTL == 0 {
+		c.NegativeTTL = 30 * time.Second
+	}
+
+	/* set default cache size to 10000 entries */
+	if c.MaxEntries == 0 {
+		c.MaxEntries = 10000
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+/* drift policies */
+const (
+	/* drifted paths are only listed in the drift report */
+	DriftReport = "report"
+
+	/* drifted paths get back the ACL aclapi last set */
+	DriftRemediate = "remediate"
+)
+
+/* scheduled comparison of the ACLs on disk with the ones aclapi last set */
+type Drift struct {
+	Enabled  bool          `yaml:"enabled,omitempty"`
+	At       string        `yaml:"at,omitempty"`
+	Interval time.Duration `yaml:"interval,omitempty"`
+	Policy   string        `yaml:"policy,omitempty"`
+	Shares   []DriftShare  `yaml:"shares,omitempty"`
+}
+
+/* policy of the paths below a share */
+type DriftShare struct {
+	Path   string `yaml:"path"`
+	Policy string `yaml:"policy"`
+}
+
+/* normalization function */
+func (d *Drift) Normalize() error {
+
+	/* first scan at 03:00 local time by default */
+	if d.At == "" {
+		d.At = "03:00"
+	}
+
+	if _, err := time.Parse("15:04", d.At); err != nil {
+		return fmt.Errorf("invalid drift at %q (expected HH:MM)", d.At)
+	}
+
+	/* scan every night by default */
+	if d.Interval == 0 {
+		d.Interval = 24 * time.Hour
+	}
+
+	if d.Interval < time.Minute {
+		return errors.New("drift interval must be at least one minute")
+	}
+
+	/* drift is only reported by default */
+	if d.Policy == "" {
+		d.Policy = DriftReport
+	}
+
+	if err := checkDriftPolicy(d.Policy); err != nil {
+		return err
+	}
+
+	for i := range d.Shares {
+		share := &d.Shares[i]
+		if !filepath.IsAbs(share.Path) {
+			return errors.New("drift share paths must be absolute")
+		}
+		share.Path = filepath.Clean(share.Path)
+
+		if err := checkDriftPolicy(share.Policy); err != nil {
+			return fmt.Errorf("drift share %s: %w", share.Path, err)
+		}
+	}
+
+	return nil
+}
+
+/* time of the first scan as an offset from midnight */
+func (d *Drift) TimeOfDay() time.Duration {
+	at, _ := time.Parse("15:04", d.At)
+	return time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute
+}
+
+func checkDriftPolicy(policy string) error {
+	if policy != DriftReport && policy != DriftRemediate {
+		return fmt.Errorf("invalid drift policy %q (expected %s or %s)", policy, DriftReport, DriftRemediate)
+	}
+	return nil
+}
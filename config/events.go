@@ -0,0 +1,24 @@
+package config
+
+import "errors"
+
+/* ACL change events streamed by WatchACLChanges */
+type Events struct {
+	Enabled    bool `yaml:"enabled,omitempty"`
+	BufferSize int  `yaml:"buffer_size,omitempty"`
+}
+
+/* normalization function */
+func (e *Events) Normalize() error {
+
+	/* keep the last 4096 events for reconnecting watchers by default */
+	if e.BufferSize == 0 {
+		e.BufferSize = 4096
+	}
+
+	if e.BufferSize < 0 {
+		return errors.New("buffer_size must be positive")
+	}
+
+	return nil
+}
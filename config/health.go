@@ -0,0 +1,32 @@
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+/* health reporting through the grpc.health.v1 service */
+type Health struct {
+	ProbeInterval time.Duration `yaml:"probe_interval,omitempty"`
+	ProbeTimeout  time.Duration `yaml:"probe_timeout,omitempty"`
+}
+
+/* normalization function */
+func (h *Health) Normalize() error {
+
+	/* check aclcore and the certificate every 10 seconds by default */
+	if h.ProbeInterval == 0 {
+		h.ProbeInterval = 10 * time.Second
+	}
+
+	/* a probe taking longer than 3 seconds counts as failed by default */
+	if h.ProbeTimeout == 0 {
+		h.ProbeTimeout = 3 * time.Second
+	}
+
+	if h.ProbeInterval < 0 || h.ProbeTimeout < 0 {
+		return errors.New("health probe_interval and probe_timeout must be positive")
+	}
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+/* serialization of conflicting operations on the same paths */
+type Locks struct {
+	WaitTimeout time.Duration `yaml:"wait_timeout,omitempty"`
+}
+
+/* normalization function */
+func (l *Locks) Normalize() error {
+
+	/* give up after waiting 10 seconds for a conflicting operation by default */
+	if l.WaitTimeout == 0 {
+		l.WaitTimeout = 10 * time.Second
+	}
+
+	if l.WaitTimeout < 0 {
+		return errors.New("locks wait_timeout must be positive")
+	}
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"fmt"
+	"net"
+)
+
+/* Prometheus metrics endpoint */
+type Metrics struct {
+	Enabled bool   `yaml:"enabled,omitempty"`
+	Listen  string `yaml:"listen,omitempty"`
+}
+
+/* normalization function */
+func (m *Metrics) Normalize() error {
+
+	/* only reachable from the host itself by default */
+	if m.Listen == "" {
+		m.Listen = "127.0.0.1:6594"
+	}
+
+	if _, _, err := net.SplitHostPort(m.Listen); err != nil {
+		return fmt.Errorf("invalid metrics listen address %q: %w", m.Listen, err)
+	}
+
+	return nil
+}
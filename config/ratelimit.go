@@ -0,0 +1,70 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+/* per-client request budgets */
+type RateLimit struct {
+	Enabled bool                  `yaml:"enabled,omitempty"`
+	Default RateBudget            `yaml:"default,omitempty"`
+	Methods map[string]RateBudget `yaml:"methods,omitempty"`
+	Caller  RateBudget            `yaml:"caller,omitempty"`
+}
+
+/* token bucket of a caller for one method */
+type RateBudget struct {
+	Rate  float64 `yaml:"rate,omitempty"`
+	Burst int     `yaml:"burst,omitempty"`
+}
+
+/* normalization function */
+func (r *RateLimit) Normalize() error {
+
+	/* 20 requests per second per caller and method by default */
+	if r.Default.Rate == 0 {
+		r.Default.Rate = 20
+	}
+
+	if err := r.Default.Normalize(); err != nil {
+		return fmt.Errorf("default budget: %w", err)
+	}
+
+	/* 200 requests per second per client certificate, whatever end users it names, by default */
+	if r.Caller.Rate == 0 {
+		r.Caller.Rate = 200
+	}
+
+	if err := r.Caller.Normalize(); err != nil {
+		return fmt.Errorf("caller budget: %w", err)
+	}
+
+	for method, budget := range r.Methods {
+		if err := budget.Normalize(); err != nil {
+			return fmt.Errorf("budget of %s: %w", method, err)
+		}
+		r.Methods[method] = budget
+	}
+
+	return nil
+}
+
+/* normalization function */
+func (b *RateBudget) Normalize() error {
+	if b.Rate <= 0 {
+		return errors.New("rate must be positive")
+	}
+
+	/* one second worth of requests at once by default */
+	if b.Burst == 0 {
+		b.Burst = int(math.Max(1, math.Ceil(b.Rate)))
+	}
+
+	if b.Burst < 0 {
+		return errors.New("burst must be positive")
+	}
+
+	return nil
+}
@@ -15,6 +15,7 @@ type Server struct {
 	TLSCertFile		string	`yaml:"tls_cert_file,omitempty"`
 	TLSKeyFile		string	`yaml:"tls_key_file,omitempty"`
 	TLSCACertFile	string	`yaml:"tls_ca_cert_file,omitempty"`
+	Health			Health	`yaml:"health,omitempty"`
 }
 
 /* normalization function */
@@ -60,5 +61,5 @@ func (s *Server) Normalize() error {
 		fmt.Printf("Prefer using TLS for security\n\n")
 	}
 
-	return nil
+	return s.Health.Normalize()
 }
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"time"
+)
+
+/* detection of ACL changes made outside of aclapi */
+type Watcher struct {
+	Enabled        bool          `yaml:"enabled,omitempty"`
+	Roots          []string      `yaml:"roots,omitempty"`
+	Debounce       time.Duration `yaml:"debounce,omitempty"`
+	SuppressWindow time.Duration `yaml:"suppress_window,omitempty"`
+	NoFanotify     bool          `yaml:"no_fanotify,omitempty"`
+	MaxObjects     int           `yaml:"max_objects,omitempty"`
+}
+
+/* normalization function */
+func (w *Watcher) Normalize() error {
+
+	/* read ACLs once notifications stopped for half a second by default */
+	if w.Debounce == 0 {
+		w.Debounce = 500 * time.Millisecond
+	}
+
+	/* attribute notifications up to 2 seconds after an aclapi change to it by default */
+	if w.SuppressWindow == 0 {
+		w.SuppressWindow = 2 * time.Second
+	}
+
+	if w.Debounce < 0 || w.SuppressWindow < 0 {
+		return errors.New("watcher debounce and suppress_window must be positive")
+	}
+
+	/* keep the ACLs of up to 250000 objects in memory by default (some 100 MB) */
+	if w.MaxObjects == 0 {
+		w.MaxObjects = 250000
+	}
+
+	if w.MaxObjects < 0 {
+		return errors.New("watcher max_objects must be positive")
+	}
+
+	if !w.Enabled {
+		return nil
+	}
+
+	if len(w.Roots) == 0 {
+		return errors.New("watcher roots are required when the watcher is enabled")
+	}
+
+	for i, root := range w.Roots {
+		if !filepath.IsAbs(root) {
+			return errors.New("watcher roots must be absolute paths")
+		}
+		w.Roots[i] = filepath.Clean(root)
+	}
+
+	return nil
+}
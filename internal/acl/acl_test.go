@@ -0,0 +1,356 @@
+package acl
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclcore"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/directory"
+	pb "github.com/PythonHacker24/linux-acl-management-aclapi/internal/grpcserver/protos"
+)
+
+/* fields of the violations carried by an InvalidArgument error, nil for a nil error */
+func violationFields(t *testing.T, err error) []string {
+	t.Helper()
+
+	if err == nil {
+		return nil
+	}
+
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("code = %v, want InvalidArgument", st.Code())
+	}
+
+	var fields []string
+	var reason string
+	for _, detail := range st.Details() {
+		switch detail := detail.(type) {
+		case *errdetails.ErrorInfo:
+			reason = detail.Reason
+		case *errdetails.BadRequest:
+			for _, violation := range detail.FieldViolations {
+				fields = append(fields, violation.Field)
+			}
+		}
+	}
+	if reason != ReasonInvalidArgument {
+		t.Fatalf("reason = %q, want %q", reason, ReasonInvalidArgument)
+	}
+	return fields
+}
+
+func TestValidateApplyRequest(t *testing.T) {
+	legacy := func(entityType, entity, perms, action string) *pb.ApplyACLRequest {
+		return &pb.ApplyACLRequest{
+			TargetPath: "/srv/share",
+			Entry:      &pb.ACLEntry{EntityType: entityType, Entity: entity, Permissions: perms, Action: action},
+		}
+	}
+	typed := func(entityType pb.EntityType, entity string, perms uint32, action pb.Action) *pb.ApplyACLRequest {
+		return &pb.ApplyACLRequest{
+			TargetPath: "/srv/share",
+			EntryV2:    &pb.ACLEntryV2{EntityType: entityType, Entity: entity, Permissions: perms, Action: action},
+		}
+	}
+	const rwx = uint32(pb.Permission_PERMISSION_READ | pb.Permission_PERMISSION_WRITE | pb.Permission_PERMISSION_EXECUTE)
+
+	tests := []struct {
+		name string
+		req  *pb.ApplyACLRequest
+		want []string
+	}{
+		{"legacy named user", legacy("user", "alice", "rwX", "add"), nil},
+		{"legacy owner", legacy("user", "", "rwx", "modify"), nil},
+		{"legacy owning group", legacy("group", "", "r-x", "modify"), nil},
+		{"legacy remove without permissions", legacy("group", "staff", "", "remove"), nil},
+		{"typed named group", typed(pb.EntityType_ENTITY_TYPE_GROUP, "1000", rwx, pb.Action_ACTION_ADD), nil},
+		{"typed owner", typed(pb.EntityType_ENTITY_TYPE_USER, "", rwx, pb.Action_ACTION_MODIFY), nil},
+		{"typed mask", typed(pb.EntityType_ENTITY_TYPE_MASK, "", rwx, pb.Action_ACTION_MODIFY), nil},
+
+		{"missing path", &pb.ApplyACLRequest{Entry: legacy("user", "alice", "rwx", "add").Entry}, []string{"target_path"}},
+		{"relative path", &pb.ApplyACLRequest{TargetPath: "srv/share", Entry: legacy("user", "alice", "rwx", "add").Entry}, []string{"target_path"}},
+		{"path with NUL", &pb.ApplyACLRequest{TargetPath: "/srv/a\x00b", Entry: legacy("user", "alice", "rwx", "add").Entry}, []string{"target_path"}},
+		{"missing entry", &pb.ApplyACLRequest{TargetPath: "/srv/share"}, []string{"entry"}},
+		{"both entries", &pb.ApplyACLRequest{
+			TargetPath: "/srv/share",
+			Entry:      legacy("user", "alice", "rwx", "add").Entry,
+			EntryV2:    typed(pb.EntityType_ENTITY_TYPE_USER, "alice", rwx, pb.Action_ACTION_ADD).EntryV2,
+		}, []string{"entry_v2"}},
+
+		{"legacy unknown action", legacy("user", "alice", "rwx", "chmod"), []string{"entry.action"}},
+		{"legacy unknown entity type", legacy("role", "alice", "rwx", "add"), []string{"entry.entity_type"}},
+		{"legacy missing permissions", legacy("user", "alice", "", "add"), []string{"entry.permissions"}},
+		{"legacy invalid permissions", legacy("user", "alice", "rwz", "add"), []string{"entry.permissions"}},
+		{"legacy repeated permissions", legacy("user", "alice", "rr", "add"), []string{"entry.permissions"}},
+		{"legacy long permissions", legacy("user", "alice", "rwx--", "add"), []string{"entry.permissions"}},
+		{"legacy entity on other", legacy("other", "alice", "r--", "modify"), []string{"entry.entity"}},
+		{"legacy everything wrong", legacy("role", "a:b", "q", "chmod"), []string{"entry.action", "entry.entity_type", "entry.permissions"}},
+
+		{"typed missing action", typed(pb.EntityType_ENTITY_TYPE_USER, "alice", rwx, pb.Action_ACTION_UNSPECIFIED), []string{"entry_v2.action"}},
+		{"typed unknown action", typed(pb.EntityType_ENTITY_TYPE_USER, "alice", rwx, pb.Action(42)), []string{"entry_v2.action"}},
+		{"typed missing entity type", typed(pb.EntityType_ENTITY_TYPE_UNSPECIFIED, "alice", rwx, pb.Action_ACTION_ADD), []string{"entry_v2.entity_type"}},
+		{"typed unknown entity type", typed(pb.EntityType(42), "alice", rwx, pb.Action_ACTION_ADD), []string{"entry_v2.entity_type"}},
+		{"typed entity on mask", typed(pb.EntityType_ENTITY_TYPE_MASK, "alice", rwx, pb.Action_ACTION_ADD), []string{"entry_v2.entity"}},
+		{"typed unknown permission bits", typed(pb.EntityType_ENTITY_TYPE_USER, "alice", 0x30, pb.Action_ACTION_ADD), []string{"entry_v2.permissions"}},
+
+		/* names that would change the meaning of a textual ACL entry */
+		{"name with colon", legacy("user", "alice:rwx,user:eve", "rwx", "add"), []string{"entry.entity"}},
+		{"name with comma", typed(pb.EntityType_ENTITY_TYPE_GROUP, "staff,other::rwx", rwx, pb.Action_ACTION_ADD), []string{"entry_v2.entity"}},
+		{"name with newline", legacy("user", "alice\nuser:eve:rwx", "rwx", "add"), []string{"entry.entity"}},
+		{"name with NUL", legacy("user", "alice\x00", "rwx", "add"), []string{"entry.entity"}},
+		{"name with escape sequence", typed(pb.EntityType_ENTITY_TYPE_USER, "\x1b[2Jalice", rwx, pb.Action_ACTION_ADD), []string{"entry_v2.entity"}},
+		{"name not UTF-8", legacy("group", "staff\xff", "rwx", "add"), []string{"entry.entity"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry, err := validateApplyRequest(test.req)
+
+			fields := violationFields(t, err)
+			slices.Sort(fields)
+			if !slices.Equal(fields, test.want) {
+				t.Fatalf("violations = %v, want %v", fields, test.want)
+			}
+			if err == nil && entry == nil {
+				t.Fatal("valid request returned no entry")
+			}
+		})
+	}
+}
+
+func TestValidateDefaultEntryTarget(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	link := filepath.Join(dir, "link")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(dir, link); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{dir, nil},
+		{file, []string{"entry_v2.is_default"}},
+
+		/* left to the backend */
+		{link, nil},
+		{filepath.Join(dir, "missing"), nil},
+	}
+
+	for _, test := range tests {
+		_, err := validateApplyRequest(&pb.ApplyACLRequest{
+			TargetPath: test.path,
+			EntryV2:    &pb.ACLEntryV2{EntityType: pb.EntityType_ENTITY_TYPE_MASK, Action: pb.Action_ACTION_MODIFY, IsDefault: true},
+		})
+		if fields := violationFields(t, err); !slices.Equal(fields, test.want) {
+			t.Errorf("violations for %s = %v, want %v", test.path, fields, test.want)
+		}
+	}
+}
+
+func TestBuildCoreEntryOwner(t *testing.T) {
+	tests := []struct {
+		entityType pb.EntityType
+		want       aclmodel.Tag
+	}{
+		{pb.EntityType_ENTITY_TYPE_USER, aclmodel.TagUserObj},
+		{pb.EntityType_ENTITY_TYPE_GROUP, aclmodel.TagGroupObj},
+		{pb.EntityType_ENTITY_TYPE_MASK, aclmodel.TagMask},
+		{pb.EntityType_ENTITY_TYPE_OTHER, aclmodel.TagOther},
+	}
+
+	/* no directory lookup is needed for unnamed entries */
+	s := &ACLServer{}
+	for _, test := range tests {
+		entry, err := s.buildCoreEntry(context.Background(), &pb.ACLEntryV2{
+			EntityType:  test.entityType,
+			Permissions: uint32(pb.Permission_PERMISSION_READ),
+			Action:      pb.Action_ACTION_MODIFY,
+		})
+		if err != nil || entry.Tag != test.want || entry.ID != nil || entry.Perms != aclmodel.PermRead {
+			t.Errorf("%v entry = %+v, %v, want an unnamed %s entry", test.entityType, entry, err, test.want)
+		}
+	}
+}
+
+/* directory resolving every principal to the same name */
+type stubDirectory struct {
+	name string
+}
+
+func (d stubDirectory) LookupUser(ctx context.Context, name string) (*directory.User, error) {
+	return &directory.User{Name: d.name, UID: 1000}, nil
+}
+
+func (d stubDirectory) LookupGroup(ctx context.Context, name string) (*directory.Group, error) {
+	return &directory.Group{Name: d.name, GID: 1000}, nil
+}
+
+func (d stubDirectory) GroupMembers(ctx context.Context, name string) ([]string, error) {
+	return []string{d.name}, nil
+}
+
+func (d stubDirectory) Close() error {
+	return nil
+}
+
+func TestBuildCoreEntryUnsafeName(t *testing.T) {
+	entry := &pb.ACLEntryV2{
+		EntityType:  pb.EntityType_ENTITY_TYPE_USER,
+		Entity:      "alice",
+		Permissions: uint32(pb.Permission_PERMISSION_READ),
+		Action:      pb.Action_ACTION_ADD,
+	}
+
+	s := &ACLServer{Directory: stubDirectory{name: "alice"}}
+	if coreEntry, err := s.buildCoreEntry(context.Background(), entry); err != nil || coreEntry.Qualifier != "alice" {
+		t.Fatalf("buildCoreEntry = %+v, %v, want alice", coreEntry, err)
+	}
+
+	/* a compromised or broken directory is not a transient failure */
+	s.Directory = stubDirectory{name: "alice:rwx,user:eve"}
+	_, err := s.buildCoreEntry(context.Background(), entry)
+	if !errors.Is(err, errUnsafeName) {
+		t.Fatalf("buildCoreEntry error = %v, want errUnsafeName", err)
+	}
+
+	st := status.Convert(directoryError(context.Background(), err))
+	if st.Code() != codes.FailedPrecondition {
+		t.Fatalf("code = %v, want FailedPrecondition", st.Code())
+	}
+	if info, ok := st.Details()[0].(*errdetails.ErrorInfo); !ok || info.Reason != ReasonUnsafePrincipalName {
+		t.Fatalf("details = %v, want reason %s", st.Details(), ReasonUnsafePrincipalName)
+	}
+}
+
+func TestLegacyRoundTrip(t *testing.T) {
+	tests := []struct {
+		legacy *pb.ACLEntry
+		typed  *pb.ACLEntryV2
+	}{
+		{
+			&pb.ACLEntry{EntityType: "user", Entity: "alice", Permissions: "rwX", Action: "add"},
+			&pb.ACLEntryV2{
+				EntityType:  pb.EntityType_ENTITY_TYPE_USER,
+				Entity:      "alice",
+				Permissions: uint32(pb.Permission_PERMISSION_READ | pb.Permission_PERMISSION_WRITE | pb.Permission_PERMISSION_CONDITIONAL_EXECUTE),
+				Action:      pb.Action_ACTION_ADD,
+			},
+		},
+		{
+			&pb.ACLEntry{EntityType: "group", Entity: "staff", Permissions: "r-x", Action: "modify", IsDefault: true},
+			&pb.ACLEntryV2{
+				EntityType:  pb.EntityType_ENTITY_TYPE_GROUP,
+				Entity:      "staff",
+				Permissions: uint32(pb.Permission_PERMISSION_READ | pb.Permission_PERMISSION_EXECUTE),
+				Action:      pb.Action_ACTION_MODIFY,
+				IsDefault:   true,
+			},
+		},
+		{
+			&pb.ACLEntry{EntityType: "user", Permissions: "rwx", Action: "modify"},
+			&pb.ACLEntryV2{
+				EntityType:  pb.EntityType_ENTITY_TYPE_USER,
+				Permissions: uint32(pb.Permission_PERMISSION_READ | pb.Permission_PERMISSION_WRITE | pb.Permission_PERMISSION_EXECUTE),
+				Action:      pb.Action_ACTION_MODIFY,
+			},
+		},
+		{
+			&pb.ACLEntry{EntityType: "mask", Permissions: "---", Action: "modify", IsDefault: true},
+			&pb.ACLEntryV2{EntityType: pb.EntityType_ENTITY_TYPE_MASK, Action: pb.Action_ACTION_MODIFY, IsDefault: true},
+		},
+		{
+			&pb.ACLEntry{EntityType: "other", Permissions: "r--", Action: "modify"},
+			&pb.ACLEntryV2{
+				EntityType:  pb.EntityType_ENTITY_TYPE_OTHER,
+				Permissions: uint32(pb.Permission_PERMISSION_READ),
+				Action:      pb.Action_ACTION_MODIFY,
+			},
+		},
+		{
+			&pb.ACLEntry{EntityType: "group", Entity: "staff", Action: "remove"},
+			&pb.ACLEntryV2{EntityType: pb.EntityType_ENTITY_TYPE_GROUP, Entity: "staff", Action: pb.Action_ACTION_REMOVE},
+		},
+	}
+
+	for _, test := range tests {
+		typed, err := EntryFromLegacy(test.legacy)
+		if err != nil || !proto.Equal(typed, test.typed) {
+			t.Errorf("EntryFromLegacy(%v) = %v, %v, want %v", test.legacy, typed, err, test.typed)
+			continue
+		}
+		if legacy := EntryToLegacy(typed); !proto.Equal(legacy, test.legacy) {
+			t.Errorf("EntryToLegacy(%v) = %v, want %v", typed, legacy, test.legacy)
+		}
+	}
+
+	/* unspecified enums have no legacy spelling and do not convert back */
+	legacy := EntryToLegacy(&pb.ACLEntryV2{Permissions: uint32(pb.Permission_PERMISSION_READ)})
+	if legacy.EntityType != "" || legacy.Action != "" || legacy.Permissions != "r--" {
+		t.Fatalf("EntryToLegacy of unspecified enums = %v", legacy)
+	}
+	if _, err := EntryFromLegacy(legacy); !slices.Equal(violationFields(t, err), []string{"entry.action", "entry.entity_type"}) {
+		t.Fatalf("EntryFromLegacy(%v) error = %v, want action and entity type violations", legacy, err)
+	}
+}
+
+func TestCoreOperationError(t *testing.T) {
+	tests := []struct {
+		errno     syscall.Errno
+		code      codes.Code
+		reason    string
+		errnoName string
+	}{
+		{syscall.ENOENT, codes.NotFound, ReasonPathNotFound, "ENOENT"},
+		{syscall.ENOTDIR, codes.NotFound, ReasonPathNotFound, "ENOTDIR"},
+		{syscall.EPERM, codes.PermissionDenied, ReasonPermissionDenied, "EPERM"},
+		{syscall.EACCES, codes.PermissionDenied, ReasonPermissionDenied, "EACCES"},
+		{syscall.EROFS, codes.PermissionDenied, ReasonPermissionDenied, "EROFS"},
+		{syscall.EINVAL, codes.InvalidArgument, ReasonInvalidArgument, "EINVAL"},
+		{syscall.ENOTSUP, codes.FailedPrecondition, ReasonUnsupportedFilesystem, "EOPNOTSUPP"},
+		{syscall.ENOSPC, codes.Internal, ReasonCoreOperationFailed, "ENOSPC"},
+		{syscall.Errno(200), codes.Internal, ReasonCoreOperationFailed, "200"},
+	}
+
+	for _, test := range tests {
+		st := status.Convert(coreOperationError(&aclcore.OperationError{Message: "setxattr failed", Errno: test.errno}))
+		if st.Code() != test.code {
+			t.Errorf("errno %d: code = %v, want %v", test.errno, st.Code(), test.code)
+			continue
+		}
+
+		info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+		if !ok {
+			t.Errorf("errno %d: details = %v, want an ErrorInfo", test.errno, st.Details())
+			continue
+		}
+		want := map[string]string{"errno": strconv.Itoa(int(test.errno)), "errno_name": test.errnoName}
+		if info.Reason != test.reason || info.Domain != ErrorDomain || !maps.Equal(info.Metadata, want) {
+			t.Errorf("errno %d: ErrorInfo = %v, want reason %s and metadata %v", test.errno, info, test.reason, want)
+		}
+	}
+
+	/* failures without an errno carry no metadata */
+	st := status.Convert(coreOperationError(&aclcore.OperationError{Message: "failed"}))
+	if info := st.Details()[0].(*errdetails.ErrorInfo); st.Code() != codes.Internal || info.Metadata != nil {
+		t.Fatalf("status without errno = %v, %v", st.Code(), info)
+	}
+}
@@ -0,0 +1,164 @@
+package acl
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/audit"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/backend"
+	pb "github.com/PythonHacker24/linux-acl-management-aclapi/internal/grpcserver/protos"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/identity"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/tracing"
+)
+
+/* reads the ACL of path for the audit trail and change events, nil if not needed or the read fails */
+func (s *ACLServer) snapshot(ctx context.Context, path string, needed bool) *aclmodel.ACL {
+	if !needed {
+		return nil
+	}
+
+	acl, err := s.Backend.Get(ctx, path)
+	if err != nil {
+		tracing.Logger(ctx).Warn("Failed to read ACL of a changed path",
+			zap.String("path", path),
+			zap.Error(err),
+		)
+		return nil
+	}
+	return acl
+}
+
+/* records the outcome of a change in the audit log (if enabled) */
+func (s *ACLServer) audit(ctx context.Context, req *backend.ApplyRequest, before, after *aclmodel.ACL, applyErr error) {
+	if s.Audit == nil {
+		return
+	}
+
+	caller := identity.FromContext(ctx)
+	entry := req.Entry
+
+	record := &audit.Record{
+		Caller:      caller.Caller,
+		CertSubject: caller.CertSubject,
+		EndUser:     caller.EndUser,
+		Peer:        caller.Peer,
+		TxnID:       req.TxnID,
+		Path:        req.Path,
+		Action:      req.Action,
+		Entry:       &entry,
+		Recursive:   req.Recursive,
+		Before:      before,
+		After:       after,
+		Outcome:     audit.OutcomeSuccess,
+	}
+
+	if trace := tracing.FromContext(ctx); trace != nil {
+		record.RequestID = trace.RequestID
+	}
+
+	if applyErr != nil {
+		record.Outcome = audit.OutcomeFailure
+		record.Error = applyErr.Error()
+	}
+
+	/*
+		the change already happened, a lost record does not fail the request but is
+		logged and counted (aclapi_audit_appends_total{result="failed"})
+	*/
+	if err := s.Audit.Append(record); err != nil {
+		tracing.Logger(ctx).Error("Failed to write audit record",
+			zap.String("path", req.Path),
+			zap.String("transaction_id", req.TxnID),
+			zap.Error(err),
+		)
+	}
+}
+
+/* converts an audit record to its API form, naming the principals of its entries */
+func (s *ACLServer) auditRecordInfo(ctx context.Context, record *audit.Record) (*pb.AuditRecord, error) {
+	info := &pb.AuditRecord{
+		Seq:           record.Seq,
+		Time:          timestamppb.New(record.Time),
+		Source:        record.Source,
+		Caller:        record.Caller,
+		CertSubject:   record.CertSubject,
+		EndUser:       record.EndUser,
+		Peer:          record.Peer,
+		TransactionId: record.TxnID,
+		RequestId:     record.RequestID,
+		Path:          record.Path,
+		Action:        actionValues[string(record.Action)],
+		Recursive:     record.Recursive,
+		Success:       record.Outcome == audit.OutcomeSuccess,
+		Error:         record.Error,
+		Hash:          record.Hash,
+	}
+
+	var err error
+	if info.Entry, err = s.requestedEntryInfo(ctx, record.Entry); err != nil {
+		return nil, err
+	}
+	if info.Before, err = s.aclInfos(ctx, record.Before); err != nil {
+		return nil, err
+	}
+	if info.After, err = s.aclInfos(ctx, record.After); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+/* requested entry in its API form (nil for an unknown entry) */
+func (s *ACLServer) requestedEntryInfo(ctx context.Context, entry *aclmodel.Entry) (*pb.ACLEntryInfo, error) {
+	if entry == nil {
+		return nil, nil
+	}
+
+	infos, err := s.entryInfos(ctx, []aclmodel.Entry{*entry})
+	if err != nil {
+		return nil, err
+	}
+
+	/* requested permissions, including conditional execute */
+	infos[0].Permissions = uint32(entry.Perms)
+	return infos[0], nil
+}
+
+/* access and default entries of an ACL in their API form (nil for an unknown ACL) */
+func (s *ACLServer) aclInfos(ctx context.Context, acl *aclmodel.ACL) ([]*pb.ACLEntryInfo, error) {
+	if acl == nil {
+		return nil, nil
+	}
+
+	entries := make([]aclmodel.Entry, 0, len(acl.Access)+len(acl.Default))
+	entries = append(entries, acl.Access...)
+	for _, entry := range acl.Default {
+		entry.Default = true
+		entries = append(entries, entry)
+	}
+	return s.entryInfos(ctx, entries)
+}
+
+/* page tokens are the opaque form of the sequence number of the last record sent */
+func encodePageToken(seq uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(seq, 10)))
+}
+
+func decodePageToken(token string) (uint64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+
+	seq, err := strconv.ParseUint(string(data), 10, 64)
+	if err != nil || seq == 0 {
+		return 0, errors.New("invalid page token")
+	}
+	return seq, nil
+}
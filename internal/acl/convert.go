@@ -0,0 +1,111 @@
+package acl
+
+import (
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+	pb "github.com/PythonHacker24/linux-acl-management-aclapi/internal/grpcserver/protos"
+)
+
+var (
+	/* legacy entity type strings and their enum values */
+	entityTypeValues = map[string]pb.EntityType{
+		"user":  pb.EntityType_ENTITY_TYPE_USER,
+		"group": pb.EntityType_ENTITY_TYPE_GROUP,
+		"mask":  pb.EntityType_ENTITY_TYPE_MASK,
+		"other": pb.EntityType_ENTITY_TYPE_OTHER,
+	}
+
+	/* legacy action strings and their enum values */
+	actionValues = map[string]pb.Action{
+		"add":    pb.Action_ACTION_ADD,
+		"modify": pb.Action_ACTION_MODIFY,
+		"remove": pb.Action_ACTION_REMOVE,
+	}
+
+	/* entity types as understood by aclcore */
+	entityTypeTags = map[pb.EntityType]aclmodel.Tag{
+		pb.EntityType_ENTITY_TYPE_USER:  aclmodel.TagUser,
+		pb.EntityType_ENTITY_TYPE_GROUP: aclmodel.TagGroup,
+		pb.EntityType_ENTITY_TYPE_MASK:  aclmodel.TagMask,
+		pb.EntityType_ENTITY_TYPE_OTHER: aclmodel.TagOther,
+	}
+
+	/* entity types reported for entries read from disk (owner entries have no entity) */
+	entryInfoTypes = map[aclmodel.Tag]pb.EntityType{
+		aclmodel.TagUserObj:  pb.EntityType_ENTITY_TYPE_USER,
+		aclmodel.TagUser:     pb.EntityType_ENTITY_TYPE_USER,
+		aclmodel.TagGroupObj: pb.EntityType_ENTITY_TYPE_GROUP,
+		aclmodel.TagGroup:    pb.EntityType_ENTITY_TYPE_GROUP,
+		aclmodel.TagMask:     pb.EntityType_ENTITY_TYPE_MASK,
+		aclmodel.TagOther:    pb.EntityType_ENTITY_TYPE_OTHER,
+	}
+
+	/* actions as understood by aclcore */
+	actionNames = map[pb.Action]aclmodel.Action{
+		pb.Action_ACTION_ADD:    aclmodel.ActionAdd,
+		pb.Action_ACTION_MODIFY: aclmodel.ActionModify,
+		pb.Action_ACTION_REMOVE: aclmodel.ActionRemove,
+	}
+)
+
+/* all permission bits a client may set in ACLEntryV2.permissions */
+const permissionMask = uint32(pb.Permission_PERMISSION_READ |
+	pb.Permission_PERMISSION_WRITE |
+	pb.Permission_PERMISSION_EXECUTE |
+	pb.Permission_PERMISSION_CONDITIONAL_EXECUTE)
+
+/*
+converts a legacy string entry into the typed entry
+invalid fields are recorded as violations against field and left unspecified
+*/
+func entryFromLegacy(v *violations, field string, entry *pb.ACLEntry) *pb.ACLEntryV2 {
+	typed := &pb.ACLEntryV2{
+		Entity:    entry.Entity,
+		IsDefault: entry.IsDefault,
+	}
+
+	action, ok := actionValues[entry.Action]
+	if !ok {
+		v.add(field+".action", "unknown action %q (expected add, modify or remove)", entry.Action)
+	}
+	typed.Action = action
+
+	entityType, ok := entityTypeValues[entry.EntityType]
+	if !ok {
+		v.add(field+".entity_type", "unknown entity type %q (expected user, group, mask or other)", entry.EntityType)
+	}
+	typed.EntityType = entityType
+
+	/* permissions are optional when removing an entry */
+	if entry.Permissions != "" || action != pb.Action_ACTION_REMOVE {
+		perms, err := aclmodel.ParsePerms(entry.Permissions)
+		if err != nil {
+			v.add(field+".permissions", "%s", err.Error())
+		}
+		typed.Permissions = uint32(perms)
+	}
+
+	return typed
+}
+
+/* converts a legacy string entry into the typed entry (codes.InvalidArgument on malformed fields) */
+func EntryFromLegacy(entry *pb.ACLEntry) (*pb.ACLEntryV2, error) {
+	var v violations
+	typed := entryFromLegacy(&v, "entry", entry)
+	return typed, v.err()
+}
+
+/* converts a typed entry back into the legacy string form */
+func EntryToLegacy(entry *pb.ACLEntryV2) *pb.ACLEntry {
+	legacy := &pb.ACLEntry{
+		EntityType: string(entityTypeTags[entry.EntityType]),
+		Entity:     entry.Entity,
+		Action:     string(actionNames[entry.Action]),
+		IsDefault:  entry.IsDefault,
+	}
+
+	if entry.Permissions != 0 || entry.Action != pb.Action_ACTION_REMOVE {
+		legacy.Permissions = aclmodel.Perm(entry.Permissions).String()
+	}
+
+	return legacy
+}
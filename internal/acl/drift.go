@@ -0,0 +1,54 @@
+package acl
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/config"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/drift"
+	pb "github.com/PythonHacker24/linux-acl-management-aclapi/internal/grpcserver/protos"
+)
+
+/* drift policies and their enum values */
+var driftPolicies = map[string]pb.DriftPolicy{
+	config.DriftReport:    pb.DriftPolicy_DRIFT_POLICY_REPORT,
+	config.DriftRemediate: pb.DriftPolicy_DRIFT_POLICY_REMEDIATE,
+}
+
+/* converts a drift report to its API form, keeping the drifts at or below prefix */
+func (s *ACLServer) driftReportInfo(ctx context.Context, report *drift.Report, prefix string) (*pb.DriftReport, error) {
+	info := &pb.DriftReport{
+		Started:  timestamppb.New(report.Started),
+		Finished: timestamppb.New(report.Finished),
+		Checked:  uint32(report.Checked),
+	}
+
+	for i := range report.Drifts {
+		d := &report.Drifts[i]
+		if prefix != "" && !aclmodel.PathWithin(d.Path, prefix) {
+			continue
+		}
+
+		pathDrift := &pb.PathDrift{
+			Path:       d.Path,
+			Seq:        d.Seq,
+			Policy:     driftPolicies[d.Policy],
+			Remediated: d.Remediated,
+			Error:      d.Error,
+		}
+
+		var err error
+		if pathDrift.Expected, err = s.aclInfos(ctx, d.Expected); err != nil {
+			return nil, err
+		}
+		if pathDrift.Actual, err = s.aclInfos(ctx, d.Actual); err != nil {
+			return nil, err
+		}
+
+		info.Drifts = append(info.Drifts, pathDrift)
+	}
+
+	return info, nil
+}
@@ -0,0 +1,231 @@
+package acl
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclcore"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/directory"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/events"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/pathlock"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/tracing"
+)
+
+/* error domain reported in google.rpc.ErrorInfo */
+const ErrorDomain = "aclapi.laclm"
+
+/* stable machine-readable reasons reported in google.rpc.ErrorInfo */
+const (
+	ReasonInvalidArgument       = "INVALID_ARGUMENT"
+	ReasonEntityNotFound        = "ENTITY_NOT_FOUND"
+	ReasonPathNotFound          = "PATH_NOT_FOUND"
+	ReasonPermissionDenied      = "PERMISSION_DENIED"
+	ReasonUnsupportedFilesystem = "UNSUPPORTED_FILESYSTEM"
+	ReasonDirectoryUnavailable  = "DIRECTORY_UNAVAILABLE"
+	ReasonUnsafePrincipalName   = "UNSAFE_PRINCIPAL_NAME"
+	ReasonCoreUnavailable       = "ACLCORE_UNAVAILABLE"
+	ReasonCoreTimeout           = "ACLCORE_TIMEOUT"
+	ReasonCoreUntrusted         = "ACLCORE_UNTRUSTED"
+	ReasonCoreProtocolError     = "ACLCORE_PROTOCOL_ERROR"
+	ReasonCoreOperationFailed   = "ACLCORE_OPERATION_FAILED"
+	ReasonAuditDisabled         = "AUDIT_DISABLED"
+	ReasonAuditUnavailable      = "AUDIT_UNAVAILABLE"
+	ReasonEventsDisabled        = "EVENTS_DISABLED"
+	ReasonResumeTokenExpired    = "RESUME_TOKEN_EXPIRED"
+	ReasonShuttingDown          = "SHUTTING_DOWN"
+	ReasonDriftDisabled         = "DRIFT_DISABLED"
+	ReasonDriftReportNotReady   = "DRIFT_REPORT_NOT_READY"
+	ReasonRateLimited           = "RATE_LIMITED"
+	ReasonPathLocked            = "PATH_LOCKED"
+	ReasonInternal              = "INTERNAL"
+)
+
+/* builds a status error carrying an ErrorInfo detail (metadata may be nil) */
+func statusError(code codes.Code, reason, message string, metadata map[string]string) error {
+	st := status.New(code, message)
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   ErrorDomain,
+		Metadata: metadata,
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+/* maps directory errors to gRPC status errors */
+func directoryError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, directory.ErrNotFound):
+		return statusError(codes.NotFound, ReasonEntityNotFound, err.Error(), nil)
+	case errors.Is(err, errUnsafeName):
+		/* the directory answered, retrying will not help until its entry is fixed */
+		tracing.Logger(ctx).Error("Directory returned an unsafe principal name",
+			zap.Error(err),
+		)
+		return statusError(codes.FailedPrecondition, ReasonUnsafePrincipalName, "directory entry of the principal has an unsafe name", nil)
+	}
+
+	tracing.Logger(ctx).Error("Directory lookup failed",
+		zap.Error(err),
+	)
+	return statusError(codes.Unavailable, ReasonDirectoryUnavailable, "directory lookup failed", nil)
+}
+
+/* maps a failure to read the audit log to a gRPC status error */
+func auditError(ctx context.Context, err error) error {
+	tracing.Logger(ctx).Error("Failed to query audit log",
+		zap.Error(err),
+	)
+	return statusError(codes.Internal, ReasonAuditUnavailable, "audit log could not be read", nil)
+}
+
+/* maps a failure to lock the target path to a gRPC status error */
+func lockError(path string, err error) error {
+	switch {
+	case errors.Is(err, pathlock.ErrTimeout):
+		return statusError(codes.Aborted, ReasonPathLocked, err.Error(), map[string]string{"path": path})
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "request cancelled")
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, "deadline exceeded")
+	}
+	return statusError(codes.Internal, ReasonInternal, err.Error(), nil)
+}
+
+/* maps a failed drift scan to a gRPC status error */
+func driftError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "request cancelled")
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, "deadline exceeded")
+	}
+
+	/* the expected ACLs come from the audit log */
+	return auditError(ctx, err)
+}
+
+/* maps an error of a change event subscription to a gRPC status error */
+func eventsError(err error) error {
+	switch {
+	case errors.Is(err, events.ErrInvalidToken):
+		var v violations
+		v.add("resume_token", "%s", err.Error())
+		return v.err()
+	case errors.Is(err, events.ErrTokenExpired):
+		return statusError(codes.OutOfRange, ReasonResumeTokenExpired, err.Error(), nil)
+	case errors.Is(err, events.ErrClosed):
+		return statusError(codes.Unavailable, ReasonShuttingDown, "server is shutting down", nil)
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "request cancelled")
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, "deadline exceeded")
+	}
+	return statusError(codes.Internal, ReasonInternal, err.Error(), nil)
+}
+
+/* maps a backend error to a gRPC status error */
+func backendError(ctx context.Context, err error) error {
+	var opErr *aclcore.OperationError
+	var protoErr *aclcore.ProtocolError
+	var peerErr *aclcore.PeerError
+	var errno syscall.Errno
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return statusError(codes.DeadlineExceeded, ReasonCoreTimeout, "root daemon did not answer in time", nil)
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "request cancelled")
+	case errors.As(err, &opErr):
+		return coreOperationError(opErr)
+	case errors.As(err, &peerErr):
+		tracing.Logger(ctx).Error("Refusing to talk to untrusted root daemon",
+			zap.Error(err),
+		)
+		return statusError(codes.Unavailable, ReasonCoreUntrusted, "root daemon identity could not be verified", nil)
+	case errors.As(err, &protoErr):
+		tracing.Logger(ctx).Error("aclcore protocol error",
+			zap.Error(err),
+		)
+		return statusError(codes.Internal, ReasonCoreProtocolError, "invalid response from root daemon", nil)
+	case errors.As(err, &errno):
+		/* failed system call of the direct xattr backend */
+		message := strings.TrimSuffix(err.Error(), ": "+errno.Error())
+		return coreOperationError(&aclcore.OperationError{Message: message, Errno: errno})
+	case errors.Is(err, aclcore.ErrUnavailable):
+		tracing.Logger(ctx).Error("Failed to communicate with root daemon",
+			zap.Error(err),
+		)
+		return statusError(codes.Unavailable, ReasonCoreUnavailable, "root daemon unavailable", nil)
+	}
+
+	tracing.Logger(ctx).Error("aclcore request failed",
+		zap.Error(err),
+	)
+	return statusError(codes.Internal, ReasonInternal, "root daemon request failed", nil)
+}
+
+/* reports whether the backend failed with ENOTDIR, directly or through aclcore */
+func isNotDirectory(err error) bool {
+	var opErr *aclcore.OperationError
+	if errors.As(err, &opErr) {
+		return opErr.Errno == syscall.ENOTDIR
+	}
+	return errors.Is(err, syscall.ENOTDIR)
+}
+
+/* maps an operation failure reported by aclcore to a gRPC status error */
+func coreOperationError(err *aclcore.OperationError) error {
+	var metadata map[string]string
+	if err.Errno != 0 {
+		metadata = map[string]string{
+			"errno":      strconv.Itoa(int(err.Errno)),
+			"errno_name": errnoName(err.Errno),
+		}
+	}
+
+	switch err.Errno {
+	case syscall.ENOENT, syscall.ENOTDIR:
+		return statusError(codes.NotFound, ReasonPathNotFound, err.Error(), metadata)
+	case syscall.EACCES, syscall.EPERM, syscall.EROFS:
+		return statusError(codes.PermissionDenied, ReasonPermissionDenied, err.Error(), metadata)
+	case syscall.EOPNOTSUPP:
+		return statusError(codes.FailedPrecondition, ReasonUnsupportedFilesystem, err.Error(), metadata)
+	case syscall.EINVAL:
+		return statusError(codes.InvalidArgument, ReasonInvalidArgument, err.Error(), metadata)
+	}
+
+	return statusError(codes.Internal, ReasonCoreOperationFailed, err.Error(), metadata)
+}
+
+/* symbolic names of errnos aclcore is expected to report */
+var errnoNames = map[syscall.Errno]string{
+	syscall.ENOENT:     "ENOENT",
+	syscall.ENOTDIR:    "ENOTDIR",
+	syscall.EACCES:     "EACCES",
+	syscall.EPERM:      "EPERM",
+	syscall.EROFS:      "EROFS",
+	syscall.EOPNOTSUPP: "EOPNOTSUPP",
+	syscall.EINVAL:     "EINVAL",
+	syscall.ENOSPC:     "ENOSPC",
+	syscall.EIO:        "EIO",
+	syscall.E2BIG:      "E2BIG",
+}
+
+/* returns the symbolic name of an errno (numeric if unknown) */
+func errnoName(errno syscall.Errno) string {
+	if name, ok := errnoNames[errno]; ok {
+		return name
+	}
+	return strconv.Itoa(int(errno))
+}
@@ -0,0 +1,67 @@
+package acl
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/backend"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/events"
+	pb "github.com/PythonHacker24/linux-acl-management-aclapi/internal/grpcserver/protos"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/identity"
+)
+
+/* change kinds and their enum values */
+var changeKinds = map[events.Kind]pb.ChangeKind{
+	events.KindApplied:  pb.ChangeKind_CHANGE_KIND_APPLIED,
+	events.KindReverted: pb.ChangeKind_CHANGE_KIND_REVERTED,
+	events.KindDetected: pb.ChangeKind_CHANGE_KIND_DETECTED,
+}
+
+/* tells watchers about a change applied on behalf of the caller (if events are enabled) */
+func (s *ACLServer) publishApplied(ctx context.Context, req *backend.ApplyRequest, after *aclmodel.ACL) {
+	if s.Events == nil {
+		return
+	}
+
+	caller := identity.FromContext(ctx)
+	entry := req.Entry
+
+	s.Events.Publish(events.Event{
+		Kind:      events.KindApplied,
+		Path:      req.Path,
+		Recursive: req.Recursive,
+		Action:    req.Action,
+		Entry:     &entry,
+		Caller:    caller.Caller,
+		EndUser:   caller.EndUser,
+		TxnID:     req.TxnID,
+		ACL:       after,
+	})
+}
+
+/* converts an event to its API form, naming the principals of its entries */
+func (s *ACLServer) changeEvent(ctx context.Context, e *events.Event) (*pb.ACLChangeEvent, error) {
+	event := &pb.ACLChangeEvent{
+		ResumeToken:   s.Events.Token(e),
+		Time:          timestamppb.New(e.Time),
+		Kind:          changeKinds[e.Kind],
+		Path:          e.Path,
+		Recursive:     e.Recursive,
+		Action:        actionValues[string(e.Action)],
+		Caller:        e.Caller,
+		EndUser:       e.EndUser,
+		TransactionId: e.TxnID,
+	}
+
+	var err error
+	if event.Entry, err = s.requestedEntryInfo(ctx, e.Entry); err != nil {
+		return nil, err
+	}
+	if event.Acl, err = s.aclInfos(ctx, e.ACL); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
@@ -2,93 +2,249 @@ package acl
 
 import (
 	"context"
-	"encoding/json"
-	"net"
 
-	"github.com/PythonHacker24/linux-acl-management-aclapi/config"
+	"google.golang.org/grpc/codes"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/audit"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/backend"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/directory"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/drift"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/events"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/fswatch"
 	pb "github.com/PythonHacker24/linux-acl-management-aclapi/internal/grpcserver/protos"
-	"go.uber.org/zap"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/pathlock"
 )
 
 /* ACL Server for gRPC endpoint */
 type ACLServer struct {
 	pb.UnimplementedACLServiceServer
+
+	/* principal directory used for entity validation and group expansion */
+	Directory directory.Directory
+
+	/* backend performing the ACL changes (aclcore or direct xattr) */
+	Backend backend.Backend
+
+	/* audit log of ACL changes, nil if auditing is disabled */
+	Audit *audit.Log
+
+	/* broker streaming ACL changes to watchers, nil if disabled */
+	Events *events.Broker
+
+	/* watcher of external ACL changes, told about our own ones (nil if disabled) */
+	Watcher *fswatch.Watcher
+
+	/* scanner comparing the ACLs on disk with the recorded ones, nil if disabled */
+	Drift *drift.Scanner
+
+	/* serializes conflicting changes, waiting as long as the request context by default */
+	Locks *pathlock.Manager
+}
+
+/* creates the ACL server resolving principals through dir and applying changes through b */
+func NewACLServer(dir directory.Directory, b backend.Backend) *ACLServer {
+	return &ACLServer{Directory: dir, Backend: b, Locks: pathlock.New(pathlock.Options{})}
 }
 
 /* handler for handling ACL entry requests */
 func (s *ACLServer) ApplyACLEntry(ctx context.Context, req *pb.ApplyACLRequest) (*pb.ApplyACLResponse, error) {
-	/* set the socket path as per the configuration */
-	socketPath := config.APIDConfig.DConfig.SocketPath
-
-	/* create the ACL modification message */
-	aclmsg := struct {
-		TxnID  string `json:"transactionID"`
-		Action string `json:"action"`
-		Entry  string `json:"entry"`
-		Path   string `json:"path"`
-	}{
-		TxnID:  req.TransactionID,
-		Action: req.Entry.Action,
-		Entry:  buildACLEntry(req.Entry),
-		Path:   req.TargetPath,
-	}
-
-	/* marshall the ACL modification message to JSON data */
-	acldata, err := json.Marshal(aclmsg)
+	/* reject malformed requests before doing anything else */
+	reqEntry, err := validateApplyRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	/* resolve the named user or group before involving the root daemon */
+	entry, err := s.buildCoreEntry(ctx, reqEntry)
+	if err != nil {
+		return nil, directoryError(ctx, err)
+	}
+
+	applyReq := &backend.ApplyRequest{
+		TxnID:     req.TransactionID,
+		Action:    actionNames[reqEntry.Action],
+		Entry:     entry,
+		Path:      req.TargetPath,
+		Recursive: req.Recursive,
+	}
+
+	/* aclcore reads, modifies and writes the ACL, concurrent changes of the same path would get lost */
+	unlock, err := s.Locks.Lock(ctx, req.TargetPath, req.Recursive)
+	if err != nil {
+		return nil, lockError(req.TargetPath, err)
+	}
+	defer unlock()
+
+	/* ask the backend to apply the entry, recording the ACL on both sides of the change */
+	before := s.snapshot(ctx, req.TargetPath, s.Audit != nil)
+	done := s.Watcher.Expect(req.TargetPath, req.Recursive)
+	message, err := s.Backend.Apply(ctx, applyReq)
+	done()
+	after := s.snapshot(ctx, req.TargetPath, s.Audit != nil || s.Events != nil && err == nil)
+	s.audit(ctx, applyReq, before, after, err)
 	if err != nil {
-		zap.L().Error("JSON encoding failed",
-			zap.Error(err),
-		)
-		return &pb.ApplyACLResponse{Success: false, Message: "JSON encoding failed"}, nil
+		if entry.Default && isNotDirectory(err) {
+			return nil, defaultEntryError(req)
+		}
+		return nil, backendError(ctx, err)
 	}
 
-	/* create a unix socket connection to communicate with ACL core daemon */
-	conn, err := net.Dial("unix", socketPath)
+	s.publishApplied(ctx, applyReq, after)
+
+	/* send response via gRPC */
+	return &pb.ApplyACLResponse{
+		Success: true,
+		Message: message,
+	}, nil
+}
+
+/* handler for expanding a group to its members */
+func (s *ACLServer) ExpandGroup(ctx context.Context, req *pb.ExpandGroupRequest) (*pb.ExpandGroupResponse, error) {
+	group, err := s.Directory.LookupGroup(ctx, req.Group)
 	if err != nil {
-		zap.L().Error("Failed to connect to root daemon",
-			zap.Error(err),
-		)
-		return &pb.ApplyACLResponse{Success: false, Message: "Failed to connect to root daemon"}, nil
+		return nil, directoryError(ctx, err)
 	}
-	defer conn.Close()
 
-	/* write the ACL JSON data into the connection */
-	_, err = conn.Write(acldata)
+	members, err := s.Directory.GroupMembers(ctx, req.Group)
 	if err != nil {
-		zap.L().Error("Failed to write to socket",
-			zap.Error(err),
-		)
-		return &pb.ApplyACLResponse{Success: false, Message: "Failed to write to socket"}, nil
+		return nil, directoryError(ctx, err)
 	}
 
-	/* max 1KB response from ACL core daemon (CHANGE IF NEEDED) */
-	respBuf := make([]byte, 1024)
-	aclResp, err := conn.Read(respBuf)
+	return &pb.ExpandGroupResponse{
+		Group:   group.Name,
+		Gid:     group.GID,
+		Members: members,
+	}, nil
+}
+
+/* handler for reading the access and default ACL of a path */
+func (s *ACLServer) GetACL(ctx context.Context, req *pb.GetACLRequest) (*pb.GetACLResponse, error) {
+	if err := validateGetRequest(req); err != nil {
+		return nil, err
+	}
+
+	acl, err := s.Backend.Get(ctx, req.Path)
 	if err != nil {
-		zap.L().Error("Failed to read from socket",
-			zap.Error(err),
-		)
-		return &pb.ApplyACLResponse{Success: false, Message: "Failed to read from socket"}, nil
+		return nil, backendError(ctx, err)
 	}
 
-	/* create the response for returning back */
-	var response struct {
-		Success bool   `json:"success"`
-		Message string `json:"message"`
+	access, err := s.entryInfos(ctx, acl.Access)
+	if err != nil {
+		return nil, directoryError(ctx, err)
 	}
 
-	/* unmarshal JSON response */
-	err = json.Unmarshal(respBuf[:aclResp], &response)
+	defaults, err := s.entryInfos(ctx, acl.Default)
 	if err != nil {
-		zap.L().Error("Failed to parse response",
-			zap.Error(err),
-		)
-		return &pb.ApplyACLResponse{Success: false, Message: "Failed to parse response"}, nil
+		return nil, directoryError(ctx, err)
 	}
 
-	/* send response via gRPC */
-	return &pb.ApplyACLResponse{
-		Success: response.Success,
-		Message: response.Message,
+	return &pb.GetACLResponse{
+		Path:           req.Path,
+		Access:         access,
+		DefaultEntries: defaults,
 	}, nil
 }
+
+/* handler streaming the audit history matching the request, one page per call */
+func (s *ACLServer) QueryAudit(req *pb.QueryAuditRequest, stream pb.ACLService_QueryAuditServer) error {
+	ctx := stream.Context()
+
+	query, err := validateQueryAuditRequest(req)
+	if err != nil {
+		return err
+	}
+
+	if s.Audit == nil {
+		return statusError(codes.FailedPrecondition, ReasonAuditDisabled, "audit log is disabled", nil)
+	}
+
+	records, more, err := s.Audit.Query(query)
+	if err != nil {
+		return auditError(ctx, err)
+	}
+
+	for i, record := range records {
+		info, err := s.auditRecordInfo(ctx, record)
+		if err != nil {
+			return directoryError(ctx, err)
+		}
+
+		resp := &pb.QueryAuditResponse{Record: info}
+		if more && i == len(records)-1 {
+			resp.NextPageToken = encodePageToken(record.Seq)
+		}
+
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/* handler streaming ACL changes under a path prefix until the client goes away */
+func (s *ACLServer) WatchACLChanges(req *pb.WatchACLChangesRequest, stream pb.ACLService_WatchACLChangesServer) error {
+	ctx := stream.Context()
+
+	if err := validateWatchRequest(req); err != nil {
+		return err
+	}
+
+	if s.Events == nil {
+		return statusError(codes.FailedPrecondition, ReasonEventsDisabled, "change events are disabled", nil)
+	}
+
+	sub, err := s.Events.Subscribe(req.PathPrefix, req.ResumeToken)
+	if err != nil {
+		return eventsError(err)
+	}
+
+	/* headers tell the client the watch is established */
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	for {
+		e, err := sub.Next(ctx)
+		if err != nil {
+			return eventsError(err)
+		}
+
+		event, err := s.changeEvent(ctx, e)
+		if err != nil {
+			return directoryError(ctx, err)
+		}
+
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+}
+
+/* handler returning the last drift report, or the report of a scan run now */
+func (s *ACLServer) GetDriftReport(ctx context.Context, req *pb.GetDriftReportRequest) (*pb.DriftReport, error) {
+	if err := validateDriftReportRequest(req); err != nil {
+		return nil, err
+	}
+
+	if s.Drift == nil {
+		return nil, statusError(codes.FailedPrecondition, ReasonDriftDisabled, "drift detection is disabled", nil)
+	}
+
+	report := s.Drift.Last()
+	if req.Rescan {
+		var err error
+		if report, err = s.Drift.Scan(ctx); err != nil {
+			return nil, driftError(ctx, err)
+		}
+	}
+	if report == nil {
+		return nil, statusError(codes.NotFound, ReasonDriftReportNotReady, "no drift scan finished yet, ask for a rescan", nil)
+	}
+
+	info, err := s.driftReportInfo(ctx, report, req.PathPrefix)
+	if err != nil {
+		return nil, directoryError(ctx, err)
+	}
+	return info, nil
+}
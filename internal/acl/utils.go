@@ -1,21 +1,118 @@
 package acl
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strconv"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/directory"
 	pb "github.com/PythonHacker24/linux-acl-management-aclapi/internal/grpcserver/protos"
 )
 
-/* builds ACL entry for gRPC handler */
-func buildACLEntry(entry *pb.ACLEntry) string {
-	prefix := ""
-	if entry.IsDefault {
-		prefix = "default:"
+/* returned when the directory resolves a principal to a name that is not safe in an ACL entry */
+var errUnsafeName = errors.New("directory returned an unsafe principal name")
+
+/*
+builds the structured ACL entry sent to aclcore
+the entry must have passed validateEntry, named entities are resolved to uid/gid
+*/
+func (s *ACLServer) buildCoreEntry(ctx context.Context, entry *pb.ACLEntryV2) (aclmodel.Entry, error) {
+	coreEntry := aclmodel.Entry{
+		Tag:     entityTypeTags[entry.EntityType],
+		Perms:   aclmodel.Perm(entry.Permissions),
+		Default: entry.IsDefault,
+	}
+
+	/* an empty entity selects the owner entries (user::, group::) */
+	if entry.Entity == "" {
+		switch coreEntry.Tag {
+		case aclmodel.TagUser:
+			coreEntry.Tag = aclmodel.TagUserObj
+		case aclmodel.TagGroup:
+			coreEntry.Tag = aclmodel.TagGroupObj
+		}
+	}
+
+	switch coreEntry.Tag {
+	case aclmodel.TagUser:
+		user, err := s.Directory.LookupUser(ctx, entry.Entity)
+		if err != nil {
+			return coreEntry, err
+		}
+		coreEntry.Qualifier = user.Name
+		coreEntry.ID = &user.UID
+	case aclmodel.TagGroup:
+		group, err := s.Directory.LookupGroup(ctx, entry.Entity)
+		if err != nil {
+			return coreEntry, err
+		}
+		coreEntry.Qualifier = group.Name
+		coreEntry.ID = &group.GID
 	}
 
-	entity := entry.Entity
-	if entity == "" {
-		entity = "user"
+	/* directory contents are not trusted either */
+	if coreEntry.Tag.Named() {
+		if err := aclmodel.ValidateName(coreEntry.Qualifier); err != nil {
+			return coreEntry, fmt.Errorf("%w: %w", errUnsafeName, err)
+		}
 	}
 
-	return fmt.Sprintf("%s%s:%s:%s", prefix, entry.EntityType, entity, entry.Permissions)
+	return coreEntry, nil
+}
+
+/* converts entries read from disk to their API form, naming the principals of named entries */
+func (s *ACLServer) entryInfos(ctx context.Context, entries []aclmodel.Entry) ([]*pb.ACLEntryInfo, error) {
+	infos := make([]*pb.ACLEntryInfo, 0, len(entries))
+	for _, entry := range entries {
+		info := &pb.ACLEntryInfo{
+			EntityType:  entryInfoTypes[entry.Tag],
+			Id:          entry.ID,
+			Permissions: uint32(entry.Perms & aclmodel.PermRWX),
+			IsDefault:   entry.Default,
+		}
+
+		if entry.Tag.Named() {
+			name, err := s.principalName(ctx, &entry)
+			if err != nil {
+				return nil, err
+			}
+			info.Entity = name
+		}
+
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+/* name of the principal of a named entry, its numeric id if the directory does not know it */
+func (s *ACLServer) principalName(ctx context.Context, entry *aclmodel.Entry) (string, error) {
+	if entry.Qualifier != "" || entry.ID == nil {
+		return entry.Qualifier, nil
+	}
+
+	id := strconv.FormatUint(uint64(*entry.ID), 10)
+
+	var name string
+	var err error
+	if entry.Tag == aclmodel.TagUser {
+		var user *directory.User
+		if user, err = s.Directory.LookupUser(ctx, id); err == nil {
+			name = user.Name
+		}
+	} else {
+		var group *directory.Group
+		if group, err = s.Directory.LookupGroup(ctx, id); err == nil {
+			name = group.Name
+		}
+	}
+
+	switch {
+	case errors.Is(err, directory.ErrNotFound):
+		return id, nil
+	case err != nil:
+		return "", err
+	}
+	return name, nil
 }
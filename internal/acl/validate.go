@@ -0,0 +1,247 @@
+package acl
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/audit"
+	pb "github.com/PythonHacker24/linux-acl-management-aclapi/internal/grpcserver/protos"
+)
+
+/* collects field violations of a request */
+type violations []*errdetails.BadRequest_FieldViolation
+
+/* records a violation for field */
+func (v *violations) add(field, format string, args ...any) {
+	*v = append(*v, &errdetails.BadRequest_FieldViolation{
+		Field:       field,
+		Description: fmt.Sprintf(format, args...),
+	})
+}
+
+/* reports whether a violation was already recorded for field */
+func (v violations) has(field string) bool {
+	for _, violation := range v {
+		if violation.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+/* converts the violations into an InvalidArgument status (nil if there are none) */
+func (v violations) err() error {
+	if len(v) == 0 {
+		return nil
+	}
+
+	st := status.New(codes.InvalidArgument, "invalid ACL request")
+	detailed, err := st.WithDetails(
+		&errdetails.ErrorInfo{Reason: ReasonInvalidArgument, Domain: ErrorDomain},
+		&errdetails.BadRequest{FieldViolations: v},
+	)
+	if err != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
+
+/*
+validates an ApplyACLRequest, returning codes.InvalidArgument with field violations
+the legacy entry is converted, so the returned typed entry is all later stages need
+*/
+func validateApplyRequest(req *pb.ApplyACLRequest) (*pb.ACLEntryV2, error) {
+	var v violations
+
+	validatePath(&v, "target_path", req.TargetPath)
+
+	var (
+		entry *pb.ACLEntryV2
+		field string
+	)
+
+	switch {
+	case req.Entry != nil && req.EntryV2 != nil:
+		v.add("entry_v2", "entry and entry_v2 are mutually exclusive")
+		return nil, v.err()
+	case req.EntryV2 != nil:
+		entry, field = req.EntryV2, "entry_v2"
+	case req.Entry != nil:
+		field = "entry"
+		entry = entryFromLegacy(&v, field, req.Entry)
+	default:
+		v.add("entry", "ACL entry is required")
+		return nil, v.err()
+	}
+
+	validateEntry(&v, field, entry)
+
+	/* default ACLs only exist on directories, best effort: the path may change until applied */
+	if entry.IsDefault && !v.has("target_path") && notDirectory(req.TargetPath) {
+		v.add(field+".is_default", notDirectoryMessage)
+	}
+
+	return entry, v.err()
+}
+
+const notDirectoryMessage = "default ACL entries can only be set on directories"
+
+/* reports whether path exists and is no directory, symbolic links are left to the backend which follows them */
+func notDirectory(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && !info.IsDir() && info.Mode().Type() != fs.ModeSymlink
+}
+
+/*
+InvalidArgument error of a default entry the backend refused to set because
+the target is not a directory (ENOTDIR), the same as the validation would give
+*/
+func defaultEntryError(req *pb.ApplyACLRequest) error {
+	field := "entry"
+	if req.EntryV2 != nil {
+		field = "entry_v2"
+	}
+
+	var v violations
+	v.add(field+".is_default", notDirectoryMessage)
+	return v.err()
+}
+
+/* validates a GetACLRequest, returning codes.InvalidArgument with field violations */
+func validateGetRequest(req *pb.GetACLRequest) error {
+	var v violations
+	validatePath(&v, "path", req.Path)
+	return v.err()
+}
+
+/* page sizes of QueryAudit */
+const (
+	defaultAuditPageSize = 100
+	maxAuditPageSize     = 1000
+)
+
+/* validates a QueryAuditRequest and converts it into an audit log query */
+func validateQueryAuditRequest(req *pb.QueryAuditRequest) (*audit.Query, error) {
+	var v violations
+
+	query := &audit.Query{
+		PathPrefix: req.PathPrefix,
+		Entity:     req.Entity,
+		Caller:     req.Caller,
+		EndUser:    req.EndUser,
+		TxnID:      req.TransactionId,
+		Limit:      int(req.PageSize),
+	}
+
+	if req.PathPrefix != "" {
+		validatePath(&v, "path_prefix", req.PathPrefix)
+	}
+
+	if req.Since != nil {
+		if err := req.Since.CheckValid(); err != nil {
+			v.add("since", "%s", err.Error())
+		}
+		query.Since = req.Since.AsTime()
+	}
+	if req.Until != nil {
+		if err := req.Until.CheckValid(); err != nil {
+			v.add("until", "%s", err.Error())
+		}
+		query.Until = req.Until.AsTime()
+	}
+	if req.Since != nil && req.Until != nil && !query.Since.Before(query.Until) {
+		v.add("until", "until must be after since")
+	}
+
+	switch {
+	case req.PageSize == 0:
+		query.Limit = defaultAuditPageSize
+	case req.PageSize > maxAuditPageSize:
+		v.add("page_size", "page size must not exceed %d", maxAuditPageSize)
+	}
+
+	if req.PageToken != "" {
+		after, err := decodePageToken(req.PageToken)
+		if err != nil {
+			v.add("page_token", "invalid page token")
+		}
+		query.After = after
+	}
+
+	return query, v.err()
+}
+
+/* validates a WatchACLChangesRequest, returning codes.InvalidArgument with field violations */
+func validateWatchRequest(req *pb.WatchACLChangesRequest) error {
+	var v violations
+	if req.PathPrefix != "" {
+		validatePath(&v, "path_prefix", req.PathPrefix)
+	}
+	return v.err()
+}
+
+/* validates a drift report request */
+func validateDriftReportRequest(req *pb.GetDriftReportRequest) error {
+	var v violations
+	if req.PathPrefix != "" {
+		validatePath(&v, "path_prefix", req.PathPrefix)
+	}
+	return v.err()
+}
+
+/* validates a path sent by a client */
+func validatePath(v *violations, field, path string) {
+	switch {
+	case path == "":
+		v.add(field, "path is required")
+	case !filepath.IsAbs(path):
+		v.add(field, "path must be absolute")
+	case strings.ContainsRune(path, 0):
+		v.add(field, "path must not contain NUL bytes")
+	}
+}
+
+/* validates the fields of a single typed ACL entry */
+func validateEntry(v *violations, field string, entry *pb.ACLEntryV2) {
+	switch _, ok := actionNames[entry.Action]; {
+	case v.has(field + ".action"):
+		/* already reported while converting the legacy entry */
+	case entry.Action == pb.Action_ACTION_UNSPECIFIED:
+		v.add(field+".action", "action is required")
+	case !ok:
+		v.add(field+".action", "unknown action %d", entry.Action)
+	}
+
+	switch entry.EntityType {
+	case pb.EntityType_ENTITY_TYPE_UNSPECIFIED:
+		if !v.has(field + ".entity_type") {
+			v.add(field+".entity_type", "entity type is required")
+		}
+	case pb.EntityType_ENTITY_TYPE_USER, pb.EntityType_ENTITY_TYPE_GROUP:
+		/* an empty entity is the owning user or group */
+		if entry.Entity != "" {
+			if err := aclmodel.ValidateName(entry.Entity); err != nil {
+				v.add(field+".entity", "%s", err.Error())
+			}
+		}
+	case pb.EntityType_ENTITY_TYPE_MASK, pb.EntityType_ENTITY_TYPE_OTHER:
+		if entry.Entity != "" {
+			v.add(field+".entity", "entity must be empty for %s entries", entityTypeTags[entry.EntityType])
+		}
+	default:
+		v.add(field+".entity_type", "unknown entity type %d", entry.EntityType)
+	}
+
+	if entry.Permissions&^permissionMask != 0 {
+		v.add(field+".permissions", "unknown permission bits 0x%x", entry.Permissions&^permissionMask)
+	}
+}
@@ -0,0 +1,922 @@
+package aclcore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+/* frame with a raw header and payload */
+func rawFrame(size uint32, payload string) []byte {
+	frame := binary.BigEndian.AppendUint32(nil, size)
+	return append(frame, payload...)
+}
+
+func TestReadFrame(t *testing.T) {
+	valid := `{"id":7,"success":true,"message":"ok"}`
+
+	tests := []struct {
+		name     string
+		data     []byte
+		wantErr  error
+		protocol bool
+	}{
+		{name: "valid", data: rawFrame(uint32(len(valid)), valid)},
+		{name: "empty stream", data: nil, wantErr: io.EOF},
+		{name: "truncated header", data: []byte{0, 0}, wantErr: io.ErrUnexpectedEOF},
+		{name: "truncated payload", data: rawFrame(uint32(len(valid)), valid[:10]), wantErr: io.ErrUnexpectedEOF},
+		{name: "oversize frame", data: rawFrame(MaxFrameSize+1, ""), protocol: true},
+		{name: "invalid JSON", data: rawFrame(9, `{"id":7,}`), protocol: true},
+		{name: "wrong JSON type", data: rawFrame(7, `"hello"`), protocol: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var resp Response
+			err := ReadFrame(bytes.NewReader(test.data), &resp)
+
+			var protoErr *ProtocolError
+			switch {
+			case test.protocol:
+				if !errors.As(err, &protoErr) {
+					t.Fatalf("ReadFrame error = %v, want a ProtocolError", err)
+				}
+			case err != test.wantErr:
+				t.Fatalf("ReadFrame error = %v, want %v", err, test.wantErr)
+			case err == nil && (resp.ID != 7 || !resp.Success || resp.Message != "ok"):
+				t.Fatalf("ReadFrame = %+v", resp)
+			}
+		})
+	}
+}
+
+func TestWriteFrame(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteFrame(&buf, &Request{ID: 3, Op: OpGet, Payload: json.RawMessage(`{"path":"/srv"}`)}); err != nil {
+		t.Fatalf("WriteFrame: %v", err)
+	}
+
+	if size := binary.BigEndian.Uint32(buf.Bytes()); int(size) != buf.Len()-4 {
+		t.Fatalf("header announces %d bytes, payload has %d", size, buf.Len()-4)
+	}
+
+	var req Request
+	if err := ReadFrame(&buf, &req); err != nil || req.ID != 3 || req.Op != OpGet || string(req.Payload) != `{"path":"/srv"}` {
+		t.Fatalf("ReadFrame = %+v, %v, want the written request", req, err)
+	}
+
+	/* frames aclcore would refuse are not sent */
+	buf.Reset()
+	huge := &PathRequest{Path: strings.Repeat("a", MaxFrameSize)}
+	if err := WriteFrame(&buf, huge); err == nil || buf.Len() != 0 {
+		t.Fatalf("WriteFrame of an oversize frame = %v, wrote %d bytes", err, buf.Len())
+	}
+
+	if err := WriteFrame(&buf, func() {}); err == nil {
+		t.Fatal("WriteFrame of an unencodable value succeeded")
+	}
+}
+
+/* returns the response frames sent back for a request, a nil frame drops the connection */
+type handler func(req *Request) []*Response
+
+/* answers hello with version 1 and capabilities, and everything else with handle */
+func helloHandler(capabilities []string, handle handler) handler {
+	return func(req *Request) []*Response {
+		if req.Op == OpHello {
+			payload, _ := json.Marshal(&HelloReply{Server: "test", Version: 1, Capabilities: capabilities})
+			return []*Response{{ID: req.ID, Success: true, Payload: payload}}
+		}
+		return handle(req)
+	}
+}
+
+/* answers every request with a success */
+func succeed(req *Request) []*Response {
+	return []*Response{{ID: req.ID, Success: true, Message: "done"}}
+}
+
+/* serves handle on the peer end of a pipe until it is closed, returning the requests received */
+func servePipe(peer net.Conn, handle handler) <-chan *Request {
+	received := make(chan *Request, 64)
+	go func() {
+		defer close(received)
+		defer peer.Close()
+
+		for {
+			var req Request
+			if err := ReadFrame(peer, &req); err != nil {
+				return
+			}
+			received <- &req
+
+			for _, resp := range handle(&req) {
+				if resp == nil {
+					return
+				}
+				if err := WriteFrame(peer, resp); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return received
+}
+
+/* handshaken connection to a peer serving handle */
+func pipeConn(t *testing.T, capabilities []string, handle handler) (*conn, <-chan *Request) {
+	t.Helper()
+
+	client, peer := net.Pipe()
+	received := servePipe(peer, helloHandler(capabilities, handle))
+	t.Cleanup(func() { client.Close() })
+
+	cn := newConn(client, nil)
+	if err := cn.handshake(context.Background()); err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+	<-received
+
+	return cn, received
+}
+
+/* waits for the next request received by a peer */
+func nextRequest(t *testing.T, received <-chan *Request) *Request {
+	t.Helper()
+
+	select {
+	case req, ok := <-received:
+		if !ok {
+			t.Fatal("peer closed the connection")
+		}
+		return req
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a request")
+	}
+	return nil
+}
+
+func TestHandshake(t *testing.T) {
+	cn, _ := pipeConn(t, []string{CapStructuredEntries, CapCancel}, succeed)
+	if cn.version != 1 || !cn.has(CapCancel) || cn.has(CapStreaming) {
+		t.Fatalf("negotiated version %d and capabilities %v", cn.version, cn.capabilities)
+	}
+
+	/* versions this client does not speak */
+	client, peer := net.Pipe()
+	defer client.Close()
+	servePipe(peer, func(req *Request) []*Response {
+		payload, _ := json.Marshal(&HelloReply{Version: MaxProtocolVersion + 1})
+		return []*Response{{ID: req.ID, Success: true, Payload: payload}}
+	})
+
+	var protoErr *ProtocolError
+	if err := newConn(client, nil).handshake(context.Background()); !errors.As(err, &protoErr) {
+		t.Fatalf("handshake with an unsupported version error = %v, want a ProtocolError", err)
+	}
+}
+
+func TestExchangeResponses(t *testing.T) {
+	tests := []struct {
+		name   string
+		handle handler
+		check  func(error) bool
+	}{
+		{
+			name:   "success",
+			handle: succeed,
+			check:  func(err error) bool { return err == nil },
+		},
+		{
+			name: "mismatched response ID",
+			handle: func(req *Request) []*Response {
+				return []*Response{{ID: req.ID + 1, Success: true}}
+			},
+			check: func(err error) bool { return errors.As(err, new(*ProtocolError)) },
+		},
+		{
+			name: "operation failure",
+			handle: func(req *Request) []*Response {
+				return []*Response{{ID: req.ID, Message: "setxattr failed", Errno: 13}}
+			},
+			check: func(err error) bool {
+				var opErr *OperationError
+				return errors.As(err, &opErr) && opErr.Errno == 13 && opErr.Message == "setxattr failed"
+			},
+		},
+		{
+			name: "failure in the middle of a stream",
+			handle: func(req *Request) []*Response {
+				return []*Response{{ID: req.ID, Success: true, More: true}, {ID: req.ID, More: true}}
+			},
+			check: func(err error) bool { return errors.As(err, new(*ProtocolError)) },
+		},
+		{
+			name:   "connection dropped",
+			handle: func(req *Request) []*Response { return []*Response{nil} },
+			check:  func(err error) bool { return errors.Is(err, ErrUnavailable) },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cn, _ := pipeConn(t, nil, test.handle)
+			if _, err := cn.roundTrip(context.Background(), OpGet, &PathRequest{Path: "/srv"}); !test.check(err) {
+				t.Fatalf("roundTrip error = %v", err)
+			}
+		})
+	}
+}
+
+/* client whose connections are pipes to peers serving handle, counting the dials */
+func pipeClient(t *testing.T, opts Options, capabilities []string, handle handler) (*Client, *atomic.Int64) {
+	t.Helper()
+
+	c := NewClient(opts)
+	t.Cleanup(c.Close)
+
+	dials := new(atomic.Int64)
+	c.pool.close()
+	c.pool = newPool(max(opts.PoolSize, 1), time.Minute, func(ctx context.Context) (*conn, error) {
+		dials.Add(1)
+
+		client, peer := net.Pipe()
+		servePipe(peer, helloHandler(capabilities, handle))
+
+		cn := newConn(client, nil)
+		if err := cn.handshake(ctx); err != nil {
+			client.Close()
+			return nil, err
+		}
+		return cn, nil
+	})
+
+	return c, dials
+}
+
+func TestMissingCapability(t *testing.T) {
+	c, _ := pipeClient(t, Options{}, []string{CapStructuredEntries}, succeed)
+
+	if _, err := c.Apply(context.Background(), &ApplyRequest{Path: "/srv"}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	/* walks need streaming, which this aclcore does not announce */
+	var protoErr *ProtocolError
+	err := c.Walk(context.Background(), "/srv", func(*WalkEntry) error { return nil })
+	if !errors.As(err, &protoErr) || !strings.Contains(protoErr.Reason, CapStreaming) {
+		t.Fatalf("Walk error = %v, want a ProtocolError naming %s", err, CapStreaming)
+	}
+
+	/* the connection was not used, it stays pooled */
+	if inUse, idle := c.pool.stats(); inUse != 0 || idle != 1 {
+		t.Fatalf("pool has %d connections in use and %d idle, want 0 and 1", inUse, idle)
+	}
+}
+
+/* pool dialing unconnected pipes, counting the dials */
+func fakePool(t *testing.T, size int, idleTimeout time.Duration) (*pool, *atomic.Int64) {
+	t.Helper()
+
+	dials := new(atomic.Int64)
+	p := newPool(size, idleTimeout, func(ctx context.Context) (*conn, error) {
+		dials.Add(1)
+		client, peer := net.Pipe()
+		t.Cleanup(func() { peer.Close() })
+		return newConn(client, nil), nil
+	})
+	t.Cleanup(p.close)
+
+	return p, dials
+}
+
+/* takes a connection from p, failing the test on errors */
+func mustGet(t *testing.T, p *pool) (*conn, bool) {
+	t.Helper()
+
+	cn, reused, err := p.get(context.Background())
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	return cn, reused
+}
+
+func TestPoolBound(t *testing.T) {
+	p, dials := fakePool(t, 2, time.Minute)
+
+	first, _ := mustGet(t, p)
+	mustGet(t, p)
+
+	/* both slots are taken */
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := p.get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("get on a full pool error = %v, want a timeout", err)
+	}
+
+	/* discarding a connection frees its slot */
+	p.discard(first)
+	if _, reused := mustGet(t, p); reused {
+		t.Fatal("got a discarded connection back")
+	}
+
+	if inUse, idle := p.stats(); inUse != 2 || idle != 0 || dials.Load() != 3 {
+		t.Fatalf("pool has %d connections in use and %d idle after %d dials, want 2, 0 and 3", inUse, idle, dials.Load())
+	}
+}
+
+func TestPoolReusesLastConnection(t *testing.T) {
+	p, dials := fakePool(t, 2, time.Minute)
+
+	first, _ := mustGet(t, p)
+	second, _ := mustGet(t, p)
+	p.put(first)
+	p.put(second)
+
+	if cn, reused := mustGet(t, p); cn != second || !reused {
+		t.Fatal("get did not return the most recently used connection")
+	}
+	if cn, reused := mustGet(t, p); cn != first || !reused {
+		t.Fatal("get did not return the remaining idle connection")
+	}
+	if dials.Load() != 2 {
+		t.Fatalf("pool dialed %d times, want 2", dials.Load())
+	}
+}
+
+func TestPoolIdleExpiry(t *testing.T) {
+	p, dials := fakePool(t, 1, 20*time.Millisecond)
+
+	cn, _ := mustGet(t, p)
+	p.put(cn)
+
+	/* the reaper closes connections idle for longer than the timeout */
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, idle := p.stats(); idle == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("idle connection not reaped")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if _, reused := mustGet(t, p); reused || dials.Load() != 2 {
+		t.Fatalf("get reused an expired connection (%d dials)", dials.Load())
+	}
+}
+
+func TestPoolCloseWakesWaiters(t *testing.T) {
+	p, _ := fakePool(t, 1, time.Minute)
+	cn, _ := mustGet(t, p)
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := p.get(context.Background())
+		done <- err
+	}()
+
+	p.close()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrUnavailable) || !errors.Is(err, errPoolClosed) {
+			t.Fatalf("get error = %v, want the pool closed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("get still waiting after close")
+	}
+
+	/* connections returned after close are closed too */
+	p.put(cn)
+	if _, idle := p.stats(); idle != 0 {
+		t.Fatalf("closed pool keeps %d idle connections", idle)
+	}
+}
+
+/* never answers anything but hello */
+func hang(req *Request) []*Response {
+	return nil
+}
+
+func TestExchangeDeadlines(t *testing.T) {
+	/* the peer never reads, the request cannot be written */
+	client, peer := net.Pipe()
+	defer client.Close()
+	defer peer.Close()
+
+	cn := newConn(client, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := cn.roundTrip(ctx, OpGet, &PathRequest{Path: "/srv"}); !errors.Is(err, context.DeadlineExceeded) || !cn.unsent {
+		t.Fatalf("roundTrip error = %v (unsent %v), want a timeout before the request was written", err, cn.unsent)
+	}
+
+	/* the peer reads the request and never answers */
+	cn, received := pipeConn(t, nil, hang)
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := cn.roundTrip(ctx, OpGet, &PathRequest{Path: "/srv"}); !errors.Is(err, context.DeadlineExceeded) || cn.unsent {
+		t.Fatalf("roundTrip error = %v (unsent %v), want a timeout after the request was written", err, cn.unsent)
+	}
+	nextRequest(t, received)
+
+	/* cancellation without a deadline interrupts the read too */
+	cn, _ = pipeConn(t, nil, hang)
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := cn.roundTrip(ctx, OpGet, &PathRequest{Path: "/srv"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("roundTrip error = %v, want the cancellation", err)
+	}
+}
+
+func TestCancelFrame(t *testing.T) {
+	tests := []struct {
+		name         string
+		op           Op
+		capabilities []string
+		cancelled    bool
+	}{
+		{name: "cancellable operation", op: OpApply, capabilities: []string{CapCancel}, cancelled: true},
+		{name: "short operation", op: OpGet, capabilities: []string{CapCancel}},
+		{name: "cancel not announced", op: OpApply},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cn, received := pipeConn(t, test.capabilities, hang)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			if _, err := cn.roundTrip(ctx, test.op, &PathRequest{Path: "/srv"}); !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("roundTrip error = %v, want a timeout", err)
+			}
+
+			req := nextRequest(t, received)
+			if !test.cancelled {
+				/* nothing is written after the request */
+				cn.Close()
+				if req, ok := <-received; ok {
+					t.Fatalf("peer received %s after the request, want nothing", req.Op)
+				}
+				return
+			}
+
+			notice := nextRequest(t, received)
+			var payload Cancel
+			if err := json.Unmarshal(notice.Payload, &payload); err != nil || notice.Op != OpCancel || payload.RequestID != req.ID {
+				t.Fatalf("peer received %s %s, want a cancel of request %d", notice.Op, notice.Payload, req.ID)
+			}
+		})
+	}
+}
+
+func TestCancelDiscardsConnection(t *testing.T) {
+	c, dials := pipeClient(t, Options{}, []string{CapStructuredEntries, CapCancel}, func(req *Request) []*Response {
+		if req.Op == OpApply {
+			return hang(req)
+		}
+		return succeed(req)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.Apply(ctx, &ApplyRequest{Path: "/srv"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Apply error = %v, want a timeout", err)
+	}
+
+	/* a late response would be read by the next request, the connection is not reused */
+	if inUse, idle := c.pool.stats(); inUse != 0 || idle != 0 {
+		t.Fatalf("pool has %d connections in use and %d idle after a cancel, want none", inUse, idle)
+	}
+	c.Get(context.Background(), "/srv")
+	if dials.Load() != 2 {
+		t.Fatalf("pool dialed %d times, want a new connection after the cancel", dials.Load())
+	}
+}
+
+func TestLateCancelDiscardsConnection(t *testing.T) {
+	c, dials := pipeClient(t, Options{}, []string{CapStructuredEntries}, succeed)
+
+	/* cancelled while the last response is handled, the interrupt runs as the exchange ends */
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := c.send(ctx, OpGet, &PathRequest{Path: "/srv"}, func(*Response) error {
+		cancel()
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	/* its deadline is in the past, the next request must not get it */
+	if inUse, idle := c.pool.stats(); inUse != 0 || idle != 0 {
+		t.Fatalf("pool has %d connections in use and %d idle after a late cancel, want none", inUse, idle)
+	}
+	ignore := func(*Response) error { return nil }
+	if err := c.send(context.Background(), OpGet, &PathRequest{Path: "/srv"}, ignore, nil); err != nil {
+		t.Fatalf("send after a late cancel: %v", err)
+	}
+	if dials.Load() != 2 {
+		t.Fatalf("pool dialed %d times, want a new connection after the late cancel", dials.Load())
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	dropped := &unavailableError{op: "read", err: io.EOF}
+	refused := &undeliveredError{err: &unavailableError{op: "dial", err: syscall.ECONNREFUSED}}
+
+	tests := []struct {
+		name string
+		op   Op
+		err  error
+		want bool
+	}{
+		{name: "read after a dropped connection", op: OpGet, err: dropped, want: true},
+		{name: "apply after a dropped connection", op: OpApply, err: dropped},
+		{name: "apply never sent", op: OpApply, err: refused, want: true},
+		{name: "walk after a dropped connection", op: OpWalk, err: dropped},
+		{name: "walk never sent", op: OpWalk, err: refused, want: true},
+		{name: "circuit open", op: OpGet, err: ErrCircuitOpen},
+		{name: "circuit open before an apply", op: OpApply, err: &undeliveredError{err: ErrCircuitOpen}},
+		{name: "operation failure", op: OpGet, err: &OperationError{Errno: syscall.EIO}},
+		{name: "timeout", op: OpGet, err: interrupted("get", context.DeadlineExceeded)},
+		{name: "timeout before an apply was sent", op: OpApply, err: &undeliveredError{err: interrupted("acquire connection", context.DeadlineExceeded)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := retryable(test.op, test.err); got != test.want {
+				t.Fatalf("retryable(%s, %v) = %v, want %v", test.op, test.err, got, test.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 40 * time.Millisecond}
+	caps := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 40 * time.Millisecond}
+
+	for i, limit := range caps {
+		attempt := i + 1
+		for range 100 {
+			if delay := policy.backoff(attempt); delay <= 0 || delay > limit {
+				t.Fatalf("backoff(%d) = %v, want a delay in (0, %v]", attempt, delay, limit)
+			}
+		}
+	}
+}
+
+func TestRetries(t *testing.T) {
+	opts := Options{Retry: RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}, BreakerThreshold: 100}
+
+	var sent atomic.Int64
+	drop := func(req *Request) []*Response {
+		sent.Add(1)
+		return []*Response{nil}
+	}
+
+	/* reads are repeated on a new connection */
+	c, dials := pipeClient(t, opts, []string{CapStructuredEntries}, drop)
+	if _, err := c.Get(context.Background(), "/srv"); !errors.Is(err, ErrUnavailable) || sent.Load() != 3 || dials.Load() != 3 {
+		t.Fatalf("Get error = %v after %d requests on %d connections, want 3 attempts", err, sent.Load(), dials.Load())
+	}
+
+	/* an apply that reached aclcore may have taken effect, it is not repeated */
+	sent.Store(0)
+	c, _ = pipeClient(t, opts, []string{CapStructuredEntries}, drop)
+	if _, err := c.Apply(context.Background(), &ApplyRequest{Path: "/srv"}); !errors.Is(err, ErrUnavailable) || sent.Load() != 1 {
+		t.Fatalf("Apply error = %v after %d requests, want a single attempt", err, sent.Load())
+	}
+
+	/* an apply that could not be sent is */
+	c = NewClient(opts)
+	t.Cleanup(c.Close)
+	c.pool.close()
+
+	var failures atomic.Int64
+	c.pool = newPool(1, time.Minute, func(ctx context.Context) (*conn, error) {
+		if failures.Add(1) < 3 {
+			return nil, &unavailableError{op: "dial", err: syscall.ECONNREFUSED}
+		}
+
+		client, peer := net.Pipe()
+		servePipe(peer, helloHandler([]string{CapStructuredEntries}, succeed))
+		cn := newConn(client, nil)
+		return cn, cn.handshake(ctx)
+	})
+	if _, err := c.Apply(context.Background(), &ApplyRequest{Path: "/srv"}); err != nil {
+		t.Fatalf("Apply after two refused dials: %v", err)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	var down atomic.Bool
+	down.Store(true)
+
+	var probes atomic.Int64
+	b := newBreaker(2, 5*time.Millisecond, func(ctx context.Context) error {
+		probes.Add(1)
+		if down.Load() {
+			return &unavailableError{op: "dial", err: syscall.ECONNREFUSED}
+		}
+		return nil
+	})
+	t.Cleanup(b.close)
+
+	failure := &unavailableError{op: "read", err: io.EOF}
+
+	/* answers from aclcore reset the count, timeouts and protocol errors are ignored */
+	b.record(failure)
+	b.record(&OperationError{Errno: syscall.EACCES})
+	b.record(failure)
+	b.record(interrupted("get", context.DeadlineExceeded))
+	b.record(&ProtocolError{Reason: "test"})
+	if state := b.State(); state != BreakerClosed || b.allow() != nil {
+		t.Fatalf("breaker is %s after non-consecutive failures, want closed", state)
+	}
+
+	/* consecutive transport failures open it */
+	b.record(failure)
+	if state := b.State(); state == BreakerClosed || !errors.Is(b.allow(), ErrCircuitOpen) {
+		t.Fatalf("breaker is %s after 2 consecutive failures, want open", state)
+	}
+
+	/* fast failures do not count as further failures */
+	b.record(ErrCircuitOpen)
+
+	/* it stays open while probes fail */
+	deadline := time.Now().Add(time.Second)
+	for probes.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("breaker does not probe aclcore")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if state := b.State(); state == BreakerClosed {
+		t.Fatal("breaker closed while aclcore is down")
+	}
+
+	/* and closes once a probe succeeds */
+	down.Store(false)
+	for b.State() != BreakerClosed {
+		if time.Now().After(deadline) {
+			t.Fatalf("breaker still %s after aclcore came back", b.State())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := b.allow(); err != nil {
+		t.Fatalf("allow after recovery: %v", err)
+	}
+}
+
+/* unix socket listening in a temporary directory, accepting connections until the test ends */
+func listenUnix(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "aclcore.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+
+	return path
+}
+
+func TestVerifySocketFile(t *testing.T) {
+	socket := listenUnix(t)
+	if err := os.Chmod(socket, 0o660); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+
+	regular := filepath.Join(t.TempDir(), "regular")
+	if err := os.WriteFile(regular, nil, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	uid := uint32(os.Getuid())
+	policy := PeerPolicy{CheckSocketFile: true, SocketOwnerUID: uid, SocketMaxMode: 0o660}
+
+	tests := []struct {
+		name   string
+		path   string
+		modify func(p *PeerPolicy)
+		check  func(error) bool
+	}{
+		{name: "trusted", path: socket, check: func(err error) bool { return err == nil }},
+		{
+			name:  "missing socket",
+			path:  filepath.Join(t.TempDir(), "missing.sock"),
+			check: func(err error) bool { return errors.Is(err, ErrUnavailable) },
+		},
+		{name: "not a socket", path: regular, check: isPeerError},
+		{name: "wrong owner", path: socket, modify: func(p *PeerPolicy) { p.SocketOwnerUID = uid + 1 }, check: isPeerError},
+		{name: "mode too open", path: socket, modify: func(p *PeerPolicy) { p.SocketMaxMode = 0o600 }, check: isPeerError},
+		{name: "check disabled", path: regular, modify: func(p *PeerPolicy) { p.CheckSocketFile = false }, check: func(err error) bool { return err == nil }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := policy
+			if test.modify != nil {
+				test.modify(&p)
+			}
+			if err := p.verifySocketFile(test.path); !test.check(err) {
+				t.Fatalf("verifySocketFile error = %v", err)
+			}
+		})
+	}
+}
+
+func isPeerError(err error) bool {
+	var peerErr *PeerError
+	return errors.As(err, &peerErr)
+}
+
+func TestVerifyConn(t *testing.T) {
+	socket := listenUnix(t)
+
+	uid := uint32(os.Getuid())
+	gid := int64(os.Getgid())
+
+	tests := []struct {
+		name   string
+		policy PeerPolicy
+		ok     bool
+	}{
+		{name: "trusted", policy: PeerPolicy{UID: uid, GID: gid}, ok: true},
+		{name: "any gid", policy: PeerPolicy{UID: uid, GID: -1}, ok: true},
+		{name: "wrong uid", policy: PeerPolicy{UID: uid + 1, GID: -1}},
+		{name: "wrong gid", policy: PeerPolicy{UID: uid, GID: gid + 1}},
+		{name: "disabled", policy: PeerPolicy{Disabled: true, UID: uid + 1}, ok: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			netConn, err := net.Dial("unix", socket)
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			defer netConn.Close()
+
+			err = test.policy.verifyConn(netConn)
+			if test.ok && err != nil || !test.ok && !isPeerError(err) {
+				t.Fatalf("verifyConn error = %v", err)
+			}
+		})
+	}
+
+	/* credentials can only be checked on unix sockets */
+	client, peer := net.Pipe()
+	defer client.Close()
+	defer peer.Close()
+
+	policy := PeerPolicy{UID: uid, GID: -1}
+	if err := policy.verifyConn(client); !isPeerError(err) {
+		t.Fatalf("verifyConn on a pipe error = %v, want a PeerError", err)
+	}
+}
+
+func TestSignerMAC(t *testing.T) {
+	signer, err := NewSigner([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+
+	/* computed independently from the documented canonical form */
+	req := &Request{
+		ID:        42,
+		Op:        OpApply,
+		Timestamp: 1700000000000,
+		Nonce:     "00112233445566778899aabbccddeeff",
+		Payload:   json.RawMessage(`{"path":"/srv"}`),
+	}
+	const want = "ce3834e57e5668659ce5dd1a4725f892e91b839dd9493a9996dfc581114138aa"
+	if got := hex.EncodeToString(signer.mac(req)); got != want {
+		t.Fatalf("mac = %s, want %s", got, want)
+	}
+
+	/* every field is covered */
+	changes := map[string]func(r *Request){
+		"id":        func(r *Request) { r.ID++ },
+		"op":        func(r *Request) { r.Op = OpSet },
+		"timestamp": func(r *Request) { r.Timestamp++ },
+		"nonce":     func(r *Request) { r.Nonce = "ff" + r.Nonce[2:] },
+		"payload":   func(r *Request) { r.Payload = json.RawMessage(`{"path":"/srv/"}`) },
+	}
+	for field, change := range changes {
+		changed := *req
+		change(&changed)
+		if hex.EncodeToString(signer.mac(&changed)) == want {
+			t.Errorf("changing the %s keeps the MAC", field)
+		}
+	}
+
+	if _, err := NewSigner(make([]byte, minKeySize-1)); err == nil {
+		t.Fatal("NewSigner accepted a short key")
+	}
+}
+
+func TestSignNonces(t *testing.T) {
+	signer, err := NewSigner(make([]byte, minKeySize))
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for range 1000 {
+		req := &Request{ID: 1, Op: OpGet}
+		if err := signer.sign(req); err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+
+		if len(req.Nonce) != 32 || seen[req.Nonce] {
+			t.Fatalf("nonce %q is malformed or repeated", req.Nonce)
+		}
+		seen[req.Nonce] = true
+
+		if mac, _ := hex.DecodeString(req.MAC); !hmac.Equal(mac, signer.mac(req)) {
+			t.Fatal("signed MAC does not verify")
+		}
+		if age := time.Since(time.UnixMilli(req.Timestamp)); age < 0 || age > time.Minute {
+			t.Fatalf("timestamp is %v old", age)
+		}
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, mode os.FileMode) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("  secret\n"), mode); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatalf("chmod: %v", err)
+		}
+		return path
+	}
+
+	keyFile := write("key", 0o600)
+	readable := write("readable", 0o644)
+
+	credentials := t.TempDir()
+	if err := os.WriteFile(filepath.Join(credentials, "aclcore-hmac"), []byte("credential\n"), 0o400); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		credDir    string
+		keyFile    string
+		credential string
+		want       string
+	}{
+		{name: "key file", keyFile: keyFile, want: "secret"},
+		{name: "credential takes precedence", credDir: credentials, keyFile: keyFile, credential: "aclcore-hmac", want: "credential"},
+		{name: "credential without a name", credDir: credentials, keyFile: keyFile, want: "secret"},
+		{name: "credential only", credDir: credentials, credential: "aclcore-hmac", want: "credential"},
+		{name: "missing credential", credDir: credentials, keyFile: keyFile, credential: "other"},
+		{name: "no credentials directory", keyFile: keyFile, credential: "aclcore-hmac", want: "secret"},
+		{name: "readable by others", keyFile: readable},
+		{name: "nothing configured"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("CREDENTIALS_DIRECTORY", test.credDir)
+
+			key, err := LoadKey(test.keyFile, test.credential)
+			switch {
+			case test.want == "" && err == nil:
+				t.Fatalf("LoadKey = %q, want an error", key)
+			case test.want != "" && (err != nil || string(key) != test.want):
+				t.Fatalf("LoadKey = %q, %v, want %q", key, err, test.want)
+			}
+		})
+	}
+}
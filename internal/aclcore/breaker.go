@@ -0,0 +1,172 @@
+package aclcore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	/* returned without contacting aclcore while the circuit breaker is open */
+	ErrCircuitOpen = &unavailableError{op: "circuit breaker", err: errors.New("circuit open, aclcore is down")}
+)
+
+/* state of the circuit breaker */
+type BreakerState int
+
+const (
+	/* requests flow normally */
+	BreakerClosed BreakerState = iota
+
+	/* aclcore is considered down, requests fail fast */
+	BreakerOpen
+
+	/* a background probe is checking whether aclcore is back */
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+/*
+circuit breaker guarding aclcore
+consecutive transport failures open the circuit, a background probe closes it
+again as soon as aclcore accepts connections
+*/
+type breaker struct {
+	threshold     int
+	probeInterval time.Duration
+	probe         func(ctx context.Context) error
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	done     chan struct{}
+	closed   bool
+}
+
+/* creates a closed breaker */
+func newBreaker(threshold int, probeInterval time.Duration, probe func(ctx context.Context) error) *breaker {
+	return &breaker{
+		threshold:     threshold,
+		probeInterval: probeInterval,
+		probe:         probe,
+		done:          make(chan struct{}),
+	}
+}
+
+/* current state */
+func (b *breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+/* fails fast while aclcore is considered down */
+func (b *breaker) allow() error {
+	if b.State() != BreakerClosed {
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+/* records the outcome of a request */
+func (b *breaker) record(err error) {
+	var opErr *OperationError
+
+	switch {
+	case err == nil, errors.As(err, &opErr):
+		/* aclcore answered */
+		b.mu.Lock()
+		b.failures = 0
+		b.mu.Unlock()
+	case errors.Is(err, ErrUnavailable) && !errors.Is(err, ErrCircuitOpen):
+		b.failure(err)
+	}
+
+	/* timeouts and protocol errors say nothing definite about availability */
+}
+
+/* counts a transport failure, opening the circuit at the threshold */
+func (b *breaker) failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state != BreakerClosed || b.failures < b.threshold || b.closed {
+		return
+	}
+
+	b.state = BreakerOpen
+	zap.L().Warn("aclcore circuit breaker opened",
+		zap.Int("consecutive_failures", b.failures),
+		zap.Error(err),
+	)
+
+	go b.probeLoop()
+}
+
+/* probes aclcore until it is reachable again, then closes the circuit */
+func (b *breaker) probeLoop() {
+	ticker := time.NewTicker(b.probeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+		}
+
+		b.setState(BreakerHalfOpen)
+
+		ctx, cancel := context.WithTimeout(context.Background(), b.probeInterval)
+		err := b.probe(ctx)
+		cancel()
+
+		if err == nil {
+			b.mu.Lock()
+			b.state = BreakerClosed
+			b.failures = 0
+			b.mu.Unlock()
+
+			zap.L().Info("aclcore circuit breaker closed, root daemon reachable again")
+			return
+		}
+
+		zap.L().Debug("aclcore probe failed",
+			zap.Error(err),
+		)
+		b.setState(BreakerOpen)
+	}
+}
+
+/* updates the state */
+func (b *breaker) setState(state BreakerState) {
+	b.mu.Lock()
+	b.state = state
+	b.mu.Unlock()
+}
+
+/* stops probing */
+func (b *breaker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.closed {
+		b.closed = true
+		close(b.done)
+	}
+}
@@ -0,0 +1,390 @@
+package aclcore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/tracing"
+)
+
+/* name announced to aclcore in the hello exchange */
+const clientName = "aclapi"
+
+/*
+client for the aclcore root daemon
+a client is long-lived and shared: it keeps a pool of connections that are
+reused across requests and replaced transparently when aclcore restarts
+*/
+type Client struct {
+	socketPath string
+	pool       *pool
+	timeouts   Timeouts
+	retry      RetryPolicy
+	breaker    *breaker
+	peer       PeerPolicy
+	signer     *Signer
+	observer   Observer
+	poolSize   int
+}
+
+/* client options */
+type Options struct {
+	SocketPath string
+
+	/* maximum number of open connections */
+	PoolSize int
+
+	/* idle connections are closed after this duration */
+	IdleTimeout time.Duration
+
+	/* per-operation timeouts applied on top of the caller's context */
+	Timeouts Timeouts
+
+	/* retry policy for idempotent operations and requests that never reached aclcore */
+	Retry RetryPolicy
+
+	/* consecutive transport failures after which requests fail fast */
+	BreakerThreshold int
+
+	/* interval of background probes while the breaker is open */
+	BreakerProbeInterval time.Duration
+
+	/* identity aclcore must present */
+	Peer PeerPolicy
+
+	/* signs every request when set */
+	Signer *Signer
+
+	/* called after every attempt to send a request (optional) */
+	Observer Observer
+}
+
+/* receives the outcome and duration of every request attempt, retries included */
+type Observer func(op Op, elapsed time.Duration, err error)
+
+/* snapshot of the connection pool */
+type PoolStats struct {
+	/* maximum number of open connections */
+	Size int
+
+	/* connections currently used by a request (or being dialed) */
+	InUse int
+
+	/* open connections waiting to be reused */
+	Idle int
+}
+
+/* per-operation default timeouts */
+type Timeouts struct {
+	/* connecting and the hello exchange */
+	Connect time.Duration
+
+	/* operations without an entry in Operations */
+	Default time.Duration
+
+	/* overrides keyed by operation */
+	Operations map[Op]time.Duration
+}
+
+/* returns the default timeout of op */
+func (t Timeouts) forOp(op Op) time.Duration {
+	if timeout, ok := t.Operations[op]; ok {
+		return timeout
+	}
+	return t.Default
+}
+
+/* creates a client talking to the aclcore socket */
+func NewClient(opts Options) *Client {
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = 1
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = time.Minute
+	}
+	if opts.Timeouts.Connect <= 0 {
+		opts.Timeouts.Connect = 5 * time.Second
+	}
+	if opts.Timeouts.Default <= 0 {
+		opts.Timeouts.Default = 30 * time.Second
+	}
+
+	if opts.Retry.MaxAttempts <= 0 {
+		opts.Retry.MaxAttempts = 1
+	}
+	if opts.Retry.InitialBackoff <= 0 {
+		opts.Retry.InitialBackoff = 100 * time.Millisecond
+	}
+	if opts.Retry.MaxBackoff < opts.Retry.InitialBackoff {
+		opts.Retry.MaxBackoff = opts.Retry.InitialBackoff
+	}
+	if opts.BreakerThreshold <= 0 {
+		opts.BreakerThreshold = 5
+	}
+	if opts.BreakerProbeInterval <= 0 {
+		opts.BreakerProbeInterval = 2 * time.Second
+	}
+
+	c := &Client{
+		socketPath: opts.SocketPath,
+		timeouts:   opts.Timeouts,
+		retry:      opts.Retry,
+		peer:       opts.Peer,
+		signer:     opts.Signer,
+		observer:   opts.Observer,
+		poolSize:   opts.PoolSize,
+	}
+	c.pool = newPool(opts.PoolSize, opts.IdleTimeout, c.dial)
+	c.breaker = newBreaker(opts.BreakerThreshold, opts.BreakerProbeInterval, c.probe)
+	return c
+}
+
+/* closes all pooled connections, in-flight requests are not interrupted */
+func (c *Client) Close() {
+	c.breaker.close()
+	c.pool.close()
+}
+
+/* checks that aclcore accepts connections and completes the hello exchange, bypassing the pool */
+func (c *Client) Ping(ctx context.Context) error {
+	return c.probe(ctx)
+}
+
+/* current state of the connection pool */
+func (c *Client) PoolStats() PoolStats {
+	inUse, idle := c.pool.stats()
+	return PoolStats{Size: c.poolSize, InUse: inUse, Idle: idle}
+}
+
+/* state of the circuit breaker guarding aclcore */
+func (c *Client) BreakerState() BreakerState {
+	return c.breaker.State()
+}
+
+/* payload of an apply request */
+type ApplyRequest struct {
+	TxnID     string          `json:"transactionID"`
+	Action    aclmodel.Action `json:"action"`
+	Entry     aclmodel.Entry  `json:"entry"`
+	Path      string          `json:"path"`
+	Recursive bool            `json:"recursive,omitempty"`
+}
+
+/* applies an ACL entry (to a whole tree if recursive), returning the message reported by aclcore */
+func (c *Client) Apply(ctx context.Context, req *ApplyRequest) (string, error) {
+	var required []string
+	if req.Recursive {
+		required = append(required, CapRecursive)
+	}
+
+	resp, err := c.call(ctx, OpApply, req, required...)
+	if err != nil {
+		return "", err
+	}
+	return resp.Message, nil
+}
+
+/* reads the access and default ACL of path */
+func (c *Client) Get(ctx context.Context, path string) (*aclmodel.ACL, error) {
+	resp, err := c.call(ctx, OpGet, &PathRequest{Path: path})
+	if err != nil {
+		return nil, err
+	}
+
+	var acl aclmodel.ACL
+	if err := json.Unmarshal(resp.Payload, &acl); err != nil {
+		return nil, &ProtocolError{Reason: "malformed get response: " + err.Error()}
+	}
+	return &acl, nil
+}
+
+/* replaces the access and default ACL of path */
+func (c *Client) Set(ctx context.Context, path string, acl *aclmodel.ACL) error {
+	_, err := c.call(ctx, OpSet, &SetRequest{Path: path, ACL: *acl})
+	return err
+}
+
+/* removes all extended ACL entries (and the default ACL) from path */
+func (c *Client) Strip(ctx context.Context, path string) error {
+	_, err := c.call(ctx, OpStrip, &PathRequest{Path: path})
+	return err
+}
+
+/* lists the tree below root (root included) as aclcore sees it */
+func (c *Client) Walk(ctx context.Context, root string, fn func(entry *WalkEntry) error) error {
+	return c.stream(ctx, OpWalk, &PathRequest{Path: root}, func(resp *Response) error {
+		/* the final frame may close the stream without an entry */
+		if len(resp.Payload) == 0 {
+			return nil
+		}
+
+		var entry WalkEntry
+		if err := json.Unmarshal(resp.Payload, &entry); err != nil {
+			return &ProtocolError{Reason: "malformed walk entry: " + err.Error()}
+		}
+		return fn(&entry)
+	})
+}
+
+/* sends a request and waits for its single response */
+func (c *Client) call(ctx context.Context, op Op, payload any, required ...string) (*Response, error) {
+	var resp *Response
+	err := c.stream(ctx, op, payload, func(r *Response) error {
+		resp = r
+		return nil
+	}, required...)
+	return resp, err
+}
+
+/*
+sends a request and passes every response frame to fn
+the request is bounded by the caller's context and the default timeout of op,
+idempotent operations (and requests that never reached aclcore) are retried
+with backoff while aclcore is unreachable
+*/
+func (c *Client) stream(ctx context.Context, op Op, payload any, fn func(*Response) error, required ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeouts.forOp(op))
+	defer cancel()
+
+	if capability, ok := opCapabilities[op]; ok {
+		required = append(required, capability)
+	}
+
+	for attempt := 1; ; attempt++ {
+		if err := c.breaker.allow(); err != nil {
+			c.observe(op, time.Now(), err)
+			return err
+		}
+
+		start := time.Now()
+		err := c.send(ctx, op, payload, fn, required)
+		c.breaker.record(err)
+		c.observe(op, start, err)
+
+		if err == nil || attempt >= c.retry.MaxAttempts || !retryable(op, err) {
+			return err
+		}
+
+		delay := c.retry.backoff(attempt)
+		tracing.Logger(ctx).Warn("aclcore request failed, retrying",
+			zap.String("op", string(op)),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", delay),
+			zap.Error(err),
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+/* reports an attempt started at start to the observer */
+func (c *Client) observe(op Op, start time.Time, err error) {
+	if c.observer != nil {
+		c.observer(op, time.Since(start), err)
+	}
+}
+
+/* sends a request on a pooled connection */
+func (c *Client) send(ctx context.Context, op Op, payload any, fn func(*Response) error, required []string) error {
+	for {
+		conn, reused, err := c.pool.get(ctx)
+		if err != nil {
+			return &undeliveredError{err: err}
+		}
+
+		for _, capability := range required {
+			if !conn.has(capability) {
+				c.pool.put(conn)
+				return &ProtocolError{Reason: fmt.Sprintf("aclcore does not support %q required for %s", capability, op)}
+			}
+		}
+
+		err = conn.exchange(ctx, op, payload, fn)
+
+		var opErr *OperationError
+		switch {
+		case conn.interrupted:
+			/* a cancellation raced with the end of the exchange and broke the deadline */
+			c.pool.discard(conn)
+			return err
+		case err == nil, errors.As(err, &opErr):
+			/* the connection is still in sync, failed operations do not break it */
+			c.pool.put(conn)
+			return err
+		case reused && conn.unsent && ctx.Err() == nil:
+			/*
+				a pooled connection went stale (aclcore restarted) before the
+				request left, so it is safe to retry on a fresh connection
+			*/
+			c.pool.discard(conn)
+			continue
+		case conn.unsent:
+			c.pool.discard(conn)
+			return &undeliveredError{err: err}
+		default:
+			c.pool.discard(conn)
+			return err
+		}
+	}
+}
+
+/* checks that aclcore accepts connections and completes the hello exchange */
+func (c *Client) probe(ctx context.Context) error {
+	cn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	return cn.Close()
+}
+
+/* dials aclcore and performs the hello exchange */
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeouts.Connect)
+	defer cancel()
+
+	if err := c.peer.verifySocketFile(c.socketPath); err != nil {
+		return nil, err
+	}
+
+	var dialer net.Dialer
+	netConn, err := dialer.DialContext(ctx, "unix", c.socketPath)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, interrupted("dial", ctxErr)
+		}
+		return nil, &unavailableError{op: "dial", err: err}
+	}
+
+	/* nothing, not even the hello, is sent to an unexpected peer */
+	if err := c.peer.verifyConn(netConn); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	cn := newConn(netConn, c.signer)
+	if err := cn.handshake(ctx); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	/* aclcore must be able to verify what it receives */
+	if c.signer != nil && !cn.has(CapHMAC) {
+		netConn.Close()
+		return nil, &ProtocolError{Reason: "message signing is enabled but aclcore does not support " + CapHMAC}
+	}
+
+	return cn, nil
+}
@@ -0,0 +1,247 @@
+package aclcore
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/tracing"
+)
+
+/* deadline in the past, used to unblock pending socket I/O */
+var aLongTimeAgo = time.Unix(1, 0)
+
+/* single connection to aclcore */
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+
+	/* negotiated in the hello exchange */
+	version      int
+	capabilities []string
+
+	nextID uint64
+
+	/* set when the last request was not written (it never reached aclcore) */
+	unsent bool
+
+	/* set when a cancellation moved the deadline into the past, the connection cannot be reused */
+	interrupted bool
+
+	/* signs every request when message authentication is enabled */
+	signer *Signer
+}
+
+/* wraps an established socket connection */
+func newConn(netConn net.Conn, signer *Signer) *conn {
+	return &conn{Conn: netConn, reader: bufio.NewReader(netConn), signer: signer}
+}
+
+/* builds the next request frame, signed if authentication is enabled */
+func (c *conn) newRequest(ctx context.Context, op Op, payload []byte) (*Request, error) {
+	c.nextID++
+	req := &Request{ID: c.nextID, Op: op, Payload: payload}
+
+	if trace := tracing.FromContext(ctx); trace != nil {
+		req.Traceparent = trace.Traceparent()
+		req.RequestID = trace.RequestID
+	}
+
+	if c.signer != nil {
+		if err := c.signer.sign(req); err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+/* exchanges hello frames and settles on a protocol version */
+func (c *conn) handshake(ctx context.Context) error {
+	capabilities := slices.Clone(clientCapabilities)
+	if c.signer != nil {
+		capabilities = append(capabilities, CapHMAC)
+	}
+
+	resp, err := c.roundTrip(ctx, OpHello, &Hello{
+		Client:       clientName,
+		MinVersion:   MinProtocolVersion,
+		MaxVersion:   MaxProtocolVersion,
+		Capabilities: capabilities,
+	})
+	if err != nil {
+		return err
+	}
+
+	var reply HelloReply
+	if err := json.Unmarshal(resp.Payload, &reply); err != nil {
+		return &ProtocolError{Reason: "malformed hello reply: " + err.Error()}
+	}
+
+	if reply.Version < MinProtocolVersion || reply.Version > MaxProtocolVersion {
+		return &ProtocolError{Reason: fmt.Sprintf(
+			"aclcore selected protocol version %d, supported versions are %d-%d",
+			reply.Version, MinProtocolVersion, MaxProtocolVersion,
+		)}
+	}
+
+	c.version = reply.Version
+	c.capabilities = reply.Capabilities
+	return nil
+}
+
+/* reports whether aclcore announced a capability */
+func (c *conn) has(capability string) bool {
+	return slices.Contains(c.capabilities, capability)
+}
+
+/* writes a request frame and reads the matching (single) response frame */
+func (c *conn) roundTrip(ctx context.Context, op Op, payload any) (*Response, error) {
+	var resp *Response
+	err := c.exchange(ctx, op, payload, func(r *Response) error {
+		resp = r
+		return nil
+	})
+	return resp, err
+}
+
+/*
+writes a request frame and passes every response frame of the request to fn
+streamed responses carry more=true on all frames but the last one
+
+socket deadlines follow the context deadline and cancelling the context (or
+fn failing) aborts the exchange, after which the connection must be discarded
+*/
+func (c *conn) exchange(ctx context.Context, op Op, payload any, fn func(*Response) error) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s payload: %w", op, err)
+	}
+
+	req, err := c.newRequest(ctx, op, data)
+	if err != nil {
+		return err
+	}
+
+	c.unsent, c.interrupted = true, false
+
+	/* zero deadline (no context deadline) means no timeout */
+	deadline, _ := ctx.Deadline()
+	if err := c.SetDeadline(deadline); err != nil {
+		return &unavailableError{op: "set deadline", err: err}
+	}
+
+	/* cancellation interrupts blocked reads and writes */
+	interrupting := make(chan struct{})
+	stopInterrupt := context.AfterFunc(ctx, func() {
+		c.SetDeadline(aLongTimeAgo)
+		close(interrupting)
+	})
+
+	/*
+		waits for a running interrupt, so it cannot clobber the deadline of a
+		cancel notice or of the next request once the connection is pooled
+	*/
+	var stopped bool
+	stop := func() {
+		if stopped {
+			return
+		}
+		stopped = true
+		if !stopInterrupt() {
+			<-interrupting
+			c.interrupted = true
+		}
+	}
+	defer stop()
+
+	if err := WriteFrame(c.Conn, req); err != nil {
+		if ctxErr := contextError(ctx, err); ctxErr != nil {
+			return interrupted(string(op), ctxErr)
+		}
+		return &unavailableError{op: "write", err: err}
+	}
+	c.unsent = false
+
+	for {
+		var resp Response
+		if err := ReadFrame(c.reader, &resp); err != nil {
+			if ctxErr := contextError(ctx, err); ctxErr != nil {
+				/* the request is in flight, tell aclcore to stop working on it */
+				stop()
+				c.sendCancel(ctx, op, req.ID)
+				return interrupted(string(op), ctxErr)
+			}
+			if _, ok := err.(*ProtocolError); ok {
+				return err
+			}
+			return &unavailableError{op: "read", err: err}
+		}
+
+		if resp.ID != req.ID {
+			return &ProtocolError{Reason: fmt.Sprintf("response ID %d does not match request ID %d", resp.ID, req.ID)}
+		}
+
+		if !resp.Success {
+			if resp.More {
+				return &ProtocolError{Reason: "failed response in the middle of a stream"}
+			}
+			return &OperationError{Message: resp.Message, Errno: syscall.Errno(resp.Errno)}
+		}
+
+		if err := fn(&resp); err != nil {
+			if resp.More {
+				stop()
+				c.sendCancel(ctx, op, req.ID)
+			}
+			return &abortedError{err: err}
+		}
+
+		if !resp.More {
+			return nil
+		}
+	}
+}
+
+/* how long a cancellation notice may take to be written */
+const cancelWriteTimeout = time.Second
+
+/*
+notifies aclcore that the caller gave up on a request
+the notice is best effort and not answered, the connection is discarded afterwards
+*/
+func (c *conn) sendCancel(ctx context.Context, op Op, id uint64) {
+	if !cancellable[op] || !c.has(CapCancel) {
+		return
+	}
+
+	payload, _ := json.Marshal(&Cancel{RequestID: id})
+	req, err := c.newRequest(ctx, OpCancel, payload)
+	if err != nil {
+		return
+	}
+
+	c.SetDeadline(time.Now().Add(cancelWriteTimeout))
+	WriteFrame(c.Conn, req)
+}
+
+/* returns the context error behind a failed socket operation (nil if unrelated) */
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	/* the socket deadline can fire just before the context timer does */
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return context.DeadlineExceeded
+	}
+
+	return nil
+}
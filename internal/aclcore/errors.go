@@ -0,0 +1,83 @@
+package aclcore
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+var (
+	/* aclcore could not be reached (socket missing, refused, connection dropped) */
+	ErrUnavailable = errors.New("aclcore unavailable")
+)
+
+/* aclcore sent something that does not follow the protocol */
+type ProtocolError struct {
+	Reason string
+}
+
+func (e *ProtocolError) Error() string {
+	return "aclcore protocol error: " + e.Reason
+}
+
+/* aclcore processed the request but the operation failed */
+type OperationError struct {
+	Message string
+	Errno   syscall.Errno
+}
+
+func (e *OperationError) Error() string {
+	if e.Errno != 0 {
+		return e.Message + ": " + e.Errno.Error()
+	}
+	return e.Message
+}
+
+/* wraps the context error of an interrupted exchange (errors.Is works on ctx errors) */
+func interrupted(op string, ctxErr error) error {
+	return fmt.Errorf("aclcore %s interrupted: %w", op, ctxErr)
+}
+
+/* wraps a transport level error so errors.Is(err, ErrUnavailable) holds */
+type unavailableError struct {
+	op  string
+	err error
+}
+
+func (e *unavailableError) Error() string {
+	return "aclcore unavailable: " + e.op + ": " + e.err.Error()
+}
+
+func (e *unavailableError) Is(target error) bool {
+	return target == ErrUnavailable
+}
+
+func (e *unavailableError) Unwrap() error {
+	return e.err
+}
+
+/* failure that happened before the request reached aclcore (acquire, dial or write) */
+type undeliveredError struct {
+	err error
+}
+
+func (e *undeliveredError) Error() string {
+	return e.err.Error()
+}
+
+func (e *undeliveredError) Unwrap() error {
+	return e.err
+}
+
+/* error returned by a stream handler, the connection is out of sync afterwards */
+type abortedError struct {
+	err error
+}
+
+func (e *abortedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *abortedError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,120 @@
+package aclcore
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+/* identity aclcore must present before any request is sent */
+type PeerPolicy struct {
+	/* skips all checks (development only) */
+	Disabled bool
+
+	/* uid the aclcore process must run as */
+	UID uint32
+
+	/* gid the aclcore process must run as (negative accepts any gid) */
+	GID int64
+
+	/* additionally verify owner and permissions of the socket file */
+	CheckSocketFile bool
+
+	/* uid that must own the socket file */
+	SocketOwnerUID uint32
+
+	/* permission bits the socket file may have at most */
+	SocketMaxMode os.FileMode
+}
+
+/* aclcore (or whatever listens on the socket) is not who it should be */
+type PeerError struct {
+	Reason string
+}
+
+func (e *PeerError) Error() string {
+	return "untrusted aclcore peer: " + e.Reason
+}
+
+/*
+checks the credentials of the process at the other end of netConn
+this is the authoritative check: the socket file can be replaced between
+verifySocketFile and the dial, the credentials of a connected peer cannot
+*/
+func (p *PeerPolicy) verifyConn(netConn net.Conn) error {
+	if p.Disabled {
+		return nil
+	}
+
+	unixConn, ok := netConn.(*net.UnixConn)
+	if !ok {
+		return &PeerError{Reason: "not a unix socket connection"}
+	}
+
+	rawConn, err := unixConn.SyscallConn()
+	if err != nil {
+		return &PeerError{Reason: "cannot access socket: " + err.Error()}
+	}
+
+	var (
+		cred    *unix.Ucred
+		credErr error
+	)
+	err = rawConn.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if err == nil {
+		err = credErr
+	}
+	if err != nil {
+		return &PeerError{Reason: "cannot read SO_PEERCRED: " + err.Error()}
+	}
+
+	if cred.Uid != p.UID {
+		return &PeerError{Reason: fmt.Sprintf("peer pid %d runs as uid %d, expected uid %d", cred.Pid, cred.Uid, p.UID)}
+	}
+
+	if p.GID >= 0 && int64(cred.Gid) != p.GID {
+		return &PeerError{Reason: fmt.Sprintf("peer pid %d runs as gid %d, expected gid %d", cred.Pid, cred.Gid, p.GID)}
+	}
+
+	return nil
+}
+
+/*
+checks owner, type and permissions of the socket file before connecting
+a defence in depth only, verifyConn decides whether the peer is trusted
+*/
+func (p *PeerPolicy) verifySocketFile(path string) error {
+	if p.Disabled || !p.CheckSocketFile {
+		return nil
+	}
+
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		/* a missing socket means aclcore is down, nothing is dialed */
+		return &unavailableError{op: "check socket file", err: err}
+	}
+	if err != nil {
+		return &PeerError{Reason: "cannot check socket file: " + err.Error()}
+	}
+
+	if info.Mode().Type() != os.ModeSocket {
+		return &PeerError{Reason: fmt.Sprintf("%s is not a socket", path)}
+	}
+
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Uid != p.SocketOwnerUID {
+		return &PeerError{Reason: fmt.Sprintf("%s is owned by uid %d, expected uid %d", path, stat.Uid, p.SocketOwnerUID)}
+	}
+
+	if extra := info.Mode().Perm() &^ p.SocketMaxMode; extra != 0 {
+		return &PeerError{Reason: fmt.Sprintf("%s has mode %04o, permissions %04o are not allowed", path, info.Mode().Perm(), extra)}
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package aclcore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+/* idle connection kept in the pool */
+type idleConn struct {
+	conn  *conn
+	since time.Time
+}
+
+/*
+bounded pool of handshaken connections to aclcore
+at most size connections are open at any time, callers wait for a free slot
+*/
+type pool struct {
+	dial        func(ctx context.Context) (*conn, error)
+	idleTimeout time.Duration
+
+	/* one token per connection that may be open */
+	slots chan struct{}
+
+	mu     sync.Mutex
+	idle   []idleConn
+	closed bool
+
+	done chan struct{}
+}
+
+/* creates a pool and starts its idle connection reaper */
+func newPool(size int, idleTimeout time.Duration, dial func(ctx context.Context) (*conn, error)) *pool {
+	p := &pool{
+		dial:        dial,
+		idleTimeout: idleTimeout,
+		slots:       make(chan struct{}, size),
+		done:        make(chan struct{}),
+	}
+
+	go p.reap()
+
+	return p
+}
+
+/* takes an idle connection or dials a new one once a slot is free */
+func (p *pool) get(ctx context.Context) (cn *conn, reused bool, err error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, false, interrupted("acquire connection", ctx.Err())
+	case <-p.done:
+		return nil, false, &unavailableError{op: "acquire connection", err: errPoolClosed}
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		<-p.slots
+		return nil, false, &unavailableError{op: "acquire connection", err: errPoolClosed}
+	}
+
+	/* most recently used connections are the least likely to be stale */
+	for len(p.idle) > 0 {
+		last := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+
+		if time.Since(last.since) < p.idleTimeout {
+			p.mu.Unlock()
+			return last.conn, true, nil
+		}
+		last.conn.Close()
+	}
+	p.mu.Unlock()
+
+	cn, err = p.dial(ctx)
+	if err != nil {
+		<-p.slots
+		return nil, false, err
+	}
+
+	return cn, false, nil
+}
+
+/* returns a healthy connection to the pool */
+func (p *pool) put(cn *conn) {
+	p.mu.Lock()
+	if p.closed {
+		cn.Close()
+	} else {
+		p.idle = append(p.idle, idleConn{conn: cn, since: time.Now()})
+	}
+	p.mu.Unlock()
+
+	<-p.slots
+}
+
+/* closes a broken connection and frees its slot */
+func (p *pool) discard(cn *conn) {
+	cn.Close()
+	<-p.slots
+}
+
+/* number of connections handed out to callers and kept idle */
+func (p *pool) stats() (inUse, idle int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.slots), len(p.idle)
+}
+
+/* closes idle connections and rejects further use */
+func (p *pool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.done)
+
+	for _, ic := range p.idle {
+		ic.conn.Close()
+	}
+	p.idle = nil
+}
+
+/* periodically closes connections idle for longer than idleTimeout */
+func (p *pool) reap() {
+	ticker := time.NewTicker(p.idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+
+		p.mu.Lock()
+		kept := p.idle[:0]
+		for _, ic := range p.idle {
+			if time.Since(ic.since) >= p.idleTimeout {
+				ic.conn.Close()
+				continue
+			}
+			kept = append(kept, ic)
+		}
+		p.idle = kept
+		p.mu.Unlock()
+	}
+}
+
+/* returned once the client has been closed */
+var errPoolClosed = errors.New("client closed")
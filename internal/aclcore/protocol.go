@@ -0,0 +1,214 @@
+package aclcore
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+)
+
+/*
+wire protocol between aclapi and aclcore
+
+every message is a frame: a 4 byte big-endian payload length followed by a
+JSON document of that length. a connection starts with a hello exchange in
+which both sides announce the protocol versions and capabilities they support,
+after which the client sends requests and aclcore answers each with a
+response carrying the same request ID.
+*/
+
+/* protocol versions spoken by this client */
+const (
+	MinProtocolVersion = 1
+	MaxProtocolVersion = 1
+)
+
+/* largest frame accepted from aclcore */
+const MaxFrameSize = 16 << 20
+
+/* operation requested from aclcore */
+type Op string
+
+const (
+	/* version and capability negotiation (first frame on every connection) */
+	OpHello Op = "hello"
+
+	/* applies a single ACL entry to a path (or a whole tree) */
+	OpApply Op = "apply"
+
+	/* reads the access and default ACL of a path */
+	OpGet Op = "get"
+
+	/* replaces the access and default ACL of a path */
+	OpSet Op = "set"
+
+	/* removes all extended entries from a path */
+	OpStrip Op = "strip"
+
+	/* lists a directory tree (streamed) */
+	OpWalk Op = "walk"
+
+	/* asks aclcore to abort an in-flight request (not answered) */
+	OpCancel Op = "cancel"
+)
+
+/* capabilities announced in the hello exchange */
+const (
+	CapStructuredEntries = "structured-entries"
+	CapCancel            = "cancel"
+	CapHMAC              = "hmac-sha256"
+	CapRecursive         = "recursive-apply"
+	CapStreaming         = "streaming"
+)
+
+/* capabilities this client supports */
+var clientCapabilities = []string{
+	CapStructuredEntries,
+	CapCancel,
+	CapRecursive,
+	CapStreaming,
+}
+
+/* operations sent with a timeout of their own, hello runs within the connect timeout */
+var timedOps = map[Op]bool{
+	OpApply: true,
+	OpGet:   true,
+	OpSet:   true,
+	OpStrip: true,
+	OpWalk:  true,
+}
+
+/* reports whether the timeout of op can be configured */
+func (op Op) Timed() bool {
+	return timedOps[op]
+}
+
+/* operations long enough to be worth cancelling in aclcore */
+var cancellable = map[Op]bool{
+	OpApply: true,
+	OpSet:   true,
+	OpWalk:  true,
+}
+
+/* capabilities aclcore must announce before an operation may be sent */
+var opCapabilities = map[Op]string{
+	OpApply: CapStructuredEntries,
+	OpGet:   CapStructuredEntries,
+	OpSet:   CapStructuredEntries,
+	OpStrip: CapStructuredEntries,
+	OpWalk:  CapStreaming,
+}
+
+/* request frame sent to aclcore */
+type Request struct {
+	ID      uint64          `json:"id"`
+	Op      Op              `json:"op"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+
+	/*
+		correlation of the gRPC request that caused this one, for aclcore logs
+		informational only, it is not covered by the message authentication code
+	*/
+	Traceparent string `json:"traceparent,omitempty"`
+	RequestID   string `json:"request_id,omitempty"`
+
+	/* authentication fields, set only when requests are signed */
+	Timestamp int64  `json:"ts,omitempty"`
+	Nonce     string `json:"nonce,omitempty"`
+	MAC       string `json:"mac,omitempty"`
+}
+
+/* response frame received from aclcore */
+type Response struct {
+	ID      uint64          `json:"id"`
+	Success bool            `json:"success"`
+	Message string          `json:"message,omitempty"`
+	Errno   int             `json:"errno,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+
+	/* set on all but the last frame of a streamed response */
+	More bool `json:"more,omitempty"`
+}
+
+/* payload of the client hello */
+type Hello struct {
+	Client       string   `json:"client"`
+	MinVersion   int      `json:"min_version"`
+	MaxVersion   int      `json:"max_version"`
+	Capabilities []string `json:"capabilities"`
+}
+
+/* payload of the aclcore hello reply */
+type HelloReply struct {
+	Server       string   `json:"server"`
+	Version      int      `json:"version"`
+	Capabilities []string `json:"capabilities"`
+}
+
+/* payload of a cancel request */
+type Cancel struct {
+	RequestID uint64 `json:"request_id"`
+}
+
+/* payload of get, strip and walk requests */
+type PathRequest struct {
+	Path string `json:"path"`
+}
+
+/* payload of a set request */
+type SetRequest struct {
+	Path string       `json:"path"`
+	ACL  aclmodel.ACL `json:"acl"`
+}
+
+/* payload of every frame of a walk response */
+type WalkEntry struct {
+	Path  string `json:"path"`
+	IsDir bool   `json:"is_dir"`
+}
+
+/* writes v as a single length-prefixed JSON frame */
+func WriteFrame(w io.Writer, v any) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode frame: %w", err)
+	}
+
+	if len(payload) > MaxFrameSize {
+		return fmt.Errorf("frame of %d bytes exceeds maximum of %d bytes", len(payload), MaxFrameSize)
+	}
+
+	/* header and payload are written together so frames are never interleaved */
+	frame := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[4:], payload)
+
+	_, err = w.Write(frame)
+	return err
+}
+
+/* reads a single length-prefixed JSON frame into v */
+func ReadFrame(r io.Reader, v any) error {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return err
+	}
+
+	size := binary.BigEndian.Uint32(header[:])
+	if size > MaxFrameSize {
+		return &ProtocolError{Reason: fmt.Sprintf("frame of %d bytes exceeds maximum of %d bytes", size, MaxFrameSize)}
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(payload, v); err != nil {
+		return &ProtocolError{Reason: "malformed frame: " + err.Error()}
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package aclcore
+
+import (
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+/* retry policy for idempotent operations and requests that never reached aclcore */
+type RetryPolicy struct {
+	/* total attempts including the first one (1 disables retries) */
+	MaxAttempts int
+
+	/* backoff before the first retry, doubled on every further retry */
+	InitialBackoff time.Duration
+
+	/* upper bound of the backoff */
+	MaxBackoff time.Duration
+}
+
+/* operations that can be repeated without changing the outcome */
+var idempotent = map[Op]bool{
+	OpGet:   true,
+	OpSet:   true,
+	OpStrip: true,
+
+	/*
+		applies are not, aclcore may have applied (part of a recursive) change
+		before the connection dropped, and walks would pass entries twice
+	*/
+}
+
+/*
+reports whether a failed attempt of op may be retried
+requests that never reached aclcore can always be sent again
+*/
+func retryable(op Op, err error) bool {
+	if errors.Is(err, ErrCircuitOpen) || !errors.Is(err, ErrUnavailable) {
+		return false
+	}
+	return idempotent[op] || errors.As(err, new(*undeliveredError))
+}
+
+/* returns the delay before retry number attempt (1-based), with full jitter */
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, p.MaxBackoff)
+
+	return rand.N(backoff) + 1
+}
@@ -0,0 +1,30 @@
+package aclmodel
+
+/* operation performed with a single entry */
+type Action string
+
+const (
+	ActionAdd    Action = "add"
+	ActionModify Action = "modify"
+	ActionRemove Action = "remove"
+)
+
+/* complete ACL of a file: access entries and (directories only) default entries */
+type ACL struct {
+	Access  []Entry `json:"access"`
+	Default []Entry `json:"default,omitempty"`
+}
+
+/* reports whether two entries refer to the same principal */
+func (e *Entry) SamePrincipal(other *Entry) bool {
+	if e.Tag != other.Tag {
+		return false
+	}
+	if !e.Tag.Named() {
+		return true
+	}
+	if e.ID != nil && other.ID != nil {
+		return *e.ID == *other.ID
+	}
+	return e.Qualifier == other.Qualifier
+}
@@ -0,0 +1,87 @@
+package aclmodel
+
+import "testing"
+
+func TestParsePerms(t *testing.T) {
+	tests := []struct {
+		perms   string
+		want    Perm
+		wantErr bool
+	}{
+		{perms: "rwx", want: PermRWX},
+		{perms: "r-x", want: PermRead | PermExecute},
+		{perms: "---", want: 0},
+		{perms: "-", want: 0},
+		{perms: "rwX", want: PermRead | PermWrite | PermConditionalExecute},
+		{perms: "xwr", want: PermRWX},
+		{perms: "rwxX", want: PermRWX | PermConditionalExecute},
+		{perms: "", wantErr: true},
+		{perms: "rwx-X", wantErr: true},
+		{perms: "rr", wantErr: true},
+		{perms: "rws", wantErr: true},
+		{perms: "RWX", wantErr: true},
+		{perms: "7", wantErr: true},
+		{perms: "r,x", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := ParsePerms(test.perms)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("ParsePerms(%q) = %v, %v, want %v (error %v)", test.perms, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestPermString(t *testing.T) {
+	tests := []struct {
+		perms Perm
+		want  string
+	}{
+		{0, "---"},
+		{PermRWX, "rwx"},
+		{PermRead | PermExecute, "r-x"},
+		{PermRead | PermConditionalExecute, "r-X"},
+		{PermRWX | PermConditionalExecute, "rwx"},
+	}
+
+	for _, test := range tests {
+		if got := test.perms.String(); got != test.want {
+			t.Errorf("Perm(%d).String() = %q, want %q", test.perms, got, test.want)
+		}
+
+		/* what is printed parses back */
+		if parsed, err := ParsePerms(test.want); err != nil || parsed.String() != test.want {
+			t.Errorf("ParsePerms(%q) = %v, %v, does not round trip", test.want, parsed, err)
+		}
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"alice", true},
+		{"1000", true},
+		{"DOMAIN\\alice", true},
+		{"alice@example.org", true},
+		{"jürgen", true},
+		{"domain users", true},
+		{"", false},
+		{"alice:rwx", false},
+		{"staff,user:eve", false},
+		{"alice\nuser:eve:rwx", false},
+		{"alice\r", false},
+		{"alice\x00", false},
+		{"alice\t", false},
+		{"\x1b[31malice", false},
+		{"alice\u0085", false},
+		{"alice\xff", false},
+	}
+
+	for _, test := range tests {
+		if err := ValidateName(test.name); (err == nil) != test.valid {
+			t.Errorf("ValidateName(%q) = %v, want valid %v", test.name, err, test.valid)
+		}
+	}
+}
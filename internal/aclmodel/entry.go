@@ -0,0 +1,150 @@
+package aclmodel
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+/* ACL entry tag (kind of principal an entry applies to) */
+type Tag string
+
+const (
+	/* named user entry (user:alice:rwx) */
+	TagUser Tag = "user"
+
+	/* named group entry (group:staff:r-x) */
+	TagGroup Tag = "group"
+
+	/* owning user entry (user::rwx) */
+	TagUserObj Tag = "user_obj"
+
+	/* owning group entry (group::r-x) */
+	TagGroupObj Tag = "group_obj"
+
+	/* mask entry (mask::rwx) */
+	TagMask Tag = "mask"
+
+	/* other entry (other::r--) */
+	TagOther Tag = "other"
+)
+
+/* reports whether entries with this tag carry a qualifier */
+func (t Tag) Named() bool {
+	return t == TagUser || t == TagGroup
+}
+
+/* permission bitmask of an ACL entry */
+type Perm uint8
+
+const (
+	PermExecute Perm = 1 << iota
+	PermWrite
+	PermRead
+
+	/*
+		conditional execute (X): execute only if the target is a directory or
+		already executable for some user, resolved by aclcore when applying
+	*/
+	PermConditionalExecute
+)
+
+/* permission bits that are stored in the kernel ACL */
+const PermRWX = PermRead | PermWrite | PermExecute
+
+/* structured ACL entry sent to aclcore */
+type Entry struct {
+	Tag       Tag     `json:"tag"`
+	Qualifier string  `json:"qualifier,omitempty"`
+	ID        *uint32 `json:"id,omitempty"`
+	Perms     Perm    `json:"perms"`
+	Default   bool    `json:"default"`
+}
+
+/* parses a permission string made of r, w, x, X and - */
+func ParsePerms(perms string) (Perm, error) {
+	if perms == "" {
+		return 0, fmt.Errorf("permissions are required")
+	}
+
+	if len(perms) > 4 {
+		return 0, fmt.Errorf("permission string %q is too long", perms)
+	}
+
+	var p Perm
+	for _, c := range perms {
+		var bit Perm
+		switch c {
+		case '-':
+			continue
+		case 'r':
+			bit = PermRead
+		case 'w':
+			bit = PermWrite
+		case 'x':
+			bit = PermExecute
+		case 'X':
+			bit = PermConditionalExecute
+		default:
+			return 0, fmt.Errorf("invalid permission character %q in %q (allowed: r, w, x, X, -)", c, perms)
+		}
+
+		if p&bit != 0 {
+			return 0, fmt.Errorf("permission %q given more than once in %q", c, perms)
+		}
+		p |= bit
+	}
+
+	return p, nil
+}
+
+/* formats the permissions the way getfacl does ("r-x", "rwX") */
+func (p Perm) String() string {
+	var b strings.Builder
+
+	b.WriteByte(pick(p&PermRead != 0, 'r'))
+	b.WriteByte(pick(p&PermWrite != 0, 'w'))
+
+	switch {
+	case p&PermExecute != 0:
+		b.WriteByte('x')
+	case p&PermConditionalExecute != 0:
+		b.WriteByte('X')
+	default:
+		b.WriteByte('-')
+	}
+
+	return b.String()
+}
+
+/* returns c if set, '-' otherwise */
+func pick(set bool, c byte) byte {
+	if set {
+		return c
+	}
+	return '-'
+}
+
+/*
+checks that a user or group name cannot change the meaning of an entry
+colons and commas are entry separators in the textual ACL format and
+control characters (newlines in particular) are record separators
+*/
+func ValidateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("name is empty")
+	}
+
+	for _, c := range name {
+		switch {
+		case c == ':' || c == ',':
+			return fmt.Errorf("name %q contains forbidden character %q", name, c)
+		case unicode.IsControl(c):
+			return fmt.Errorf("name %q contains a control character", name)
+		case c == unicode.ReplacementChar:
+			return fmt.Errorf("name %q is not valid UTF-8", name)
+		}
+	}
+
+	return nil
+}
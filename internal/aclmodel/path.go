@@ -0,0 +1,20 @@
+package aclmodel
+
+import "strings"
+
+/* reports whether path is dir or below it (dir "/" contains every path) */
+func PathWithin(path, dir string) bool {
+	dir = strings.TrimSuffix(dir, "/")
+	if path == dir || dir == "" {
+		return true
+	}
+	return strings.HasPrefix(path, dir+"/")
+}
+
+/*
+reports whether a change to path reaches something at or below prefix: the
+change is below prefix, or it is a recursive change above it
+*/
+func ChangeWithin(path string, recursive bool, prefix string) bool {
+	return PathWithin(path, prefix) || (recursive && PathWithin(prefix, path))
+}
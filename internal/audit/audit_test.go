@@ -0,0 +1,393 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+)
+
+/* key of the hash chain of test logs */
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+/* writes n records to a new log and returns its path */
+func writeLog(t *testing.T, n int) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "audit", "audit.log")
+	log, err := Open(path, Options{Key: testKey})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer log.Close()
+
+	for i := 0; i < n; i++ {
+		appendRecord(t, log, "/srv/share")
+	}
+	return path
+}
+
+func appendRecord(t *testing.T, log *Log, path string) *Record {
+	t.Helper()
+
+	uid := uint32(1001)
+	record := &Record{
+		Caller:  "backend",
+		EndUser: "alice",
+		Path:    path,
+		Action:  aclmodel.ActionAdd,
+		Entry:   &aclmodel.Entry{Tag: aclmodel.TagUser, ID: &uid, Perms: aclmodel.PermRead},
+		Before:  &aclmodel.ACL{Access: []aclmodel.Entry{{Tag: aclmodel.TagUserObj, Perms: aclmodel.PermRWX}}},
+		Outcome: OutcomeSuccess,
+	}
+	if err := log.Append(record); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	return record
+}
+
+func readLines(t *testing.T, path string) [][]byte {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading log: %v", err)
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	return lines[:len(lines)-1]
+}
+
+func writeLines(t *testing.T, path string, lines [][]byte) {
+	t.Helper()
+
+	if err := os.WriteFile(path, bytes.Join(lines, nil), 0o600); err != nil {
+		t.Fatalf("writing log: %v", err)
+	}
+}
+
+func TestAppendAndVerify(t *testing.T) {
+	path := writeLog(t, 3)
+
+	summary, err := VerifyFile(path, testKey, "")
+	if err != nil {
+		t.Fatalf("VerifyFile: %v", err)
+	}
+	if summary.Records != 3 {
+		t.Fatalf("Records = %d, want 3", summary.Records)
+	}
+
+	/* reopening continues the chain */
+	log, err := Open(path, Options{Key: testKey})
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	record := appendRecord(t, log, "/srv/other")
+	log.Close()
+
+	if record.Seq != 4 || record.PrevHash != summary.LastHash {
+		t.Fatalf("record %d chained to %s, want 4 chained to %s", record.Seq, record.PrevHash, summary.LastHash)
+	}
+
+	summary, err = VerifyFile(path, testKey, summary.LastHash)
+	if err != nil {
+		t.Fatalf("VerifyFile: %v", err)
+	}
+	if summary.Records != 4 || summary.LastHash != record.Hash || !summary.AnchorFound {
+		t.Fatalf("summary = %+v", summary)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(lines [][]byte) [][]byte
+		line   int
+		reason string
+	}{
+		{
+			name: "modified field",
+			tamper: func(lines [][]byte) [][]byte {
+				lines[1] = bytes.Replace(lines[1], []byte(`"end_user":"alice"`), []byte(`"end_user":"bob"`), 1)
+				return lines
+			},
+			line:   2,
+			reason: "hash does not match",
+		},
+		{
+			name: "removed record",
+			tamper: func(lines [][]byte) [][]byte {
+				return append(lines[:1], lines[2:]...)
+			},
+			line:   2,
+			reason: "found record 3",
+		},
+		{
+			name: "swapped records",
+			tamper: func(lines [][]byte) [][]byte {
+				lines[1], lines[2] = lines[2], lines[1]
+				return lines
+			},
+			line:   2,
+			reason: "found record 3",
+		},
+		{
+			name: "added field",
+			tamper: func(lines [][]byte) [][]byte {
+				lines[0] = bytes.Replace(lines[0], []byte(`{"seq":1,`), []byte(`{"seq":1,"note":"x",`), 1)
+				return lines
+			},
+			line:   1,
+			reason: "canonical form",
+		},
+		{
+			name: "truncated record",
+			tamper: func(lines [][]byte) [][]byte {
+				lines[2] = lines[2][:len(lines[2])/2]
+				return lines
+			},
+			line:   3,
+			reason: "truncated record",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeLog(t, 3)
+			writeLines(t, path, tt.tamper(readLines(t, path)))
+
+			_, err := VerifyFile(path, testKey, "")
+			var verifyErr *VerifyError
+			if !errors.As(err, &verifyErr) {
+				t.Fatalf("VerifyFile error = %v, want a VerifyError", err)
+			}
+			if verifyErr.Line != tt.line || !strings.Contains(verifyErr.Reason, tt.reason) {
+				t.Fatalf("VerifyFile error = %v, want line %d: %s", err, tt.line, tt.reason)
+			}
+
+			/* a broken log is not extended */
+			if _, err := Open(path, Options{Key: testKey}); !errors.As(err, &verifyErr) {
+				t.Fatalf("Open error = %v, want a VerifyError", err)
+			}
+		})
+	}
+}
+
+func TestVerifyDetectsRemovedTail(t *testing.T) {
+	path := writeLog(t, 3)
+	summary, err := VerifyFile(path, testKey, "")
+	if err != nil {
+		t.Fatalf("VerifyFile: %v", err)
+	}
+
+	lines := readLines(t, path)
+	writeLines(t, path, lines[:2])
+
+	summary, err = VerifyFile(path, testKey, summary.LastHash)
+	if err != nil {
+		t.Fatalf("VerifyFile: %v", err)
+	}
+	if summary.AnchorFound {
+		t.Fatal("anchor of the removed record was found")
+	}
+}
+
+func TestQuery(t *testing.T) {
+	log, err := Open(filepath.Join(t.TempDir(), "audit.log"), Options{Key: testKey})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer log.Close()
+
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	uid, gid := uint32(1001), uint32(2001)
+	for i, record := range []*Record{
+		{Caller: "portal", EndUser: "carol", TxnID: "t1", Path: "/srv/share", Entry: &aclmodel.Entry{Tag: aclmodel.TagUser, Qualifier: "alice", ID: &uid}},
+		{Caller: "portal", EndUser: "dave", TxnID: "t2", Path: "/srv/share/docs/a", Entry: &aclmodel.Entry{Tag: aclmodel.TagGroup, Qualifier: "staff", ID: &gid}},
+		{Caller: "batch", TxnID: "t3", Path: "/srv", Recursive: true, Entry: &aclmodel.Entry{Tag: aclmodel.TagUser, Qualifier: "alice", ID: &uid}},
+		{Caller: "portal", EndUser: "carol", TxnID: "t4", Path: "/srv/shared", Entry: &aclmodel.Entry{Tag: aclmodel.TagOther}},
+	} {
+		record.Time = start.Add(time.Duration(i) * time.Hour)
+		record.Action = aclmodel.ActionAdd
+		record.Outcome = OutcomeSuccess
+		if err := log.Append(record); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []uint64
+	}{
+		{"everything", Query{}, []uint64{1, 2, 3, 4}},
+		{"path prefix", Query{PathPrefix: "/srv/share"}, []uint64{1, 2, 3}},
+		{"path below a recursive change", Query{PathPrefix: "/srv/share/docs"}, []uint64{2, 3}},
+		{"entity by name", Query{Entity: "alice"}, []uint64{1, 3}},
+		{"entity by id", Query{Entity: "2001"}, []uint64{2}},
+		{"caller", Query{Caller: "portal"}, []uint64{1, 2, 4}},
+		{"caller and end user", Query{Caller: "portal", EndUser: "carol"}, []uint64{1, 4}},
+		{"transaction", Query{TxnID: "t3"}, []uint64{3}},
+		{"combined", Query{Caller: "portal", Entity: "alice"}, []uint64{1}},
+		{"time range", Query{Since: start.Add(time.Hour), Until: start.Add(3 * time.Hour)}, []uint64{2, 3}},
+		{"after", Query{After: 2}, []uint64{3, 4}},
+		{"unknown caller", Query{Caller: "nobody"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, more, err := log.Query(&tt.query)
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			if more {
+				t.Fatal("Query reported more records without a limit")
+			}
+
+			var got []uint64
+			for _, record := range records {
+				got = append(got, record.Seq)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Query returned records %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	/* pages end where the next one starts */
+	records, more, err := log.Query(&Query{Caller: "portal", Limit: 2})
+	if err != nil || !more || len(records) != 2 || records[1].Seq != 2 {
+		t.Fatalf("first page = %d records (more %v, err %v)", len(records), more, err)
+	}
+	records, more, err = log.Query(&Query{Caller: "portal", Limit: 2, After: 2})
+	if err != nil || more || len(records) != 1 || records[0].Seq != 4 {
+		t.Fatalf("second page = %d records (more %v, err %v)", len(records), more, err)
+	}
+}
+
+/* records modified on disk after the index was built are not returned */
+func TestQueryDetectsModifiedRecord(t *testing.T) {
+	path := writeLog(t, 2)
+	log, err := Open(path, Options{Key: testKey})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer log.Close()
+
+	lines := readLines(t, path)
+	lines[0] = bytes.Replace(lines[0], []byte(`"alice"`), []byte(`"alicf"`), 1)
+	writeLines(t, path, lines)
+
+	if _, _, err := log.Query(&Query{}); err == nil || !strings.Contains(err.Error(), "hash does not match") {
+		t.Fatalf("Query error = %v, want a hash mismatch", err)
+	}
+}
+
+func TestKeyedChain(t *testing.T) {
+	path := writeLog(t, 2)
+
+	/* the chain cannot be verified, nor extended, without the key it was written with */
+	otherKey := bytes.Repeat([]byte("x"), 32)
+	var verifyErr *VerifyError
+	if _, err := VerifyFile(path, otherKey, ""); !errors.As(err, &verifyErr) || verifyErr.Line != 1 {
+		t.Fatalf("VerifyFile with another key error = %v, want a VerifyError at line 1", err)
+	}
+	if _, err := Open(path, Options{Key: otherKey}); !errors.As(err, &verifyErr) {
+		t.Fatalf("Open with another key error = %v, want a VerifyError", err)
+	}
+
+	/* short keys are refused */
+	short := testKey[:minKeySize-1]
+	if _, err := VerifyFile(path, short, ""); err == nil {
+		t.Fatal("VerifyFile accepted a short key")
+	}
+	if _, err := Open(filepath.Join(t.TempDir(), "audit.log"), Options{Key: short}); err == nil {
+		t.Fatal("Open accepted a short key")
+	}
+}
+
+func TestAppendObserver(t *testing.T) {
+	var observed []error
+	log, err := Open(filepath.Join(t.TempDir(), "audit.log"), Options{
+		Key:      testKey,
+		Observer: func(err error) { observed = append(observed, err) },
+	})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	appendRecord(t, log, "/srv/share")
+	log.Close()
+	if err := log.Append(&Record{Path: "/srv/share"}); err == nil {
+		t.Fatal("Append to a closed log succeeded")
+	}
+
+	if len(observed) != 2 || observed[0] != nil || observed[1] == nil {
+		t.Fatalf("observed %v, want a success then a failure", observed)
+	}
+}
+
+func TestFailedWriteIsRolledBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := Open(path, Options{Key: testKey})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer log.Close()
+	appendRecord(t, log, "/srv/share")
+
+	/* half a line reaches the file before the disk fills up */
+	log.writeLine = func(file *os.File, line []byte) error {
+		file.Write(line[:len(line)/2])
+		return errors.New("no space left on device")
+	}
+	if err := log.Append(&Record{Path: "/srv/share", Outcome: OutcomeSuccess}); err == nil {
+		t.Fatal("Append succeeded despite the failed write")
+	}
+
+	log.writeLine = writeLine
+	record := appendRecord(t, log, "/srv/other")
+	if record.Seq != 2 {
+		t.Fatalf("record after the failed write has seq %d, want 2", record.Seq)
+	}
+
+	if summary, err := VerifyFile(path, testKey, ""); err != nil || summary.Records != 2 {
+		t.Fatalf("VerifyFile = %+v, %v, want 2 chained records", summary, err)
+	}
+}
+
+func TestUnrecoverableWriteBreaksLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := Open(path, Options{Key: testKey})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer log.Close()
+
+	readOnly, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer readOnly.Close()
+
+	/* the torn line cannot be removed either (truncating a read-only descriptor fails) */
+	appendOnly := log.file
+	log.writeLine = func(file *os.File, line []byte) error {
+		file.Write(line[:len(line)/2])
+		log.file = readOnly
+		return errors.New("input/output error")
+	}
+	if err := log.Append(&Record{Path: "/srv/share", Outcome: OutcomeSuccess}); err == nil {
+		t.Fatal("Append succeeded despite the failed write")
+	}
+
+	log.file, log.writeLine = appendOnly, writeLine
+	if err := log.Append(&Record{Path: "/srv/share", Outcome: OutcomeSuccess}); err == nil {
+		t.Fatal("Append after an unrecoverable write succeeded")
+	}
+}
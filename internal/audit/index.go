@@ -0,0 +1,153 @@
+package audit
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+)
+
+/* position and searchable fields of a record, kept in memory */
+type indexEntry struct {
+	seq    uint64
+	offset int64
+	length int
+
+	time      time.Time
+	path      string
+	recursive bool
+	caller    string
+	endUser   string
+}
+
+/*
+in-memory index of the log, rebuilt when the log is opened and extended on
+every append
+
+records are only read from disk once they match the indexed filters
+*/
+type index struct {
+	/* every record in log order, entries[i].seq is i+1 */
+	entries []indexEntry
+
+	/* sequence numbers of the records with a given field value, in log order */
+	byTxn    map[string][]uint64
+	byCaller map[string][]uint64
+	byUser   map[string][]uint64
+	byEntity map[string][]uint64
+}
+
+func newIndex() *index {
+	return &index{
+		byTxn:    make(map[string][]uint64),
+		byCaller: make(map[string][]uint64),
+		byUser:   make(map[string][]uint64),
+		byEntity: make(map[string][]uint64),
+	}
+}
+
+/* indexes a record stored at offset */
+func (x *index) add(record *Record, offset int64, length int) {
+	x.entries = append(x.entries, indexEntry{
+		seq:       record.Seq,
+		offset:    offset,
+		length:    length,
+		time:      record.Time,
+		path:      record.Path,
+		recursive: record.Recursive,
+		caller:    record.Caller,
+		endUser:   record.EndUser,
+	})
+
+	addKey(x.byTxn, record.TxnID, record.Seq)
+	addKey(x.byCaller, record.Caller, record.Seq)
+	addKey(x.byUser, record.EndUser, record.Seq)
+	for _, entity := range entities(record) {
+		addKey(x.byEntity, entity, record.Seq)
+	}
+}
+
+func addKey(keys map[string][]uint64, key string, seq uint64) {
+	if key != "" {
+		keys[key] = append(keys[key], seq)
+	}
+}
+
+/* names under which a record can be found by entity: qualifier and numeric id */
+func entities(record *Record) []string {
+	if record.Entry == nil {
+		return nil
+	}
+
+	var names []string
+	if record.Entry.Qualifier != "" {
+		names = append(names, record.Entry.Qualifier)
+	}
+	if record.Entry.ID != nil {
+		names = append(names, strconv.FormatUint(uint64(*record.Entry.ID), 10))
+	}
+	return names
+}
+
+/*
+sequence numbers of the records that may match q, in log order
+
+the most selective equality filter picks the candidates, every candidate is
+still checked against the whole query
+*/
+func (x *index) candidates(q *Query) []uint64 {
+	var best []uint64
+	var found bool
+
+	for _, filter := range []struct {
+		keys  map[string][]uint64
+		value string
+	}{
+		{x.byTxn, q.TxnID},
+		{x.byCaller, q.Caller},
+		{x.byUser, q.EndUser},
+		{x.byEntity, q.Entity},
+	} {
+		if filter.value == "" {
+			continue
+		}
+		seqs := filter.keys[filter.value]
+		if !found || len(seqs) < len(best) {
+			best, found = seqs, true
+		}
+	}
+
+	if found {
+		return best
+	}
+
+	/* no equality filter, every record is a candidate */
+	all := make([]uint64, len(x.entries))
+	for i := range x.entries {
+		all[i] = uint64(i) + 1
+	}
+	return all
+}
+
+/* reports whether the indexed fields of entry match q */
+func (e *indexEntry) matches(q *Query) bool {
+	if q.Caller != "" && e.caller != q.Caller {
+		return false
+	}
+	if q.EndUser != "" && e.endUser != q.EndUser {
+		return false
+	}
+	if !q.Since.IsZero() && e.time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !e.time.Before(q.Until) {
+		return false
+	}
+
+	/* recursive changes above the prefix reached it as well */
+	if q.PathPrefix != "" && !aclmodel.ChangeWithin(e.path, e.recursive, q.PathPrefix) {
+		return false
+	}
+
+	return true
+}
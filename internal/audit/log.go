@@ -0,0 +1,195 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+/* shortest accepted audit key */
+const minKeySize = 32
+
+/* audit log options */
+type Options struct {
+	/* secret keying the hash chain, at least 32 bytes */
+	Key []byte
+
+	/* called after every append with its error (optional) */
+	Observer func(err error)
+}
+
+/* append-only, hash-chained audit log stored as JSON lines */
+type Log struct {
+	mu       sync.Mutex
+	file     *os.File
+	path     string
+	key      []byte
+	observer func(err error)
+
+	/* sequence number, hash and end of the last record written */
+	seq      uint64
+	lastHash string
+	size     int64
+
+	/* set when a failed write could not be undone, nothing can be chained after it */
+	broken error
+
+	index *index
+
+	/* writes and flushes a record line, replaced in tests */
+	writeLine func(file *os.File, line []byte) error
+}
+
+/*
+opens (or creates) the audit log at path
+
+the existing records are verified first, aclapi refuses to extend a log
+whose chain is already broken (or was written with another key)
+*/
+func Open(path string, opts Options) (*Log, error) {
+	if err := checkKey(opts.Key); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	/* read back for queries, written only at the end */
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	log := &Log{
+		file:      file,
+		path:      path,
+		key:       opts.Key,
+		observer:  opts.Observer,
+		index:     newIndex(),
+		writeLine: writeLine,
+	}
+	summary, err := scan(file, opts.Key, func(record *Record, offset int64, length int) {
+		log.index.add(record, offset, length)
+		log.size = offset + int64(length)
+	})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	log.seq = summary.Records
+	log.lastHash = summary.LastHash
+	return log, nil
+}
+
+/*
+chains record to the log and writes it to disk, filling in its sequence
+number, hashes and (if unset) timestamp
+*/
+func (l *Log) Append(record *Record) error {
+	err := l.append(record)
+	if l.observer != nil {
+		l.observer(err)
+	}
+	return err
+}
+
+func (l *Log) append(record *Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return errors.New("audit log is closed")
+	}
+	if l.broken != nil {
+		return fmt.Errorf("audit log unusable since a failed write: %w", l.broken)
+	}
+
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	record.Time = record.Time.UTC()
+	record.Seq = l.seq + 1
+	record.PrevHash = l.lastHash
+
+	hash, err := record.computeHash(l.key)
+	if err != nil {
+		return fmt.Errorf("failed to hash audit record: %w", err)
+	}
+	record.Hash = hash
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+
+	/* a single write per record, flushed before the change is reported */
+	if err := l.writeLine(l.file, append(line, '\n')); err != nil {
+		l.rollback()
+		return err
+	}
+
+	l.index.add(record, l.size, len(line)+1)
+	l.seq = record.Seq
+	l.lastHash = record.Hash
+	l.size += int64(len(line)) + 1
+	return nil
+}
+
+/* writes a record line and flushes it to disk */
+func writeLine(file *os.File, line []byte) error {
+	if _, err := file.Write(line); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit log: %w", err)
+	}
+	return nil
+}
+
+/*
+removes what a failed write left behind, a torn or unsynced line would break
+the chain of the next record, the log refuses appends if that fails too
+*/
+func (l *Log) rollback() {
+	err := l.file.Truncate(l.size)
+	if err == nil {
+		_, err = l.file.Seek(l.size, io.SeekStart)
+	}
+	if err != nil {
+		l.broken = fmt.Errorf("failed to remove a partially written audit record: %w", err)
+	}
+}
+
+/* rejects keys too short to protect the chain */
+func checkKey(key []byte) error {
+	if len(key) < minKeySize {
+		return fmt.Errorf("audit key must be at least %d bytes, got %d", minKeySize, len(key))
+	}
+	return nil
+}
+
+/* path of the log file */
+func (l *Log) Path() string {
+	return l.path
+}
+
+/* closes the log file, further appends fail */
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+/* filters of a history query, empty fields match every record */
+type Query struct {
+	/* records for this path or below it, and recursive changes above it */
+	PathPrefix string
+
+	/* user or group name, or numeric id, of the changed entry */
+	Entity string
+
+	/* common name of the client certificate and end user of the change */
+	Caller  string
+	EndUser string
+
+	TxnID string
+
+	/* time range, Since inclusive and Until exclusive */
+	Since time.Time
+	Until time.Time
+
+	/* only records after this sequence number (pagination) */
+	After uint64
+
+	/* maximum number of records returned */
+	Limit int
+}
+
+/*
+returns the records matching q in log order, at most q.Limit of them, and
+whether more records match after the last one returned
+*/
+func (l *Log) Query(q *Query) ([]*Record, bool, error) {
+	/* the index only grows, a snapshot of it is consistent */
+	l.mu.Lock()
+	if l.file == nil {
+		l.mu.Unlock()
+		return nil, false, errors.New("audit log is closed")
+	}
+	file := l.file
+	entries := l.index.entries
+	candidates := l.index.candidates(q)
+	l.mu.Unlock()
+
+	var records []*Record
+	for _, seq := range candidates {
+		if seq <= q.After {
+			continue
+		}
+
+		entry := &entries[seq-1]
+		if !entry.matches(q) {
+			continue
+		}
+
+		record, err := readEntry(file, l.key, entry)
+		if err != nil {
+			return nil, false, err
+		}
+		if !recordMatches(record, q) {
+			continue
+		}
+
+		if q.Limit > 0 && len(records) == q.Limit {
+			return records, true, nil
+		}
+		records = append(records, record)
+	}
+
+	return records, false, nil
+}
+
+/* reads an indexed record back from the log, checking that it was not modified since */
+func readEntry(file *os.File, key []byte, entry *indexEntry) (*Record, error) {
+	line := make([]byte, entry.length)
+	if _, err := file.ReadAt(line, entry.offset); err != nil {
+		return nil, fmt.Errorf("failed to read audit record %d: %w", entry.seq, err)
+	}
+
+	record, err := decodeRecord(line, key)
+	if err != nil {
+		return nil, fmt.Errorf("audit record %d: %w", entry.seq, err)
+	}
+	if record.Seq != entry.seq {
+		return nil, fmt.Errorf("audit record %d: found record %d instead, the log was modified", entry.seq, record.Seq)
+	}
+	return record, nil
+}
+
+/* checks the filters that are not covered by the index entries */
+func recordMatches(record *Record, q *Query) bool {
+	if q.TxnID != "" && record.TxnID != q.TxnID {
+		return false
+	}
+
+	if q.Entity != "" {
+		for _, entity := range entities(record) {
+			if entity == q.Entity {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+)
+
+/* outcome of an audited change */
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+)
+
+/* origin of changes not requested through the API */
+const (
+	/* made outside of aclapi (setfacl, chmod, ...) and noticed afterwards */
+	SourceExternal = "external"
+
+	/* made by aclapi to undo drift from the ACL it last set */
+	SourceRemediation = "remediation"
+)
+
+/* previous hash of the first record of a log */
+var genesisHash = strings.Repeat("0", sha256.Size*2)
+
+/*
+single audited ACL change, stored as one JSON line
+
+every record carries the hash of the previous one, so removing, reordering or
+editing records breaks the chain; hashes are keyed, so a chain cannot be
+rebuilt after editing without the audit key
+*/
+type Record struct {
+	/* position in the log, starting at 1 without gaps */
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+
+	/* origin of the change, empty for changes requested through the API */
+	Source string `json:"source,omitempty"`
+
+	/* who asked for the change */
+	Caller      string `json:"caller"`
+	CertSubject string `json:"cert_subject,omitempty"`
+	EndUser     string `json:"end_user,omitempty"`
+	Peer        string `json:"peer,omitempty"`
+
+	/* correlation with the caller and the aclapi/aclcore logs */
+	TxnID     string `json:"txn_id,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+
+	/* requested change */
+	Path      string          `json:"path"`
+	Action    aclmodel.Action `json:"action"`
+	Entry     *aclmodel.Entry `json:"entry,omitempty"`
+	Recursive bool            `json:"recursive,omitempty"`
+
+	/* ACL of the path before and after the change (nil if it could not be read) */
+	Before *aclmodel.ACL `json:"before,omitempty"`
+	After  *aclmodel.ACL `json:"after,omitempty"`
+
+	Outcome Outcome `json:"outcome"`
+	Error   string  `json:"error,omitempty"`
+
+	/* hash chain */
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+/* hex HMAC-SHA256 (keyed with the audit key) of the record encoded without its own hash */
+func (r *Record) computeHash(key []byte) (string, error) {
+	unsigned := *r
+	unsigned.Hash = ""
+
+	data, err := json.Marshal(&unsigned)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
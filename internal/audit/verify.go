@@ -0,0 +1,161 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+/* state of a log whose chain is intact */
+type Summary struct {
+	/* number of records, also the sequence number of the last one */
+	Records uint64
+
+	/*
+		hash of the last record, compare it with a copy kept elsewhere to detect
+		records removed from the end of the log
+	*/
+	LastHash string
+
+	/* whether the record hash passed to Verify was found in the log */
+	AnchorFound bool
+}
+
+/* first inconsistency found in a log */
+type VerifyError struct {
+	/* line of the log (starting at 1) */
+	Line int
+
+	/* sequence number expected at that line */
+	Seq uint64
+
+	Reason string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("audit log broken at line %d (record %d): %s", e.Line, e.Seq, e.Reason)
+}
+
+/* checks the hash chain of the log stored at path, see Verify */
+func VerifyFile(path string, key []byte, anchor string) (*Summary, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	return Verify(file, key, anchor)
+}
+
+/*
+checks that the records read from r form an unbroken chain: sequence numbers
+without gaps, every record linked to the previous one and every hash matching
+the record's content, keyed with the audit key the log was written with
+
+anchor is the hash of a record reported by an earlier verification (or
+empty), its absence reveals records removed from the end of the log
+*/
+func Verify(r io.Reader, key []byte, anchor string) (*Summary, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+
+	var anchorFound bool
+	summary, err := scan(r, key, func(record *Record, offset int64, length int) {
+		if anchor != "" && record.Hash == anchor {
+			anchorFound = true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	summary.AnchorFound = anchorFound
+	return summary, nil
+}
+
+/* verifies the records read from r, calling fn with every intact record and its position */
+func scan(r io.Reader, key []byte, fn func(record *Record, offset int64, length int)) (*Summary, error) {
+	summary := &Summary{LastHash: genesisHash}
+	reader := bufio.NewReader(r)
+
+	var offset int64
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(data) == 0 {
+				return summary, nil
+			}
+			return nil, brokenAt(line, summary, "truncated record")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+
+		record, err := checkRecord(data, key, summary)
+		if err != nil {
+			return nil, brokenAt(line, summary, err.Error())
+		}
+
+		fn(record, offset, len(data))
+		offset += int64(len(data))
+	}
+}
+
+/* checks a single line against the chain so far and advances the summary */
+func checkRecord(line []byte, key []byte, summary *Summary) (*Record, error) {
+	record, err := decodeRecord(line, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if record.Seq != summary.Records+1 {
+		return nil, fmt.Errorf("found record %d, records are missing or out of order", record.Seq)
+	}
+
+	if record.PrevHash != summary.LastHash {
+		return nil, errors.New("previous hash does not match, records are missing or out of order")
+	}
+
+	summary.Records = record.Seq
+	summary.LastHash = record.Hash
+	return record, nil
+}
+
+/*
+decodes a line of the log, checking that it is exactly the canonical encoding
+of a record whose hash matches its content
+*/
+func decodeRecord(line []byte, key []byte) (*Record, error) {
+	var record Record
+	if err := json.Unmarshal(line, &record); err != nil {
+		return nil, fmt.Errorf("malformed record: %v", err)
+	}
+
+	hash, err := record.computeHash(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash record: %v", err)
+	}
+	if record.Hash != hash {
+		return nil, errors.New("hash does not match, the record was modified (or the key is wrong)")
+	}
+
+	/* fields unknown to the record or reformatted JSON are modifications too */
+	encoded, err := json.Marshal(&record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode record: %v", err)
+	}
+	if !bytes.Equal(encoded, bytes.TrimSuffix(line, []byte("\n"))) {
+		return nil, errors.New("record is not in canonical form, the record was modified")
+	}
+
+	return &record, nil
+}
+
+func brokenAt(line int, summary *Summary, reason string) *VerifyError {
+	return &VerifyError{Line: line, Seq: summary.Records + 1, Reason: reason}
+}
@@ -0,0 +1,57 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+)
+
+/* single entry change, applied to one path or a whole tree */
+type ApplyRequest struct {
+	TxnID     string
+	Action    aclmodel.Action
+	Entry     aclmodel.Entry
+	Path      string
+	Recursive bool
+}
+
+/* file or directory visited by Walk */
+type WalkEntry struct {
+	Path  string
+	IsDir bool
+}
+
+/* called for every path visited by Walk, returning an error stops the walk */
+type WalkFunc func(entry *WalkEntry) error
+
+/*
+backend performing ACL operations on the filesystem
+
+errors caused by a failed system call wrap the syscall.Errno, so callers can
+map them to meaningful status codes regardless of the implementation
+*/
+type Backend interface {
+	/* applies a single entry change, returning a human readable summary */
+	Apply(ctx context.Context, req *ApplyRequest) (string, error)
+
+	/* reads the access and default ACL of a path */
+	Get(ctx context.Context, path string) (*aclmodel.ACL, error)
+
+	/* replaces the access and default ACL of a path */
+	Set(ctx context.Context, path string, acl *aclmodel.ACL) error
+
+	/* removes all extended entries and the default ACL of a path */
+	Strip(ctx context.Context, path string) error
+
+	/* visits root and everything below it, symbolic links are not followed */
+	Walk(ctx context.Context, root string, fn WalkFunc) error
+
+	/* releases resources held by the backend */
+	Close() error
+}
+
+/* implemented by backends relying on a daemon that can become unreachable */
+type Prober interface {
+	/* checks that the backend can currently perform operations */
+	Probe(ctx context.Context) error
+}
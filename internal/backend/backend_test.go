@@ -0,0 +1,345 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+)
+
+func id(v uint32) *uint32 {
+	return &v
+}
+
+/* directory on tmpfs, which supports POSIX ACLs without mount options */
+func tmpfsDir(t *testing.T) string {
+	t.Helper()
+
+	var fs unix.Statfs_t
+	if err := unix.Statfs("/dev/shm", &fs); err != nil || fs.Type != unix.TMPFS_MAGIC {
+		t.Skip("/dev/shm is not a tmpfs")
+	}
+
+	dir, err := os.MkdirTemp("/dev/shm", "backend-test-")
+	if err != nil {
+		t.Skipf("cannot create a directory on tmpfs: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return dir
+}
+
+/* tree with a directory, a file and a symbolic link below root */
+func testTree(t *testing.T) (root, sub, file string) {
+	t.Helper()
+
+	root = tmpfsDir(t)
+	sub = filepath.Join(root, "sub")
+	file = filepath.Join(root, "data")
+
+	if err := os.Mkdir(sub, 0o750); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(file, nil, 0o640); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Chmod(file, 0o640); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	if err := os.Symlink(file, filepath.Join(root, "link")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	return root, sub, file
+}
+
+/* finds the entry of a principal, nil if absent */
+func find(entries []aclmodel.Entry, tag aclmodel.Tag, uid *uint32) *aclmodel.Entry {
+	want := aclmodel.Entry{Tag: tag, ID: uid}
+	for i := range entries {
+		if entries[i].SamePrincipal(&want) {
+			return &entries[i]
+		}
+	}
+	return nil
+}
+
+/* reads the ACL of path, failing the test on errors */
+func mustGet(t *testing.T, b Backend, path string) *aclmodel.ACL {
+	t.Helper()
+
+	acl, err := b.Get(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Get(%s): %v", path, err)
+	}
+	return acl
+}
+
+func TestXattrRoundTrip(t *testing.T) {
+	_, _, file := testTree(t)
+	x := NewXattr()
+	ctx := context.Background()
+
+	named := aclmodel.Entry{Tag: aclmodel.TagUser, ID: id(1000), Perms: aclmodel.PermRead | aclmodel.PermExecute}
+	if _, err := x.Apply(ctx, &ApplyRequest{Action: aclmodel.ActionAdd, Entry: named, Path: file}); err != nil {
+		if errors.Is(err, unix.EOPNOTSUPP) {
+			t.Skip("tmpfs mounted without POSIX ACL support")
+		}
+		t.Fatalf("Apply: %v", err)
+	}
+
+	acl := mustGet(t, x, file)
+	if entry := find(acl.Access, aclmodel.TagUser, id(1000)); entry == nil || entry.Perms != named.Perms {
+		t.Fatalf("access ACL %+v lacks user:1000:r-x", acl.Access)
+	}
+
+	/* the mask covers the owning group (r--) and the named entry (r-x) */
+	if mask := find(acl.Access, aclmodel.TagMask, nil); mask == nil || mask.Perms != named.Perms {
+		t.Fatalf("access ACL %+v has no r-x mask", acl.Access)
+	}
+
+	/* writing back what was read changes nothing */
+	if err := x.Set(ctx, file, acl); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if again := mustGet(t, x, file); !reflect.DeepEqual(again.Access, acl.Access) {
+		t.Fatalf("ACL after Set = %+v, want %+v", again.Access, acl.Access)
+	}
+
+	/* modifying and removing the entry */
+	named.Perms = aclmodel.PermRead
+	if _, err := x.Apply(ctx, &ApplyRequest{Action: aclmodel.ActionModify, Entry: named, Path: file}); err != nil {
+		t.Fatalf("Apply modify: %v", err)
+	}
+	if entry := find(mustGet(t, x, file).Access, aclmodel.TagUser, id(1000)); entry == nil || entry.Perms != aclmodel.PermRead {
+		t.Fatalf("user:1000 entry = %+v, want r--", entry)
+	}
+
+	if _, err := x.Apply(ctx, &ApplyRequest{Action: aclmodel.ActionRemove, Entry: named, Path: file}); err != nil {
+		t.Fatalf("Apply remove: %v", err)
+	}
+	if entry := find(mustGet(t, x, file).Access, aclmodel.TagUser, id(1000)); entry != nil {
+		t.Fatalf("user:1000 entry %+v still present after remove", entry)
+	}
+}
+
+func TestXattrStrip(t *testing.T) {
+	_, _, file := testTree(t)
+	x := NewXattr()
+	ctx := context.Background()
+
+	named := aclmodel.Entry{Tag: aclmodel.TagGroup, ID: id(2000), Perms: aclmodel.PermRWX}
+	if _, err := x.Apply(ctx, &ApplyRequest{Action: aclmodel.ActionAdd, Entry: named, Path: file}); err != nil {
+		if errors.Is(err, unix.EOPNOTSUPP) {
+			t.Skip("tmpfs mounted without POSIX ACL support")
+		}
+		t.Fatalf("Apply: %v", err)
+	}
+
+	if err := x.Strip(ctx, file); err != nil {
+		t.Fatalf("Strip: %v", err)
+	}
+
+	/* the owning group gets its own permissions back instead of the mask */
+	if acl := mustGet(t, x, file); len(acl.Access) != 3 {
+		t.Fatalf("access ACL after Strip = %+v, want the owner, owning group and other only", acl.Access)
+	}
+	if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0o640 {
+		t.Fatalf("mode after Strip = %v, %v, want 0640", info.Mode().Perm(), err)
+	}
+}
+
+func TestXattrRecursiveDefault(t *testing.T) {
+	root, sub, file := testTree(t)
+	x := NewXattr()
+	ctx := context.Background()
+
+	entry := aclmodel.Entry{Tag: aclmodel.TagGroup, ID: id(2000), Perms: aclmodel.PermRead | aclmodel.PermWrite, Default: true}
+
+	/* default entries only exist on directories */
+	_, err := x.Apply(ctx, &ApplyRequest{Action: aclmodel.ActionAdd, Entry: entry, Path: file})
+	if errors.Is(err, unix.EOPNOTSUPP) {
+		t.Skip("tmpfs mounted without POSIX ACL support")
+	}
+	if !errors.Is(err, syscall.ENOTDIR) {
+		t.Fatalf("Apply of a default entry on a file error = %v, want ENOTDIR", err)
+	}
+
+	/* recursively, files are skipped */
+	if _, err := x.Apply(ctx, &ApplyRequest{Action: aclmodel.ActionAdd, Entry: entry, Path: root, Recursive: true}); err != nil {
+		t.Fatalf("recursive Apply: %v", err)
+	}
+
+	for _, dir := range []string{root, sub} {
+		acl := mustGet(t, x, dir)
+		if got := find(acl.Default, aclmodel.TagGroup, id(2000)); got == nil || got.Perms != entry.Perms {
+			t.Fatalf("default ACL of %s = %+v, want group:2000:rw-", dir, acl.Default)
+		}
+
+		/* the first default entry starts from the access ACL */
+		if find(acl.Default, aclmodel.TagUserObj, nil) == nil || find(acl.Default, aclmodel.TagMask, nil) == nil {
+			t.Fatalf("default ACL of %s = %+v, want base entries and a mask", dir, acl.Default)
+		}
+	}
+	if acl := mustGet(t, x, file); len(acl.Default) != 0 {
+		t.Fatalf("file got a default ACL %+v", acl.Default)
+	}
+}
+
+func TestXattrApplyErrors(t *testing.T) {
+	_, _, file := testTree(t)
+	x := NewXattr()
+	ctx := context.Background()
+
+	tests := map[string]*ApplyRequest{
+		"remove owner entry": {Action: aclmodel.ActionRemove, Entry: aclmodel.Entry{Tag: aclmodel.TagUserObj}, Path: file},
+		"unresolved name":    {Action: aclmodel.ActionAdd, Entry: aclmodel.Entry{Tag: aclmodel.TagUser}, Path: file},
+		"unknown action":     {Action: "toggle", Entry: aclmodel.Entry{Tag: aclmodel.TagOther}, Path: file},
+	}
+
+	for name, req := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := x.Apply(ctx, req)
+			if errors.Is(err, unix.EOPNOTSUPP) {
+				t.Skip("tmpfs mounted without POSIX ACL support")
+			}
+			if !errors.Is(err, syscall.EINVAL) {
+				t.Fatalf("Apply error = %v, want EINVAL", err)
+			}
+		})
+	}
+}
+
+func TestXattrConditionalExecute(t *testing.T) {
+	_, sub, file := testTree(t)
+	x := NewXattr()
+	ctx := context.Background()
+
+	entry := aclmodel.Entry{Tag: aclmodel.TagUser, ID: id(1000), Perms: aclmodel.PermRead | aclmodel.PermConditionalExecute}
+
+	/* X grants execute on directories only, the file is not executable by anyone */
+	want := map[string]aclmodel.Perm{
+		file: aclmodel.PermRead,
+		sub:  aclmodel.PermRead | aclmodel.PermExecute,
+	}
+	for path, perms := range want {
+		if _, err := x.Apply(ctx, &ApplyRequest{Action: aclmodel.ActionAdd, Entry: entry, Path: path}); err != nil {
+			if errors.Is(err, unix.EOPNOTSUPP) {
+				t.Skip("tmpfs mounted without POSIX ACL support")
+			}
+			t.Fatalf("Apply(%s): %v", path, err)
+		}
+
+		if got := find(mustGet(t, x, path).Access, aclmodel.TagUser, id(1000)); got == nil || got.Perms != perms {
+			t.Fatalf("user:1000 entry of %s = %+v, want %s", path, got, perms)
+		}
+	}
+}
+
+func TestXattrWalk(t *testing.T) {
+	root, sub, file := testTree(t)
+
+	var visited []string
+	err := NewXattr().Walk(context.Background(), root, func(entry *WalkEntry) error {
+		visited = append(visited, entry.Path)
+		if isDir := entry.Path != file; entry.IsDir != isDir {
+			t.Errorf("%s reported with IsDir=%t", entry.Path, entry.IsDir)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+
+	/* the symbolic link is not visited */
+	want := []string{root, file, sub}
+	if !slices.Equal(visited, want) {
+		t.Fatalf("Walk visited %v, want %v", visited, want)
+	}
+
+	/* a cancelled walk stops */
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewXattr().Walk(ctx, root, func(*WalkEntry) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled Walk error = %v, want context.Canceled", err)
+	}
+}
+
+/* backend answering Get with a fixed result, counting the calls */
+type fakeBackend struct {
+	Backend
+
+	acl   *aclmodel.ACL
+	err   error
+	gets  int
+	probe error
+}
+
+func (f *fakeBackend) Get(ctx context.Context, path string) (*aclmodel.ACL, error) {
+	f.gets++
+	return f.acl, f.err
+}
+
+func (f *fakeBackend) Probe(ctx context.Context) error {
+	return f.probe
+}
+
+func TestDirectReadsFallback(t *testing.T) {
+	directACL := &aclmodel.ACL{Access: []aclmodel.Entry{{Tag: aclmodel.TagUserObj}}}
+	coreACL := &aclmodel.ACL{Access: []aclmodel.Entry{{Tag: aclmodel.TagOther}}}
+
+	tests := []struct {
+		name      string
+		directErr error
+		fallback  bool
+	}{
+		{name: "direct read succeeds"},
+		{name: "access denied", directErr: &os.PathError{Op: "getxattr", Path: "/srv", Err: syscall.EACCES}, fallback: true},
+		{name: "operation not permitted", directErr: syscall.EPERM, fallback: true},
+		{name: "missing path", directErr: &os.PathError{Op: "stat", Path: "/srv", Err: syscall.ENOENT}},
+		{name: "ACLs not supported", directErr: syscall.EOPNOTSUPP},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core := &fakeBackend{acl: coreACL}
+			reads := WithDirectReads(core)
+			direct := &fakeBackend{acl: directACL, err: test.directErr}
+			reads.direct = direct
+
+			acl, err := reads.Get(context.Background(), "/srv")
+
+			switch {
+			case test.fallback:
+				if err != nil || acl != coreACL || core.gets != 1 {
+					t.Fatalf("Get = %+v, %v after %d backend reads, want the backend ACL", acl, err, core.gets)
+				}
+			case test.directErr != nil:
+				if !errors.Is(err, test.directErr) || core.gets != 0 {
+					t.Fatalf("Get error = %v after %d backend reads, want the direct error only", err, core.gets)
+				}
+			default:
+				if err != nil || acl != directACL || core.gets != 0 {
+					t.Fatalf("Get = %+v, %v after %d backend reads, want the direct ACL", acl, err, core.gets)
+				}
+			}
+			if direct.gets != 1 {
+				t.Fatalf("direct reader called %d times, want once", direct.gets)
+			}
+		})
+	}
+
+	/* probes reach the wrapped backend */
+	down := errors.New("down")
+	if err := WithDirectReads(&fakeBackend{probe: down}).Probe(context.Background()); err != down {
+		t.Fatalf("Probe error = %v, want the backend's", err)
+	}
+}
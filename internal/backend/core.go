@@ -0,0 +1,62 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclcore"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+)
+
+/* backend delegating every operation to the aclcore root daemon */
+type Core struct {
+	Client *aclcore.Client
+}
+
+/* creates a backend using client */
+func NewCore(client *aclcore.Client) *Core {
+	return &Core{Client: client}
+}
+
+/* applies a single entry change through aclcore */
+func (c *Core) Apply(ctx context.Context, req *ApplyRequest) (string, error) {
+	return c.Client.Apply(ctx, &aclcore.ApplyRequest{
+		TxnID:     req.TxnID,
+		Action:    req.Action,
+		Entry:     req.Entry,
+		Path:      req.Path,
+		Recursive: req.Recursive,
+	})
+}
+
+/* reads the ACL of a path through aclcore */
+func (c *Core) Get(ctx context.Context, path string) (*aclmodel.ACL, error) {
+	return c.Client.Get(ctx, path)
+}
+
+/* replaces the ACL of a path through aclcore */
+func (c *Core) Set(ctx context.Context, path string, acl *aclmodel.ACL) error {
+	return c.Client.Set(ctx, path, acl)
+}
+
+/* strips the ACL of a path through aclcore */
+func (c *Core) Strip(ctx context.Context, path string) error {
+	return c.Client.Strip(ctx, path)
+}
+
+/* walks a tree through aclcore (it can see what aclapi cannot) */
+func (c *Core) Walk(ctx context.Context, root string, fn WalkFunc) error {
+	return c.Client.Walk(ctx, root, func(entry *aclcore.WalkEntry) error {
+		return fn(&WalkEntry{Path: entry.Path, IsDir: entry.IsDir})
+	})
+}
+
+/* checks that aclcore is reachable and speaks the protocol */
+func (c *Core) Probe(ctx context.Context) error {
+	return c.Client.Ping(ctx)
+}
+
+/* closes the aclcore connections */
+func (c *Core) Close() error {
+	c.Client.Close()
+	return nil
+}
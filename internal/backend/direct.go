@@ -0,0 +1,51 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"syscall"
+
+	"go.uber.org/zap"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/tracing"
+)
+
+/*
+backend serving reads with aclapi's own privileges
+reading an ACL only needs search permission on the parent directories, so
+most reads never reach the wrapped (privileged) backend, which is only asked
+when aclapi is denied access
+*/
+type DirectReads struct {
+	Backend
+
+	/* unprivileged reader tried first */
+	direct Backend
+}
+
+/* wraps next so that reads are attempted directly first */
+func WithDirectReads(next Backend) *DirectReads {
+	return &DirectReads{Backend: next, direct: NewXattr()}
+}
+
+/* reads the ACL of a path directly, falling back to the wrapped backend on EACCES/EPERM */
+func (d *DirectReads) Get(ctx context.Context, path string) (*aclmodel.ACL, error) {
+	acl, err := d.direct.Get(ctx, path)
+	if errors.Is(err, syscall.EACCES) || errors.Is(err, syscall.EPERM) {
+		tracing.Logger(ctx).Debug("Direct ACL read denied, falling back to backend",
+			zap.String("path", path),
+			zap.Error(err),
+		)
+		return d.Backend.Get(ctx, path)
+	}
+	return acl, err
+}
+
+/* probes the wrapped backend, if it can be probed */
+func (d *DirectReads) Probe(ctx context.Context) error {
+	if prober, ok := d.Backend.(Prober); ok {
+		return prober.Probe(ctx)
+	}
+	return nil
+}
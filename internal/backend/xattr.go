@@ -0,0 +1,247 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/posixacl"
+)
+
+/*
+backend manipulating the POSIX ACL extended attributes directly
+
+it runs with the privileges of aclapi itself, so it only works on files
+aclapi owns (or with CAP_FOWNER), which makes it suitable for development
+boxes and shares delegated to the aclapi user
+*/
+type Xattr struct{}
+
+/* creates the direct xattr backend */
+func NewXattr() *Xattr {
+	return &Xattr{}
+}
+
+/* applies a single entry change to a path, or to every path below it */
+func (x *Xattr) Apply(ctx context.Context, req *ApplyRequest) (string, error) {
+	if !req.Recursive {
+		if err := x.applyEntry(req.Path, req.Action, &req.Entry); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s %s entry on %s", req.Action, req.Entry.Tag, req.Path), nil
+	}
+
+	var count int
+	err := x.Walk(ctx, req.Path, func(entry *WalkEntry) error {
+		/* default entries only exist on directories */
+		if req.Entry.Default && !entry.IsDir {
+			return nil
+		}
+		if err := x.applyEntry(entry.Path, req.Action, &req.Entry); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s %s entry on %d paths below %s", req.Action, req.Entry.Tag, count, req.Path), nil
+}
+
+/* reads the access and default ACL of a path */
+func (x *Xattr) Get(ctx context.Context, path string) (*aclmodel.ACL, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return readACL(path, info)
+}
+
+/* replaces the access and default ACL of a path */
+func (x *Xattr) Set(ctx context.Context, path string, acl *aclmodel.ACL) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return writeACL(path, info, acl)
+}
+
+/* removes all extended entries and the default ACL of a path */
+func (x *Xattr) Strip(ctx context.Context, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	acl, err := readACL(path, info)
+	if err != nil {
+		return err
+	}
+
+	/* the owning group keeps its own permissions, not the mask */
+	var minimal []aclmodel.Entry
+	for _, entry := range acl.Access {
+		if !entry.Tag.Named() && entry.Tag != aclmodel.TagMask {
+			minimal = append(minimal, entry)
+		}
+	}
+
+	return writeACL(path, info, &aclmodel.ACL{Access: minimal})
+}
+
+/* visits root and everything below it, symbolic links are skipped */
+func (x *Xattr) Walk(ctx context.Context, root string, fn WalkFunc) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		return fn(&WalkEntry{Path: path, IsDir: d.IsDir()})
+	})
+}
+
+/* nothing to release */
+func (x *Xattr) Close() error {
+	return nil
+}
+
+/* read-modify-write of a single entry on one path */
+func (x *Xattr) applyEntry(path string, action aclmodel.Action, change *aclmodel.Entry) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if change.Default && !info.IsDir() {
+		return &os.PathError{Op: "apply default entry", Path: path, Err: syscall.ENOTDIR}
+	}
+	if change.Tag.Named() && change.ID == nil {
+		return fmt.Errorf("%s entry without a resolved ID: %w", change.Tag, syscall.EINVAL)
+	}
+
+	acl, err := readACL(path, info)
+	if err != nil {
+		return err
+	}
+
+	entries := &acl.Access
+	if change.Default {
+		/* the first default entry starts from a copy of the access ACL */
+		if len(acl.Default) == 0 {
+			acl.Default = baseEntries(acl.Access, true)
+		}
+		entries = &acl.Default
+	}
+
+	entry := *change
+	entry.Perms = resolvePerms(entry.Perms, info)
+
+	index := slices.IndexFunc(*entries, func(e aclmodel.Entry) bool {
+		return e.SamePrincipal(&entry)
+	})
+
+	switch action {
+	case aclmodel.ActionAdd, aclmodel.ActionModify:
+		if index >= 0 {
+			(*entries)[index].Perms = entry.Perms
+		} else {
+			*entries = append(*entries, entry)
+		}
+	case aclmodel.ActionRemove:
+		if !entry.Tag.Named() && entry.Tag != aclmodel.TagMask {
+			return fmt.Errorf("%s entry cannot be removed: %w", entry.Tag, syscall.EINVAL)
+		}
+		if index >= 0 {
+			*entries = slices.Delete(*entries, index, index+1)
+		}
+	default:
+		return fmt.Errorf("unknown action %q: %w", action, syscall.EINVAL)
+	}
+
+	/* an explicitly set mask is kept as is */
+	if entry.Tag != aclmodel.TagMask || action == aclmodel.ActionRemove {
+		*entries = posixacl.CalcMask(*entries)
+	}
+
+	return writeACL(path, info, acl)
+}
+
+/* turns X into x when the target is a directory or executable for someone */
+func resolvePerms(perms aclmodel.Perm, info os.FileInfo) aclmodel.Perm {
+	if perms&aclmodel.PermConditionalExecute != 0 {
+		perms &^= aclmodel.PermConditionalExecute
+		if info.IsDir() || info.Mode().Perm()&0o111 != 0 {
+			perms |= aclmodel.PermExecute
+		}
+	}
+	return perms
+}
+
+/* copies the owner, owning group and other entries of a list */
+func baseEntries(entries []aclmodel.Entry, asDefault bool) []aclmodel.Entry {
+	var base []aclmodel.Entry
+	for _, entry := range entries {
+		if !entry.Tag.Named() && entry.Tag != aclmodel.TagMask {
+			entry.Default = asDefault
+			base = append(base, entry)
+		}
+	}
+	return base
+}
+
+/* reads both ACLs of a path, synthesizing the access ACL from the mode if unset */
+func readACL(path string, info os.FileInfo) (*aclmodel.ACL, error) {
+	access, err := posixacl.Read(path, false)
+	if err != nil {
+		return nil, err
+	}
+	if access == nil {
+		access = posixacl.FromMode(info.Mode())
+	}
+
+	acl := &aclmodel.ACL{Access: access}
+	if !info.IsDir() {
+		return acl, nil
+	}
+
+	if acl.Default, err = posixacl.Read(path, true); err != nil {
+		return nil, err
+	}
+
+	return acl, nil
+}
+
+/*
+writes both ACLs of a path
+a minimal access ACL is stored in the mode bits only, like setfacl does
+*/
+func writeACL(path string, info os.FileInfo, acl *aclmodel.ACL) error {
+	if posixacl.IsMinimal(acl.Access) {
+		if err := os.Chmod(path, posixacl.ToMode(info.Mode(), acl.Access)); err != nil {
+			return err
+		}
+		if err := posixacl.Write(path, false, nil); err != nil {
+			return err
+		}
+	} else if err := posixacl.Write(path, false, acl.Access); err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return nil
+	}
+
+	return posixacl.Write(path, true, acl.Default)
+}
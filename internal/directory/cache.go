@@ -0,0 +1,132 @@
+package directory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+/* cached lookup result (value is nil for negative entries) */
+type cacheEntry struct {
+	value   any
+	err     error
+	expires time.Time
+}
+
+/* directory wrapper caching lookups for a bounded time */
+type Cache struct {
+	dir         Directory
+	ttl         time.Duration
+	negativeTTL time.Duration
+	maxEntries  int
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+/* wraps dir with a TTL cache (not found results are cached for negativeTTL) */
+func NewCache(dir Directory, ttl, negativeTTL time.Duration, maxEntries int) *Cache {
+	return &Cache{
+		dir:         dir,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		maxEntries:  maxEntries,
+		entries:     make(map[string]cacheEntry),
+	}
+}
+
+/* resolves a user by name or numeric uid */
+func (c *Cache) LookupUser(ctx context.Context, name string) (*User, error) {
+	value, err := c.get("user:"+name, func() (any, error) {
+		return c.dir.LookupUser(ctx, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*User), nil
+}
+
+/* resolves a group by name or numeric gid */
+func (c *Cache) LookupGroup(ctx context.Context, name string) (*Group, error) {
+	value, err := c.get("group:"+name, func() (any, error) {
+		return c.dir.LookupGroup(ctx, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*Group), nil
+}
+
+/* expands a group to the names of all its members */
+func (c *Cache) GroupMembers(ctx context.Context, name string) ([]string, error) {
+	value, err := c.get("members:"+name, func() (any, error) {
+		return c.dir.GroupMembers(ctx, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]string), nil
+}
+
+/* closes the wrapped directory */
+func (c *Cache) Close() error {
+	return c.dir.Close()
+}
+
+/* drops all cached entries */
+func (c *Cache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]cacheEntry)
+}
+
+/* returns the cached value for key or loads it */
+func (c *Cache) get(key string, load func() (any, error)) (any, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+
+	if ok && now.Before(entry.expires) {
+		return entry.value, entry.err
+	}
+
+	value, err := load()
+
+	/* only successful and not found results are cached, transient errors are not */
+	switch {
+	case err == nil:
+		c.put(key, cacheEntry{value: value, expires: now.Add(c.ttl)})
+	case errors.Is(err, ErrNotFound):
+		c.put(key, cacheEntry{err: err, expires: now.Add(c.negativeTTL)})
+	}
+
+	return value, err
+}
+
+/* stores an entry, evicting expired (or arbitrary) entries when the cache is full */
+func (c *Cache) put(key string, entry cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= c.maxEntries {
+		now := time.Now()
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+
+		/* still full: evict arbitrary entries (map iteration order is random) */
+		for k := range c.entries {
+			if len(c.entries) < c.maxEntries {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+
+	c.entries[key] = entry
+}
@@ -0,0 +1,71 @@
+package directory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/config"
+)
+
+var (
+	/* returned when a user or group does not exist in the directory */
+	ErrNotFound = errors.New("principal not found")
+)
+
+/* resolved user account */
+type User struct {
+	Name string
+	UID  uint32
+	GID  uint32
+}
+
+/* resolved group */
+type Group struct {
+	Name    string
+	GID     uint32
+	Members []string
+}
+
+/*
+principal directory resolves ACL entities to accounts
+implementations must return ErrNotFound (possibly wrapped) for unknown principals
+*/
+type Directory interface {
+	/* resolves a user by name or numeric uid */
+	LookupUser(ctx context.Context, name string) (*User, error)
+
+	/* resolves a group by name or numeric gid */
+	LookupGroup(ctx context.Context, name string) (*Group, error)
+
+	/* expands a group to the names of all its members (including primary group members) */
+	GroupMembers(ctx context.Context, name string) ([]string, error)
+
+	/* releases the connections of the directory, called once no lookups run any more */
+	Close() error
+}
+
+/* creates the directory configured in the daemon config */
+func New(cfg *config.Directory) (Directory, error) {
+	var dir Directory
+
+	switch cfg.Backend {
+	case "nss":
+		dir = NewNSS()
+	case "ldap":
+		ldapDir, err := NewLDAP(&cfg.LDAP)
+		if err != nil {
+			return nil, err
+		}
+		dir = ldapDir
+	default:
+		return nil, fmt.Errorf("unknown directory backend %q", cfg.Backend)
+	}
+
+	/* wrap the directory with a lookup cache unless disabled */
+	if !cfg.Cache.Disabled {
+		dir = NewCache(dir, cfg.Cache.TTL, cfg.Cache.NegativeTTL, cfg.Cache.MaxEntries)
+	}
+
+	return dir, nil
+}
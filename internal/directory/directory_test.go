@@ -0,0 +1,466 @@
+package directory
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/config"
+)
+
+/* in-memory LDAP server answering the searches made by the directory */
+type standIn struct {
+	entries []*ldap.Entry
+
+	mu       sync.Mutex
+	dials    int
+	closes   int
+	searches []string
+
+	/* searches wait for block to be closed when set */
+	block chan struct{}
+
+	/* the next search fails with a network error */
+	failNext bool
+}
+
+type standInConn struct {
+	ldap.Client
+	server *standIn
+}
+
+func (s *standIn) dial() (ldap.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dials++
+	return &standInConn{server: s}, nil
+}
+
+func (s *standIn) stats() (dials, searches int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dials, len(s.searches)
+}
+
+func (c *standInConn) Bind(username, password string) error { return nil }
+
+func (c *standInConn) Close() error {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+	c.server.closes++
+	return nil
+}
+
+func (c *standInConn) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	s := c.server
+
+	s.mu.Lock()
+	s.searches = append(s.searches, req.Filter)
+	block, fail := s.block, s.failNext
+	s.failNext = false
+	s.mu.Unlock()
+
+	if block != nil {
+		<-block
+	}
+	if fail {
+		return nil, ldap.NewError(ldap.ErrorNetwork, errors.New("connection reset by peer"))
+	}
+
+	result := &ldap.SearchResult{}
+	for _, entry := range s.entries {
+		if strings.HasSuffix(strings.ToLower(entry.DN), strings.ToLower(req.BaseDN)) && matches(req.Filter, entry) {
+			result.Entries = append(result.Entries, entry)
+		}
+	}
+	return result, nil
+}
+
+/* evaluates the subset of the filter syntax used by the directory (&, | and equality) */
+func matches(filter string, entry *ldap.Entry) bool {
+	ok, rest := match(filter, entry)
+	return ok && rest == ""
+}
+
+func match(filter string, entry *ldap.Entry) (bool, string) {
+	filter = filter[1:]
+
+	if op := filter[0]; op == '&' || op == '|' {
+		result := op == '&'
+		for filter = filter[1:]; filter[0] == '('; {
+			var ok bool
+			ok, filter = match(filter, entry)
+			if op == '&' {
+				result = result && ok
+			} else {
+				result = result || ok
+			}
+		}
+		return result, filter[1:]
+	}
+
+	end := strings.IndexByte(filter, ')')
+	attribute, value, _ := strings.Cut(filter[:end], "=")
+	value = unescapeFilter(value)
+	return slices.ContainsFunc(entry.GetEqualFoldAttributeValues(attribute), func(v string) bool {
+		return strings.EqualFold(v, value)
+	}), filter[end+1:]
+}
+
+/* reverses ldap.EscapeFilter */
+func unescapeFilter(value string) string {
+	var out strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+2 < len(value) {
+			if b, err := hex.DecodeString(value[i+1 : i+3]); err == nil {
+				out.Write(b)
+				i += 2
+				continue
+			}
+		}
+		out.WriteByte(value[i])
+	}
+	return out.String()
+}
+
+func posixUser(name, uid, gid string) *ldap.Entry {
+	return ldap.NewEntry("uid="+name+",ou=people,dc=example,dc=org", map[string][]string{
+		"objectClass": {"posixAccount"},
+		"uid":         {name},
+		"uidNumber":   {uid},
+		"gidNumber":   {gid},
+	})
+}
+
+func newStandIn() *standIn {
+	return &standIn{entries: []*ldap.Entry{
+		posixUser("alice", "1001", "100"),
+		posixUser("bob", "1002", "200"),
+		posixUser("carol", "1003", "100"),
+		ldap.NewEntry("cn=staff,ou=groups,dc=example,dc=org", map[string][]string{
+			"objectClass": {"posixGroup"},
+			"cn":          {"staff"},
+			"gidNumber":   {"100"},
+			"memberUid":   {"dave"},
+			"member": {
+				"uid=bob,ou=people,dc=example,dc=org",
+				"UID=Alice,OU=People,DC=example,DC=org",
+				"cn=nested,ou=groups,dc=example,dc=org",
+				"uid=eve,ou=elsewhere,dc=example,dc=org",
+			},
+		}),
+	}}
+}
+
+func newTestLDAP(s *standIn, poolSize int) *LDAP {
+	cfg := &config.LDAP{
+		URL:          "ldap://ldap.example.org",
+		UserBaseDNs:  []string{"ou=people,dc=example,dc=org"},
+		GroupBaseDNs: []string{"ou=groups,dc=example,dc=org"},
+		PoolSize:     poolSize,
+	}
+	if err := cfg.Normalize(); err != nil {
+		panic(err)
+	}
+	return NewLDAPWithDialer(cfg, "", s.dial)
+}
+
+/* waits until cond holds */
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLDAPLookups(t *testing.T) {
+	s := newStandIn()
+	l := newTestLDAP(s, 0)
+	ctx := context.Background()
+
+	if u, err := l.LookupUser(ctx, "alice"); err != nil || *u != (User{Name: "alice", UID: 1001, GID: 100}) {
+		t.Fatalf("LookupUser(alice) = %+v, %v", u, err)
+	}
+	if u, err := l.LookupUser(ctx, "1002"); err != nil || u.Name != "bob" {
+		t.Fatalf("LookupUser(1002) = %+v, %v, want bob", u, err)
+	}
+	for _, name := range []string{"nobody", "a*", "alice)(uid=*"} {
+		if _, err := l.LookupUser(ctx, name); !errors.Is(err, ErrNotFound) {
+			t.Errorf("LookupUser(%q) error = %v, want ErrNotFound", name, err)
+		}
+	}
+
+	/* member DNs are resolved with one search, the unresolvable ones skipped */
+	_, before := s.stats()
+	group, err := l.LookupGroup(ctx, "staff")
+	if err != nil {
+		t.Fatalf("LookupGroup: %v", err)
+	}
+	if want := []string{"dave", "bob", "alice"}; group.GID != 100 || !slices.Equal(group.Members, want) {
+		t.Fatalf("group = %+v, want gid 100 and members %v", group, want)
+	}
+	if _, after := s.stats(); after-before != 2 {
+		t.Fatalf("LookupGroup made %d searches, want 2", after-before)
+	}
+
+	members, err := l.GroupMembers(ctx, "staff")
+	if want := []string{"dave", "bob", "alice", "carol"}; err != nil || !slices.Equal(members, want) {
+		t.Fatalf("GroupMembers = %v, %v, want %v", members, err, want)
+	}
+	if dials, _ := s.stats(); dials != 1 {
+		t.Fatalf("%d connections dialed, want 1 reused connection", dials)
+	}
+}
+
+func TestLDAPConcurrentSearches(t *testing.T) {
+	s := newStandIn()
+	s.block = make(chan struct{})
+	l := newTestLDAP(s, 2)
+
+	/* two searches run at once, one on each pooled connection */
+	var wg sync.WaitGroup
+	for _, name := range []string{"alice", "bob"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := l.LookupUser(context.Background(), name); err != nil {
+				t.Errorf("LookupUser(%s): %v", name, err)
+			}
+		}()
+	}
+	waitFor(t, "two concurrent searches", func() bool {
+		_, searches := s.stats()
+		return searches == 2
+	})
+
+	/* a third waits for a connection, but no longer than its context */
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.LookupUser(ctx, "carol"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("LookupUser with the pool exhausted error = %v, want a deadline error", err)
+	}
+
+	close(s.block)
+	wg.Wait()
+	if dials, searches := s.stats(); dials != 2 || searches != 2 {
+		t.Fatalf("%d dials and %d searches, want 2 of each", dials, searches)
+	}
+}
+
+func TestLDAPCancelledSearch(t *testing.T) {
+	s := newStandIn()
+	s.block = make(chan struct{})
+	l := newTestLDAP(s, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.LookupUser(ctx, "alice"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("LookupUser error = %v, want a deadline error", err)
+	}
+	close(s.block)
+
+	/* the aborted connection is not reused */
+	if _, err := l.LookupUser(context.Background(), "alice"); err != nil {
+		t.Fatalf("LookupUser after the cancelled search: %v", err)
+	}
+	if dials, _ := s.stats(); dials != 2 {
+		t.Fatalf("%d connections dialed, want 2", dials)
+	}
+}
+
+/* context reporting an error once ended, without its Done channel ever firing */
+type lateContext struct {
+	context.Context
+	ended atomic.Bool
+}
+
+func (c *lateContext) Err() error {
+	if c.ended.Load() {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestLDAPLateCancelReleasesConnection(t *testing.T) {
+	s := newStandIn()
+	l := newTestLDAP(s, 1)
+
+	/* the search completes, the context ends before its result is taken */
+	ctx := &lateContext{Context: context.Background()}
+	_, err := l.do(ctx, func(conn ldap.Client) (*ldap.SearchResult, error) {
+		defer ctx.ended.Store(true)
+		return conn.Search(&ldap.SearchRequest{BaseDN: "ou=people,dc=example,dc=org", Filter: "(uid=alice)"})
+	})
+	if err != nil {
+		t.Fatalf("do: %v", err)
+	}
+
+	/* the connection is back in the pool */
+	if _, err := l.LookupUser(context.Background(), "alice"); err != nil {
+		t.Fatalf("LookupUser: %v", err)
+	}
+	if dials, _ := s.stats(); dials != 1 {
+		t.Fatalf("%d connections dialed, want the first one reused", dials)
+	}
+}
+
+func TestLDAPClose(t *testing.T) {
+	s := newStandIn()
+	l := newTestLDAP(s, 2)
+
+	if _, err := l.LookupUser(context.Background(), "alice"); err != nil {
+		t.Fatalf("LookupUser: %v", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	/* connections released after Close are closed too */
+	if _, err := l.LookupUser(context.Background(), "bob"); err != nil {
+		t.Fatalf("LookupUser after Close: %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dials != 2 || s.closes != 2 || len(l.idle) != 0 {
+		t.Fatalf("%d dials, %d closes and %d idle connections, want every connection closed", s.dials, s.closes, len(l.idle))
+	}
+}
+
+func TestLDAPReconnects(t *testing.T) {
+	s := newStandIn()
+	s.failNext = true
+	l := newTestLDAP(s, 1)
+
+	if _, err := l.LookupUser(context.Background(), "alice"); err != nil {
+		t.Fatalf("LookupUser on a stale connection: %v", err)
+	}
+	if dials, searches := s.stats(); dials != 2 || searches != 2 {
+		t.Fatalf("%d dials and %d searches, want the search retried on a new connection", dials, searches)
+	}
+}
+
+func TestNSS(t *testing.T) {
+	dir := t.TempDir()
+	n := &NSS{
+		GroupFile:  filepath.Join(dir, "group"),
+		PasswdFile: filepath.Join(dir, "passwd"),
+	}
+	os.WriteFile(n.GroupFile, []byte("# comment\nroot:x:0:alice, bob\nother:x:1:carol\n"), 0o644)
+	os.WriteFile(n.PasswdFile, []byte("root:x:0:0::/root:/bin/sh\nbob:x:1002:0::/:/bin/sh\ncarol:x:1003:1::/:/bin/sh\n"), 0o644)
+	ctx := context.Background()
+
+	if u, err := n.LookupUser(ctx, "0"); err != nil || u.Name != "root" || u.UID != 0 {
+		t.Fatalf("LookupUser(0) = %+v, %v, want root", u, err)
+	}
+	if _, err := n.LookupUser(ctx, "no-such-user-aclapi"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("LookupUser of an unknown user error = %v, want ErrNotFound", err)
+	}
+	if _, err := n.LookupGroup(ctx, "no-such-group-aclapi"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("LookupGroup of an unknown group error = %v, want ErrNotFound", err)
+	}
+
+	members, err := n.GroupMembers(ctx, "root")
+	if want := []string{"alice", "bob", "root"}; err != nil || !slices.Equal(members, want) {
+		t.Fatalf("GroupMembers(root) = %v, %v, want %v", members, err, want)
+	}
+
+	n.GroupFile = filepath.Join(dir, "missing")
+	if _, err := n.GroupMembers(ctx, "root"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("GroupMembers without a group file error = %v, want a read error", err)
+	}
+}
+
+/* directory counting lookups, failing with err when set */
+type countingDirectory struct {
+	lookups int
+	err     error
+}
+
+func (d *countingDirectory) LookupUser(ctx context.Context, name string) (*User, error) {
+	d.lookups++
+	if d.err != nil {
+		return nil, d.err
+	}
+	if name == "ghost" {
+		return nil, ErrNotFound
+	}
+	return &User{Name: name}, nil
+}
+
+func (d *countingDirectory) LookupGroup(ctx context.Context, name string) (*Group, error) {
+	d.lookups++
+	return &Group{Name: name}, d.err
+}
+
+func (d *countingDirectory) GroupMembers(ctx context.Context, name string) ([]string, error) {
+	d.lookups++
+	return []string{name}, d.err
+}
+
+func (d *countingDirectory) Close() error {
+	return nil
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		ttl, negTTL time.Duration
+		lookup      string
+		err         error
+		wantLookups int
+	}{
+		{name: "hit", ttl: time.Hour, negTTL: time.Hour, lookup: "alice", wantLookups: 1},
+		{name: "expired", ttl: time.Nanosecond, negTTL: time.Hour, lookup: "alice", wantLookups: 2},
+		{name: "negative hit", ttl: time.Nanosecond, negTTL: time.Hour, lookup: "ghost", wantLookups: 1},
+		{name: "negative expired", ttl: time.Hour, negTTL: time.Nanosecond, lookup: "ghost", wantLookups: 2},
+		{name: "transient error", ttl: time.Hour, negTTL: time.Hour, lookup: "alice", err: errors.New("unavailable"), wantLookups: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := &countingDirectory{err: test.err}
+			c := NewCache(dir, test.ttl, test.negTTL, 10)
+
+			for range 2 {
+				time.Sleep(time.Millisecond)
+				c.LookupUser(ctx, test.lookup)
+			}
+			if dir.lookups != test.wantLookups {
+				t.Fatalf("%d lookups, want %d", dir.lookups, test.wantLookups)
+			}
+		})
+	}
+
+	/* users, groups and members are cached apart, and the cache stays bounded */
+	dir := &countingDirectory{}
+	c := NewCache(dir, time.Hour, time.Hour, 2)
+	c.LookupUser(ctx, "staff")
+	c.LookupGroup(ctx, "staff")
+	c.GroupMembers(ctx, "staff")
+	if dir.lookups != 3 || len(c.entries) != 2 {
+		t.Fatalf("%d lookups and %d entries, want 3 lookups and 2 entries", dir.lookups, len(c.entries))
+	}
+
+	c.Flush()
+	c.LookupUser(ctx, "staff")
+	if dir.lookups != 4 {
+		t.Fatalf("%d lookups after Flush, want 4", dir.lookups)
+	}
+}
@@ -0,0 +1,473 @@
+package directory
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/config"
+)
+
+/* RFC 2307 attributes read from directory entries */
+var (
+	userAttributes  = []string{"uid", "uidNumber", "gidNumber"}
+	groupAttributes = []string{"cn", "gidNumber", "memberUid", "member"}
+)
+
+/* member DNs of an RFC 2307bis group resolved by a single search */
+const memberBatchSize = 50
+
+/* attribute types (names or OIDs) that may be copied from a member DN into a filter */
+var attributeType = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9-]*|[0-9]+(\.[0-9]+)*)$`)
+
+/* directory backed by an LDAP server (OpenLDAP, 389-ds, ...) */
+type LDAP struct {
+	cfg      *config.LDAP
+	password string
+
+	/*
+		dial opens an unbound connection to the server
+		replaced in tests to run against a local LDAP stand-in
+	*/
+	dial func() (ldap.Client, error)
+
+	/* bound connections not in use, and one token per connection in use */
+	idle  chan ldap.Client
+	slots chan struct{}
+
+	/* guards returning connections to idle against Close */
+	mu     sync.Mutex
+	closed bool
+}
+
+/* creates a directory resolving principals via LDAP */
+func NewLDAP(cfg *config.LDAP) (*LDAP, error) {
+	password := cfg.BindPassword
+	if cfg.BindPasswordFile != "" {
+		data, err := os.ReadFile(cfg.BindPasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read LDAP bind password file: %w", err)
+		}
+		password = strings.TrimRight(string(data), "\r\n")
+	}
+
+	tlsConfig, err := ldapTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	l := NewLDAPWithDialer(cfg, password, nil)
+	l.dial = func() (ldap.Client, error) {
+		conn, err := ldap.DialURL(cfg.URL,
+			ldap.DialWithDialer(&net.Dialer{Timeout: cfg.Timeout}),
+			ldap.DialWithTLSConfig(tlsConfig),
+		)
+		if err != nil {
+			return nil, err
+		}
+		conn.SetTimeout(cfg.Timeout)
+
+		/* upgrade plain ldap:// connections if requested */
+		if cfg.StartTLS {
+			if err := conn.StartTLS(tlsConfig); err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("StartTLS failed: %w", err)
+			}
+		}
+
+		return conn, nil
+	}
+
+	return l, nil
+}
+
+/* creates a directory using a custom dial function (local stand-ins, tests) */
+func NewLDAPWithDialer(cfg *config.LDAP, password string, dial func() (ldap.Client, error)) *LDAP {
+	size := max(cfg.PoolSize, 1)
+	return &LDAP{
+		cfg:      cfg,
+		password: password,
+		dial:     dial,
+		idle:     make(chan ldap.Client, size),
+		slots:    make(chan struct{}, size),
+	}
+}
+
+/* resolves a user by name or numeric uid */
+func (l *LDAP) LookupUser(ctx context.Context, name string) (*User, error) {
+	filter := l.cfg.UserFilter
+	if isNumeric(name) {
+		filter = l.cfg.UserIDFilter
+	}
+
+	entry, err := l.searchOne(ctx, l.cfg.UserBaseDNs, fmt.Sprintf(filter, ldap.EscapeFilter(name)), userAttributes)
+	if err != nil {
+		return nil, fmt.Errorf("user %q: %w", name, err)
+	}
+
+	return userFromEntry(entry)
+}
+
+/* resolves a group by name or numeric gid */
+func (l *LDAP) LookupGroup(ctx context.Context, name string) (*Group, error) {
+	filter := l.cfg.GroupFilter
+	if isNumeric(name) {
+		filter = l.cfg.GroupIDFilter
+	}
+
+	entry, err := l.searchOne(ctx, l.cfg.GroupBaseDNs, fmt.Sprintf(filter, ldap.EscapeFilter(name)), groupAttributes)
+	if err != nil {
+		return nil, fmt.Errorf("group %q: %w", name, err)
+	}
+
+	gid, err := parseID(entry.GetAttributeValue("gidNumber"))
+	if err != nil {
+		return nil, fmt.Errorf("group %q has invalid gidNumber: %w", name, err)
+	}
+
+	group := &Group{
+		Name:    entry.GetAttributeValue("cn"),
+		GID:     gid,
+		Members: entry.GetAttributeValues("memberUid"),
+	}
+
+	/* RFC 2307bis groups list members by DN */
+	members, err := l.usersByDN(ctx, entry.GetAttributeValues("member"))
+	if err != nil {
+		return nil, err
+	}
+	group.Members = append(group.Members, members...)
+
+	return group, nil
+}
+
+/* expands a group to the names of all its members (including primary group members) */
+func (l *LDAP) GroupMembers(ctx context.Context, name string) ([]string, error) {
+	group, err := l.LookupGroup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{})
+	var members []string
+	for _, member := range group.Members {
+		if _, ok := seen[member]; ok {
+			continue
+		}
+		seen[member] = struct{}{}
+		members = append(members, member)
+	}
+
+	/* users having this group as their primary group */
+	filter := fmt.Sprintf("(&(objectClass=posixAccount)(gidNumber=%d))", group.GID)
+	entries, err := l.search(ctx, l.cfg.UserBaseDNs, ldap.ScopeWholeSubtree, filter, userAttributes)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		member := entry.GetAttributeValue("uid")
+		if _, ok := seen[member]; ok || member == "" {
+			continue
+		}
+		seen[member] = struct{}{}
+		members = append(members, member)
+	}
+
+	return members, nil
+}
+
+/*
+resolves member DNs to user names, searching the user base DNs for a batch of
+members at a time instead of reading every member entry on its own
+
+nested groups, non-posix members and members outside of the user base DNs
+are skipped
+*/
+func (l *LDAP) usersByDN(ctx context.Context, dns []string) ([]string, error) {
+	var names []string
+
+	for start := 0; start < len(dns); start += memberBatchSize {
+		var (
+			wanted []*ldap.DN
+			filter strings.Builder
+		)
+		for _, dn := range dns[start:min(start+memberBatchSize, len(dns))] {
+			parsed, err := ldap.ParseDN(dn)
+			if err != nil || len(parsed.RDNs) == 0 {
+				continue
+			}
+
+			rdn, ok := rdnFilter(parsed.RDNs[0])
+			if !ok {
+				continue
+			}
+			wanted = append(wanted, parsed)
+			filter.WriteString(rdn)
+		}
+		if len(wanted) == 0 {
+			continue
+		}
+
+		entries, err := l.search(ctx, l.cfg.UserBaseDNs, ldap.ScopeWholeSubtree,
+			"(&(objectClass=posixAccount)(|"+filter.String()+"))", userAttributes)
+		if err != nil {
+			return nil, err
+		}
+
+		/* the filter only matches the first RDN, keep the entries with the exact DN */
+		for _, dn := range wanted {
+			for _, entry := range entries {
+				entryDN, err := ldap.ParseDN(entry.DN)
+				if err != nil || !entryDN.EqualFold(dn) {
+					continue
+				}
+
+				user, err := userFromEntry(entry)
+				if err != nil {
+					return nil, err
+				}
+				names = append(names, user.Name)
+				break
+			}
+		}
+	}
+
+	return names, nil
+}
+
+/* filter matching the attributes of a relative DN, false if one cannot be expressed safely */
+func rdnFilter(rdn *ldap.RelativeDN) (string, bool) {
+	var filter strings.Builder
+	for _, attribute := range rdn.Attributes {
+		if !attributeType.MatchString(attribute.Type) {
+			return "", false
+		}
+		fmt.Fprintf(&filter, "(%s=%s)", attribute.Type, ldap.EscapeFilter(attribute.Value))
+	}
+
+	if len(rdn.Attributes) > 1 {
+		return "(&" + filter.String() + ")", true
+	}
+	return filter.String(), len(rdn.Attributes) == 1
+}
+
+/* searches the base DNs for exactly one matching entry */
+func (l *LDAP) searchOne(ctx context.Context, baseDNs []string, filter string, attributes []string) (*ldap.Entry, error) {
+	entries, err := l.search(ctx, baseDNs, ldap.ScopeWholeSubtree, filter, attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(entries) {
+	case 0:
+		return nil, ErrNotFound
+	case 1:
+		return entries[0], nil
+	default:
+		return nil, fmt.Errorf("ambiguous directory entry: %d matches for %s", len(entries), filter)
+	}
+}
+
+/* runs a search against every base DN, reconnecting once on connection failure */
+func (l *LDAP) search(ctx context.Context, baseDNs []string, scope int, filter string, attributes []string) ([]*ldap.Entry, error) {
+	var entries []*ldap.Entry
+
+	for _, baseDN := range baseDNs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		request := ldap.NewSearchRequest(
+			baseDN, scope, ldap.NeverDerefAliases,
+			0, int(l.cfg.Timeout.Seconds()), false,
+			filter, attributes, nil,
+		)
+
+		result, err := l.do(ctx, func(conn ldap.Client) (*ldap.SearchResult, error) {
+			return conn.Search(request)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, result.Entries...)
+	}
+
+	return entries, nil
+}
+
+/*
+runs fn on a pooled bound connection, waiting for one while the pool is
+exhausted; a stale connection is replaced and fn retried once
+*/
+func (l *LDAP) do(ctx context.Context, fn func(conn ldap.Client) (*ldap.SearchResult, error)) (*ldap.SearchResult, error) {
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-l.slots }()
+
+	for attempt := 0; ; attempt++ {
+		var conn ldap.Client
+		select {
+		case conn = <-l.idle:
+		default:
+			var err error
+			if conn, err = l.connect(); err != nil {
+				return nil, err
+			}
+		}
+
+		result, aborted, err := l.run(ctx, conn, fn)
+		switch {
+		case aborted:
+			/* the connection was closed to abort fn */
+			return nil, err
+		case err != nil && ldap.IsErrorWithCode(err, ldap.ErrorNetwork):
+			conn.Close()
+			if attempt == 0 {
+				continue
+			}
+			return nil, err
+		}
+
+		/* fn completed, even if ctx ended just after, the connection is fine */
+		l.release(conn)
+		return result, err
+	}
+}
+
+/* returns a connection to the idle pool, or closes it once the directory is closed */
+func (l *LDAP) release(conn ldap.Client) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		conn.Close()
+		return
+	}
+	l.idle <- conn
+}
+
+/* closes the idle connections, connections in use are closed when released */
+func (l *LDAP) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.closed = true
+	for {
+		select {
+		case conn := <-l.idle:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+/*
+runs fn on conn, closing conn to abort fn when ctx is done first
+aborted reports that fn was abandoned and conn closed, err is then the context error
+*/
+func (l *LDAP) run(ctx context.Context, conn ldap.Client, fn func(conn ldap.Client) (*ldap.SearchResult, error)) (result *ldap.SearchResult, aborted bool, err error) {
+	type outcome struct {
+		result *ldap.SearchResult
+		err    error
+	}
+
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := fn(conn)
+		done <- outcome{result, err}
+	}()
+
+	select {
+	case o := <-done:
+		return o.result, false, o.err
+	case <-ctx.Done():
+		conn.Close()
+		return nil, true, ctx.Err()
+	}
+}
+
+/* dials and binds a new connection */
+func (l *LDAP) connect() (ldap.Client, error) {
+	conn, err := l.dial()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to LDAP server: %w", err)
+	}
+
+	/* anonymous binds are used when no bind DN is configured */
+	if l.cfg.BindDN != "" {
+		if err := conn.Bind(l.cfg.BindDN, l.password); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("LDAP bind failed: %w", err)
+		}
+	}
+
+	return conn, nil
+}
+
+/* builds the TLS config for ldaps:// and StartTLS */
+func ldapTLSConfig(cfg *config.LDAP) (*tls.Config, error) {
+	serverURL, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LDAP URL: %w", err)
+	}
+
+	/* StartTLS needs the server name set explicitly for verification */
+	tlsConfig := &tls.Config{
+		ServerName:         serverURL.Hostname(),
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.TLSCACertFile != "" {
+		caCert, err := os.ReadFile(cfg.TLSCACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read LDAP CA cert: %w", err)
+		}
+		certPool := x509.NewCertPool()
+		if ok := certPool.AppendCertsFromPEM(caCert); !ok {
+			return nil, fmt.Errorf("failed to add LDAP CA cert to pool")
+		}
+		tlsConfig.RootCAs = certPool
+	}
+
+	return tlsConfig, nil
+}
+
+/* converts a posixAccount entry */
+func userFromEntry(entry *ldap.Entry) (*User, error) {
+	uid, err := parseID(entry.GetAttributeValue("uidNumber"))
+	if err != nil {
+		return nil, fmt.Errorf("entry %s has invalid uidNumber: %w", entry.DN, err)
+	}
+
+	gid, err := parseID(entry.GetAttributeValue("gidNumber"))
+	if err != nil {
+		return nil, fmt.Errorf("entry %s has invalid gidNumber: %w", entry.DN, err)
+	}
+
+	return &User{Name: entry.GetAttributeValue("uid"), UID: uid, GID: gid}, nil
+}
+
+/* parses a numeric uid/gid attribute */
+func parseID(value string) (uint32, error) {
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(id), nil
+}
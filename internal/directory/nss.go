@@ -0,0 +1,180 @@
+package directory
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+/* directory backed by the local name service switch (files, sssd, winbind, ...) */
+type NSS struct {
+	/* group and passwd databases scanned for group expansion */
+	GroupFile  string
+	PasswdFile string
+}
+
+/* creates a directory resolving principals via NSS */
+func NewNSS() *NSS {
+	return &NSS{
+		GroupFile:  "/etc/group",
+		PasswdFile: "/etc/passwd",
+	}
+}
+
+/* resolves a user by name or numeric uid */
+func (n *NSS) LookupUser(ctx context.Context, name string) (*User, error) {
+	var (
+		u   *user.User
+		err error
+	)
+
+	if isNumeric(name) {
+		u, err = user.LookupId(name)
+	} else {
+		u, err = user.Lookup(name)
+	}
+	if err != nil {
+		var unknownName user.UnknownUserError
+		var unknownID user.UnknownUserIdError
+		if errors.As(err, &unknownName) || errors.As(err, &unknownID) {
+			return nil, fmt.Errorf("user %q: %w", name, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to lookup user %q: %w", name, err)
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid uid %q for user %q", u.Uid, name)
+	}
+
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gid %q for user %q", u.Gid, name)
+	}
+
+	return &User{Name: u.Username, UID: uint32(uid), GID: uint32(gid)}, nil
+}
+
+/* resolves a group by name or numeric gid */
+func (n *NSS) LookupGroup(ctx context.Context, name string) (*Group, error) {
+	var (
+		g   *user.Group
+		err error
+	)
+
+	if isNumeric(name) {
+		g, err = user.LookupGroupId(name)
+	} else {
+		g, err = user.LookupGroup(name)
+	}
+	if err != nil {
+		var unknownName user.UnknownGroupError
+		var unknownID user.UnknownGroupIdError
+		if errors.As(err, &unknownName) || errors.As(err, &unknownID) {
+			return nil, fmt.Errorf("group %q: %w", name, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to lookup group %q: %w", name, err)
+	}
+
+	gid, err := strconv.ParseUint(g.Gid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gid %q for group %q", g.Gid, name)
+	}
+
+	return &Group{Name: g.Name, GID: uint32(gid)}, nil
+}
+
+/*
+expands a group to its members
+NSS has no portable enumeration API, so secondary members are read from the
+group file and primary members from the passwd file
+*/
+func (n *NSS) GroupMembers(ctx context.Context, name string) ([]string, error) {
+	group, err := n.LookupGroup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{})
+	var members []string
+	add := func(member string) {
+		if member == "" {
+			return
+		}
+		if _, ok := seen[member]; ok {
+			return
+		}
+		seen[member] = struct{}{}
+		members = append(members, member)
+	}
+
+	/* secondary members: group_name:password:GID:user_list */
+	err = scanColonFile(n.GroupFile, func(fields []string) {
+		if len(fields) < 4 || fields[0] != group.Name {
+			return
+		}
+		for _, member := range strings.Split(fields[3], ",") {
+			add(strings.TrimSpace(member))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	/* primary members: login:password:UID:GID:GECOS:home:shell */
+	gid := strconv.FormatUint(uint64(group.GID), 10)
+	err = scanColonFile(n.PasswdFile, func(fields []string) {
+		if len(fields) < 4 || fields[3] != gid {
+			return
+		}
+		add(fields[0])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+/* nothing to release */
+func (n *NSS) Close() error {
+	return nil
+}
+
+/* calls fn with the colon separated fields of every non-comment line in path */
+func scanColonFile(path string, fn func(fields []string)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fn(strings.Split(line, ":"))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return nil
+}
+
+/* reports whether name is a plain numeric id */
+func isNumeric(name string) bool {
+	if name == "" {
+		return false
+	}
+	_, err := strconv.ParseUint(name, 10, 32)
+	return err == nil
+}
@@ -0,0 +1,436 @@
+package drift
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/config"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/audit"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/backend"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/events"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/fswatch"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/pathlock"
+)
+
+/* audit records read at once while collecting the expected ACLs */
+const auditPageSize = 1000
+
+/* scanner configuration */
+type Options struct {
+	/* time of the first scan as an offset from local midnight, and time between scans */
+	At       time.Duration
+	Interval time.Duration
+
+	/* policy of the paths outside of the shares */
+	Policy string
+	Shares []config.DriftShare
+
+	/* backend reading and restoring ACLs */
+	Backend backend.Backend
+
+	/* audit log holding the expected ACLs and receiving the remediations */
+	Audit *audit.Log
+
+	/* broker and watcher told about remediations (either may be nil) */
+	Events  *events.Broker
+	Watcher *fswatch.Watcher
+
+	/* locks shared with the API, paths are compared and remediated under them (optional) */
+	Locks *pathlock.Manager
+}
+
+/* path whose ACL is not the one aclapi last set */
+type Drift struct {
+	Path string
+
+	/* audit record of the change that set the expected ACL */
+	Seq      uint64
+	Expected *aclmodel.ACL
+
+	/* ACL on disk when scanned, nil if it could not be read */
+	Actual *aclmodel.ACL
+
+	Policy     string
+	Remediated bool
+
+	/* why the path could not be read or remediated */
+	Error string
+}
+
+/* outcome of a scan */
+type Report struct {
+	Started  time.Time
+	Finished time.Time
+
+	/* paths with an expected ACL that still exist */
+	Checked int
+
+	Drifts []Drift
+}
+
+/*
+compares, on a schedule, the ACL of every path changed through aclapi with
+the ACL recorded in the audit log after its last change, and restores the
+recorded ACL where the policy says so
+
+only the path named in a request is compared, the paths below it reached by
+a recursive change are not recorded in the audit log, their earlier expected
+ACLs are forgotten
+*/
+type Scanner struct {
+	opts Options
+
+	/* one scan at a time */
+	scanning sync.Mutex
+
+	mu   sync.Mutex
+	last *Report
+
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+/* creates a scanner, scans only run once started or when asked for */
+func New(opts Options) *Scanner {
+	return &Scanner{opts: opts, stop: make(chan struct{})}
+}
+
+/* starts scanning on schedule */
+func (s *Scanner) Start() {
+	s.done.Add(1)
+	go func() {
+		defer s.done.Done()
+		s.schedule()
+	}()
+}
+
+/* stops the schedule, interrupting a running scan */
+func (s *Scanner) Close() {
+	close(s.stop)
+	s.done.Wait()
+}
+
+/* last completed report, nil before the first scan */
+func (s *Scanner) Last() *Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+/* runs scans at the configured time of day and then every interval */
+func (s *Scanner) schedule() {
+	next := nextRun(time.Now(), s.opts.At)
+	zap.L().Info("Drift scans scheduled",
+		zap.Time("next", next),
+		zap.Duration("interval", s.opts.Interval),
+	)
+
+	for {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-s.stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		if _, err := s.Scan(ctx); err != nil && ctx.Err() == nil {
+			zap.L().Error("Drift scan failed",
+				zap.Error(err),
+			)
+		}
+		cancel()
+
+		/* skip the runs missed while scanning */
+		for !next.After(time.Now()) {
+			next = next.Add(s.opts.Interval)
+		}
+	}
+}
+
+/* first occurrence of the time of day at after now */
+func nextRun(now time.Time, at time.Duration) time.Time {
+	year, month, day := now.Date()
+	next := time.Date(year, month, day, 0, 0, 0, 0, now.Location()).Add(at)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+/* compares every recorded path now, the report also becomes the last one */
+func (s *Scanner) Scan(ctx context.Context) (*Report, error) {
+	s.scanning.Lock()
+	defer s.scanning.Unlock()
+
+	report := &Report{Started: time.Now().UTC()}
+
+	expected, err := s.expected()
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(expected))
+	for path := range expected {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		drift, exists := s.check(ctx, expected[path])
+		if !exists {
+			continue
+		}
+		report.Checked++
+
+		if drift != nil {
+			report.Drifts = append(report.Drifts, *drift)
+		}
+	}
+
+	report.Finished = time.Now().UTC()
+	zap.L().Info("Drift scan finished",
+		zap.Int("checked", report.Checked),
+		zap.Int("drifted", len(report.Drifts)),
+		zap.Duration("duration", report.Finished.Sub(report.Started)),
+	)
+
+	s.mu.Lock()
+	s.last = report
+	s.mu.Unlock()
+
+	return report, nil
+}
+
+/*
+last ACL set through aclapi for every path, taken from the successful API
+changes and remediations in the audit log (external changes are the drift)
+*/
+func (s *Scanner) expected() (map[string]*audit.Record, error) {
+	expected := make(map[string]*audit.Record)
+
+	query := &audit.Query{Limit: auditPageSize}
+	for {
+		records, more, err := s.opts.Audit.Query(query)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, record := range records {
+			expect(expected, record)
+		}
+
+		if !more {
+			return expected, nil
+		}
+		query.After = records[len(records)-1].Seq
+	}
+}
+
+/*
+record expected for the path of current once the changes after it are taken
+into account, nil if a later change left the ACL of the path unknown
+*/
+func (s *Scanner) expectedSince(current *audit.Record) (*audit.Record, error) {
+	/* recursive changes above the path are included */
+	records, _, err := s.opts.Audit.Query(&audit.Query{PathPrefix: current.Path, After: current.Seq})
+	if err != nil {
+		return nil, err
+	}
+
+	expected := map[string]*audit.Record{current.Path: current}
+	for _, record := range records {
+		expect(expected, record)
+	}
+	return expected[current.Path], nil
+}
+
+/*
+updates the expected ACLs with a record, a successful change through aclapi
+sets the ACL of its path, or makes it unknown when the ACL after it could not
+be read, and a recursive one makes every path below it unknown (they were
+changed too, to ACLs that are not recorded)
+*/
+func expect(expected map[string]*audit.Record, record *audit.Record) {
+	if record.Outcome != audit.OutcomeSuccess {
+		return
+	}
+	if record.Source != "" && record.Source != audit.SourceRemediation {
+		return
+	}
+
+	if record.Recursive {
+		for path := range expected {
+			if aclmodel.PathWithin(path, record.Path) {
+				delete(expected, path)
+			}
+		}
+	}
+
+	if record.After == nil {
+		delete(expected, record.Path)
+		return
+	}
+	expected[record.Path] = record
+}
+
+/*
+compares the ACL of a recorded path with the expected one and applies the
+policy, returning the drift (nil if there is none) and whether the path
+still exists
+*/
+func (s *Scanner) check(ctx context.Context, record *audit.Record) (*Drift, bool) {
+	drift := &Drift{
+		Path:     record.Path,
+		Seq:      record.Seq,
+		Expected: record.After,
+		Policy:   s.policy(record.Path),
+	}
+
+	/* changes through aclapi in progress are waited for, they are not drift */
+	if s.opts.Locks != nil {
+		unlock, err := s.opts.Locks.Lock(ctx, drift.Path, false)
+		if err != nil {
+			drift.Error = err.Error()
+			return drift, true
+		}
+		defer unlock()
+	}
+
+	actual, err := s.opts.Backend.Get(ctx, drift.Path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		/* removed since, there is nothing left to drift */
+		return nil, false
+	case err != nil:
+		drift.Error = err.Error()
+		return drift, true
+	}
+	drift.Actual = actual
+
+	/* changes made through aclapi since the scan started set a newer expected ACL, or an unknown one */
+	newer, err := s.expectedSince(record)
+	if err != nil {
+		drift.Error = err.Error()
+		return drift, true
+	}
+	if newer == nil {
+		return nil, false
+	}
+	drift.Seq, drift.Expected = newer.Seq, newer.After
+
+	if equalACL(actual, drift.Expected) {
+		return nil, true
+	}
+
+	if drift.Policy == config.DriftRemediate {
+		s.remediate(ctx, drift)
+	}
+	return drift, true
+}
+
+/* policy of the closest share containing path */
+func (s *Scanner) policy(path string) string {
+	policy, longest := s.opts.Policy, -1
+	for _, share := range s.opts.Shares {
+		if len(share.Path) > longest && aclmodel.PathWithin(path, share.Path) {
+			policy, longest = share.Policy, len(share.Path)
+		}
+	}
+	return policy
+}
+
+/* sets the expected ACL again, recording and publishing the change */
+func (s *Scanner) remediate(ctx context.Context, drift *Drift) {
+	/* not an external change for the watcher */
+	done := s.opts.Watcher.Expect(drift.Path, false)
+	err := s.opts.Backend.Set(ctx, drift.Path, drift.Expected)
+	done()
+
+	var after *aclmodel.ACL
+	if err == nil {
+		after, _ = s.opts.Backend.Get(ctx, drift.Path)
+	}
+
+	record := &audit.Record{
+		Source:  audit.SourceRemediation,
+		Path:    drift.Path,
+		Before:  drift.Actual,
+		After:   after,
+		Outcome: audit.OutcomeSuccess,
+	}
+	if err != nil {
+		record.Outcome = audit.OutcomeFailure
+		record.Error = err.Error()
+	}
+	if auditErr := s.opts.Audit.Append(record); auditErr != nil {
+		zap.L().Error("Failed to write audit record",
+			zap.String("path", drift.Path),
+			zap.Error(auditErr),
+		)
+	}
+
+	if err != nil {
+		zap.L().Error("Failed to remediate ACL drift",
+			zap.String("path", drift.Path),
+			zap.Error(err),
+		)
+		drift.Error = err.Error()
+		return
+	}
+
+	zap.L().Warn("Reverted ACL drift",
+		zap.String("path", drift.Path),
+		zap.Uint64("seq", drift.Seq),
+	)
+	drift.Remediated = true
+
+	if s.opts.Events != nil {
+		s.opts.Events.Publish(events.Event{
+			Kind: events.KindReverted,
+			Path: drift.Path,
+			ACL:  after,
+		})
+	}
+}
+
+/* reports whether two ACLs have the same entries, an absent default ACL equals an empty one */
+func equalACL(a, b *aclmodel.ACL) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return equalEntries(a.Access, b.Access) && equalEntries(a.Default, b.Default)
+}
+
+func equalEntries(a, b []aclmodel.Entry) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !reflect.DeepEqual(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,250 @@
+package drift
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/config"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/audit"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/backend"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/events"
+)
+
+/* key of the hash chain of test audit logs */
+var auditKey = []byte("0123456789abcdef0123456789abcdef")
+
+/* records the current ACL of path as set through the API */
+func recordChange(t *testing.T, log *audit.Log, b backend.Backend, path string) {
+	t.Helper()
+
+	acl, err := b.Get(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Get %s: %v", path, err)
+	}
+	if err := log.Append(&audit.Record{Caller: "test", Path: path, After: acl, Outcome: audit.OutcomeSuccess}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	root := t.TempDir()
+	kept := filepath.Join(root, "kept")
+	drifted := filepath.Join(root, "share", "drifted")
+	removed := filepath.Join(root, "removed")
+	for _, path := range []string{kept, drifted, removed} {
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, nil, 0o640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	log, err := audit.Open(filepath.Join(t.TempDir(), "audit.log"), audit.Options{Key: auditKey})
+	if err != nil {
+		t.Fatalf("audit.Open: %v", err)
+	}
+	defer log.Close()
+
+	b := backend.NewXattr()
+	for _, path := range []string{kept, drifted, removed} {
+		recordChange(t, log, b, path)
+	}
+
+	/* external changes do not change the expected ACL */
+	if err := os.Chmod(drifted, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	recordChange(t, log, b, drifted)
+	if err := log.Append(&audit.Record{Source: audit.SourceExternal, Path: kept, Outcome: audit.OutcomeSuccess}); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(removed)
+
+	broker := events.NewBroker(4)
+	reverted, err := broker.Subscribe("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(Options{
+		Policy:  config.DriftReport,
+		Backend: b,
+		Audit:   log,
+		Events:  broker,
+	})
+
+	/* the last API change set 0666, a hand-made chmod drifts from it */
+	if err := os.Chmod(drifted, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	report, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if report.Checked != 2 || len(report.Drifts) != 1 {
+		t.Fatalf("report = %+v, want 2 paths checked and 1 drift", report)
+	}
+	if d := report.Drifts[0]; d.Path != drifted || d.Seq != 4 || d.Policy != config.DriftReport || d.Remediated {
+		t.Fatalf("drift = %+v, want unremediated drift of %s from record 4", d, drifted)
+	}
+	if s.Last() != report {
+		t.Fatal("Last does not return the report of the scan")
+	}
+
+	/* the closest share decides */
+	s.opts.Shares = []config.DriftShare{
+		{Path: root, Policy: config.DriftReport},
+		{Path: filepath.Dir(drifted), Policy: config.DriftRemediate},
+	}
+	report, err = s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if len(report.Drifts) != 1 || !report.Drifts[0].Remediated {
+		t.Fatalf("report = %+v, want the drift remediated", report)
+	}
+	if info, err := os.Stat(drifted); err != nil || info.Mode().Perm() != 0o666 {
+		t.Fatalf("mode after remediation = %v, %v, want 0666", info.Mode().Perm(), err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if e, err := reverted.Next(ctx); err != nil || e.Kind != events.KindReverted || e.Path != drifted {
+		t.Fatalf("event = %+v, %v, want reverted %s", e, err, drifted)
+	}
+
+	records, _, err := log.Query(&audit.Query{After: 5})
+	if err != nil || len(records) != 1 || records[0].Source != audit.SourceRemediation {
+		t.Fatalf("records after the scan = %+v, %v, want the remediation", records, err)
+	}
+
+	/* nothing left to do */
+	if report, err = s.Scan(context.Background()); err != nil || len(report.Drifts) != 0 {
+		t.Fatalf("report = %+v, %v, want no drift", report, err)
+	}
+}
+
+func TestCheckSeesChangesDuringScan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, nil, 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := audit.Open(filepath.Join(t.TempDir(), "audit.log"), audit.Options{Key: auditKey})
+	if err != nil {
+		t.Fatalf("audit.Open: %v", err)
+	}
+	defer log.Close()
+
+	b := backend.NewXattr()
+	recordChange(t, log, b, path)
+	expected, err := (&Scanner{opts: Options{Audit: log}}).expected()
+	if err != nil {
+		t.Fatalf("expected: %v", err)
+	}
+
+	/* an API change made after the scan collected the expected ACLs */
+	if err := os.Chmod(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	recordChange(t, log, b, path)
+
+	s := New(Options{Policy: config.DriftRemediate, Backend: b, Audit: log})
+	drift, exists := s.check(context.Background(), expected[path])
+	if !exists || drift != nil {
+		t.Fatalf("check = %+v, %v, want no drift", drift, exists)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("mode after check = %v, %v, want the API change kept", info.Mode().Perm(), err)
+	}
+}
+
+func TestRecursiveChangeForgetsPathsBelow(t *testing.T) {
+	share := filepath.Join(t.TempDir(), "share")
+	file := filepath.Join(share, "file")
+	unread := filepath.Join(share, "unread")
+	if err := os.Mkdir(share, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{file, unread} {
+		if err := os.WriteFile(path, nil, 0o640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	log, err := audit.Open(filepath.Join(t.TempDir(), "audit.log"), audit.Options{Key: auditKey})
+	if err != nil {
+		t.Fatalf("audit.Open: %v", err)
+	}
+	defer log.Close()
+
+	/* individual changes first */
+	b := backend.NewXattr()
+	recordChange(t, log, b, file)
+	recordChange(t, log, b, unread)
+	collected, err := (&Scanner{opts: Options{Audit: log}}).expected()
+	if err != nil {
+		t.Fatalf("expected: %v", err)
+	}
+
+	/* then a recursive change of the share, and a change whose ACL could not be read afterwards */
+	for _, path := range []string{share, file} {
+		if err := os.Chmod(path, 0o700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	acl, err := b.Get(context.Background(), share)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := log.Append(&audit.Record{Caller: "test", Path: share, Recursive: true, After: acl, Outcome: audit.OutcomeSuccess}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(unread, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := log.Append(&audit.Record{Caller: "test", Path: unread, Outcome: audit.OutcomeSuccess}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(Options{Policy: config.DriftRemediate, Backend: b, Audit: log})
+
+	/* whether the changes came before the scan or during it */
+	for _, path := range []string{file, unread} {
+		if drift, exists := s.check(context.Background(), collected[path]); exists || drift != nil {
+			t.Fatalf("check %s = %+v, %v, want it no longer compared", path, drift, exists)
+		}
+	}
+	report, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if report.Checked != 1 || len(report.Drifts) != 0 {
+		t.Fatalf("report = %+v, want only the share checked and no drift", report)
+	}
+
+	for path, mode := range map[string]os.FileMode{file: 0o700, unread: 0o600} {
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != mode {
+			t.Fatalf("mode of %s after the scan = %v, %v, want the API change kept", path, info.Mode().Perm(), err)
+		}
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	at := 3 * time.Hour
+	tests := []struct {
+		now, want time.Time
+	}{
+		{time.Date(2024, 5, 1, 1, 0, 0, 0, time.UTC), time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC), time.Date(2024, 5, 2, 3, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 5, 31, 23, 0, 0, 0, time.UTC), time.Date(2024, 6, 1, 3, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		if got := nextRun(test.now, at); !got.Equal(test.want) {
+			t.Errorf("nextRun(%v) = %v, want %v", test.now, got, test.want)
+		}
+	}
+}
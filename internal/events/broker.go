@@ -0,0 +1,256 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+)
+
+var (
+	/* the resume token is malformed or was never issued */
+	ErrInvalidToken = errors.New("invalid resume token")
+
+	/* the events after the resume token are no longer buffered */
+	ErrTokenExpired = errors.New("resume token expired, events were missed")
+
+	/* the broker was closed (daemon shutting down) */
+	ErrClosed = errors.New("event broker closed")
+)
+
+/* how a change came to aclapi's attention */
+type Kind string
+
+const (
+	/* applied by aclapi on behalf of a caller */
+	KindApplied Kind = "applied"
+
+	/* drift undone by aclapi */
+	KindReverted Kind = "reverted"
+
+	/* made behind aclapi's back and noticed afterwards */
+	KindDetected Kind = "detected"
+)
+
+/* ACL change published to watchers */
+type Event struct {
+	/* position in the broker, assigned on publish */
+	Seq  uint64
+	Time time.Time
+
+	Kind      Kind
+	Path      string
+	Recursive bool
+
+	/* requested change, unknown for detected changes */
+	Action aclmodel.Action
+	Entry  *aclmodel.Entry
+
+	/* who asked for the change */
+	Caller  string
+	EndUser string
+	TxnID   string
+
+	/* ACL of the path after the change, nil if it was not read */
+	ACL *aclmodel.ACL
+}
+
+/*
+fans ACL change events out to watchers
+
+the last events are kept in a fixed-size ring so that a watcher reconnecting
+with a resume token receives the events it missed; tokens are only valid for
+the lifetime of the process
+*/
+type Broker struct {
+	mu sync.Mutex
+
+	/* random identifier of this process, part of every token */
+	epoch string
+
+	ring []Event
+
+	/* sequence numbers of the oldest and newest buffered events */
+	first uint64
+	last  uint64
+
+	/* closed and replaced on every publish to wake up waiting watchers */
+	wake chan struct{}
+
+	closed bool
+}
+
+/* creates a broker buffering the last size events */
+func NewBroker(size int) *Broker {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+
+	return &Broker{
+		epoch: hex.EncodeToString(buf),
+		ring:  make([]Event, size),
+		first: 1,
+		wake:  make(chan struct{}),
+	}
+}
+
+/* assigns the next sequence number to e and hands it to the watchers */
+func (b *Broker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.last++
+	e.Seq = b.last
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.ring[b.slot(e.Seq)] = e
+	if b.last-b.first >= uint64(len(b.ring)) {
+		b.first++
+	}
+
+	close(b.wake)
+	b.wake = make(chan struct{})
+}
+
+/* wakes up every watcher, which then fails with ErrClosed */
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+	close(b.wake)
+}
+
+/* opaque token resuming a watch right after e */
+func (b *Broker) Token(e *Event) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(b.epoch + "." + strconv.FormatUint(e.Seq, 10)))
+}
+
+/*
+starts watching changes reaching prefix (empty for every path), from the
+event after the one token was issued for, or from now if token is empty
+*/
+func (b *Broker) Subscribe(prefix, token string) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+
+	sub := &Subscription{broker: b, prefix: prefix, cursor: b.last}
+	if token == "" {
+		return sub, nil
+	}
+
+	epoch, seq, err := parseToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case epoch != b.epoch:
+		/* issued before a restart, the buffer did not survive it */
+		return nil, ErrTokenExpired
+	case seq > b.last:
+		return nil, ErrInvalidToken
+	case seq+1 < b.first:
+		return nil, ErrTokenExpired
+	}
+
+	sub.cursor = seq
+	return sub, nil
+}
+
+func parseToken(token string) (string, uint64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", 0, ErrInvalidToken
+	}
+
+	epoch, seqText, ok := strings.Cut(string(data), ".")
+	if !ok {
+		return "", 0, ErrInvalidToken
+	}
+
+	seq, err := strconv.ParseUint(seqText, 10, 64)
+	if err != nil {
+		return "", 0, ErrInvalidToken
+	}
+	return epoch, seq, nil
+}
+
+/* position of the event with sequence number seq in the ring */
+func (b *Broker) slot(seq uint64) int {
+	return int((seq - 1) % uint64(len(b.ring)))
+}
+
+/* returns the first event after cursor, waiting for it if necessary */
+func (b *Broker) next(ctx context.Context, cursor uint64) (Event, error) {
+	b.mu.Lock()
+	for {
+		switch {
+		case b.closed:
+			b.mu.Unlock()
+			return Event{}, ErrClosed
+		case cursor+1 < b.first:
+			/* the watcher fell behind by more than the ring holds */
+			b.mu.Unlock()
+			return Event{}, ErrTokenExpired
+		case cursor < b.last:
+			e := b.ring[b.slot(cursor+1)]
+			b.mu.Unlock()
+			return e, nil
+		}
+
+		wake := b.wake
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return Event{}, ctx.Err()
+		case <-wake:
+		}
+
+		b.mu.Lock()
+	}
+}
+
+/* stream of the events reaching a path prefix */
+type Subscription struct {
+	broker *Broker
+	prefix string
+
+	/* sequence number of the last event seen */
+	cursor uint64
+}
+
+/* returns the next event reaching the prefix, waiting until one is published */
+func (s *Subscription) Next(ctx context.Context) (*Event, error) {
+	for {
+		e, err := s.broker.next(ctx, s.cursor)
+		if err != nil {
+			return nil, err
+		}
+		s.cursor = e.Seq
+
+		if s.prefix == "" || aclmodel.ChangeWithin(e.Path, e.Recursive, s.prefix) {
+			return &e, nil
+		}
+	}
+}
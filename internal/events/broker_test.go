@@ -0,0 +1,118 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func next(t *testing.T, sub *Subscription) *Event {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	e, err := sub.Next(ctx)
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	return e
+}
+
+func TestSubscribeFiltersByPrefix(t *testing.T) {
+	b := NewBroker(16)
+	sub, err := b.Subscribe("/srv/share", "")
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	b.Publish(Event{Kind: KindApplied, Path: "/srv/shared"})
+	b.Publish(Event{Kind: KindApplied, Path: "/srv/share/docs"})
+	b.Publish(Event{Kind: KindDetected, Path: "/srv/other"})
+	b.Publish(Event{Kind: KindApplied, Path: "/srv", Recursive: true})
+
+	if e := next(t, sub); e.Path != "/srv/share/docs" || e.Seq != 2 {
+		t.Fatalf("first event = %+v, want /srv/share/docs", e)
+	}
+	if e := next(t, sub); e.Path != "/srv" || e.Seq != 4 {
+		t.Fatalf("second event = %+v, want the recursive change of /srv", e)
+	}
+
+	/* nothing more to deliver */
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := sub.Next(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Next error = %v, want a timeout", err)
+	}
+}
+
+func TestResume(t *testing.T) {
+	b := NewBroker(4)
+	for _, path := range []string{"/a", "/b", "/c"} {
+		b.Publish(Event{Path: path})
+	}
+
+	sub, err := b.Subscribe("", "")
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	b.Publish(Event{Path: "/d"})
+	token := b.Token(next(t, sub))
+
+	/* missed events are replayed after the token */
+	b.Publish(Event{Path: "/e"})
+	b.Publish(Event{Path: "/f"})
+	resumed, err := b.Subscribe("", token)
+	if err != nil {
+		t.Fatalf("Subscribe(token): %v", err)
+	}
+	if e := next(t, resumed); e.Path != "/e" {
+		t.Fatalf("resumed at %+v, want /e", e)
+	}
+
+	/* the ring only holds the last 4 events */
+	for _, path := range []string{"/g", "/h", "/i", "/j"} {
+		b.Publish(Event{Path: path})
+	}
+	if _, err := b.Subscribe("", token); !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("Subscribe(old token) error = %v, want ErrTokenExpired", err)
+	}
+
+	/* a watcher that fell behind is told so */
+	if _, err := resumed.Next(context.Background()); !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("Next error = %v, want ErrTokenExpired", err)
+	}
+
+	/* tokens of another process are expired, garbage is invalid */
+	if _, err := NewBroker(4).Subscribe("", token); !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("Subscribe(foreign token) error = %v, want ErrTokenExpired", err)
+	}
+	if _, err := b.Subscribe("", "garbage!"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Subscribe(garbage) error = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestCloseWakesWatchers(t *testing.T) {
+	b := NewBroker(4)
+	sub, err := b.Subscribe("", "")
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := sub.Next(context.Background())
+		done <- err
+	}()
+
+	b.Close()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrClosed) {
+			t.Fatalf("Next error = %v, want ErrClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("watcher still waiting after Close")
+	}
+}
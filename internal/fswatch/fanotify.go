@@ -0,0 +1,206 @@
+package fswatch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"unsafe"
+
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+)
+
+/* events reported for every object of the marked filesystems */
+const fanotifyMask = unix.FAN_ATTRIB | unix.FAN_CREATE | unix.FAN_MOVED_TO | unix.FAN_DELETE |
+	unix.FAN_MOVED_FROM | unix.FAN_ONDIR
+
+/* size of the fsid following the header of an fid info record */
+const sizeofFsid = 8
+
+/*
+notifier watching whole filesystems with fanotify, reporting the objects
+below the roots
+
+a single mark per filesystem covers every directory, present and future, but
+it needs CAP_SYS_ADMIN to mark and CAP_DAC_READ_SEARCH to turn the reported
+file handles back into paths
+*/
+type fanotify struct {
+	/* raw descriptor for fanotify_mark (File.Fd would make it blocking) */
+	fd    int
+	file  *os.File
+	roots []string
+
+	/* directory of each marked filesystem by fsid, used to open file handles */
+	mounts map[[2]int32]*os.File
+}
+
+func newFanotify() (*fanotify, error) {
+	fd, err := unix.FanotifyInit(
+		unix.FAN_CLASS_NOTIF|unix.FAN_REPORT_DFID_NAME|unix.FAN_NONBLOCK|unix.FAN_CLOEXEC,
+		unix.O_RDONLY|unix.O_LARGEFILE,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("fanotify_init: %w", err)
+	}
+
+	return &fanotify{
+		fd:     fd,
+		file:   os.NewFile(uintptr(fd), "fanotify"),
+		mounts: make(map[[2]int32]*os.File),
+	}, nil
+}
+
+func (n *fanotify) name() string {
+	return "fanotify"
+}
+
+/* marks the filesystem of root */
+func (n *fanotify) add(root string) error {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(root, &stat); err != nil {
+		return fmt.Errorf("statfs %s: %w", root, err)
+	}
+
+	fsid := [2]int32{stat.Fsid.Val[0], stat.Fsid.Val[1]}
+	if _, ok := n.mounts[fsid]; !ok {
+		mount, err := os.Open(root)
+		if err != nil {
+			return err
+		}
+
+		err = unix.FanotifyMark(n.fd, unix.FAN_MARK_ADD|unix.FAN_MARK_FILESYSTEM,
+			fanotifyMask, unix.AT_FDCWD, root)
+		if err != nil {
+			mount.Close()
+			return fmt.Errorf("fanotify_mark %s: %w", root, err)
+		}
+		n.mounts[fsid] = mount
+	}
+
+	n.roots = append(n.roots, root)
+	return nil
+}
+
+/* reads notifications until closed or stopped */
+func (n *fanotify) run(ch chan<- notification, stop <-chan struct{}) {
+	buf := make([]byte, 64*1024)
+	for {
+		size, err := n.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				zap.L().Error("Reading fanotify events failed",
+					zap.Error(err),
+				)
+			}
+			return
+		}
+
+		for offset := 0; offset+unix.FAN_EVENT_METADATA_LEN <= size; {
+			meta := (*unix.FanotifyEventMetadata)(unsafe.Pointer(&buf[offset]))
+			if meta.Event_len < uint32(meta.Metadata_len) || offset+int(meta.Event_len) > size {
+				break
+			}
+
+			event := buf[offset : offset+int(meta.Event_len)]
+			offset += int(meta.Event_len)
+
+			if meta.Mask&unix.FAN_Q_OVERFLOW != 0 {
+				if !deliver(ch, stop, notification{op: opOverflow}) {
+					return
+				}
+				continue
+			}
+
+			path, ok := n.resolve(event[meta.Metadata_len:])
+			if !ok || !n.watched(path) {
+				continue
+			}
+			if !n.handle(ch, stop, meta.Mask, path) {
+				return
+			}
+		}
+	}
+}
+
+/* translates a single fanotify event, false once stopped */
+func (n *fanotify) handle(ch chan<- notification, stop <-chan struct{}, mask uint64, path string) bool {
+	isDir := mask&unix.FAN_ONDIR != 0
+
+	switch {
+	case mask&(unix.FAN_CREATE|unix.FAN_MOVED_TO) != 0:
+		return deliver(ch, stop, notification{path: path, op: opCreated, isDir: isDir})
+	case mask&(unix.FAN_DELETE|unix.FAN_MOVED_FROM) != 0:
+		return deliver(ch, stop, notification{path: path, op: opRemoved, isDir: isDir})
+	case mask&unix.FAN_ATTRIB != 0:
+		return deliver(ch, stop, notification{path: path, op: opAttrib, isDir: isDir})
+	}
+	return true
+}
+
+/*
+path of the object an event refers to, from its directory fid and name info
+record: header (type, pad, length), fsid, file handle, NUL-terminated name
+*/
+func (n *fanotify) resolve(info []byte) (string, bool) {
+	const headerSize = 4
+	if len(info) < headerSize+sizeofFsid+8 || info[0] != unix.FAN_EVENT_INFO_TYPE_DFID_NAME {
+		return "", false
+	}
+
+	fsid := [2]int32{
+		int32(binary.NativeEndian.Uint32(info[headerSize:])),
+		int32(binary.NativeEndian.Uint32(info[headerSize+4:])),
+	}
+	mount, ok := n.mounts[fsid]
+	if !ok {
+		return "", false
+	}
+
+	handle := info[headerSize+sizeofFsid:]
+	handleBytes := int(binary.NativeEndian.Uint32(handle))
+	handleType := int32(binary.NativeEndian.Uint32(handle[4:]))
+	if len(handle) < 8+handleBytes {
+		return "", false
+	}
+	name := cString(handle[8+handleBytes:])
+
+	fd, err := unix.OpenByHandleAt(int(mount.Fd()), unix.NewFileHandle(handleType, handle[8:8+handleBytes]), unix.O_PATH)
+	if err != nil {
+		/* the directory is already gone */
+		return "", false
+	}
+	defer unix.Close(fd)
+
+	dir, err := os.Readlink("/proc/self/fd/" + strconv.Itoa(fd))
+	if err != nil {
+		return "", false
+	}
+
+	if name == "" || name == "." {
+		return dir, true
+	}
+	return filepath.Join(dir, name), true
+}
+
+/* reports whether path is below one of the roots (the marks cover whole filesystems) */
+func (n *fanotify) watched(path string) bool {
+	for _, root := range n.roots {
+		if aclmodel.PathWithin(path, root) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *fanotify) close() error {
+	for _, mount := range n.mounts {
+		mount.Close()
+	}
+	return n.file.Close()
+}
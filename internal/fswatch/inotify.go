@@ -0,0 +1,170 @@
+package fswatch
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+)
+
+/* events watched on every directory below the roots */
+const inotifyMask = unix.IN_ATTRIB | unix.IN_CREATE | unix.IN_MOVED_TO | unix.IN_DELETE |
+	unix.IN_MOVED_FROM | unix.IN_DONT_FOLLOW | unix.IN_EXCL_UNLINK | unix.IN_ONLYDIR
+
+/*
+notifier watching every directory below the roots with inotify
+
+inotify is not recursive: a watch is added for each directory, including the
+ones created later, so the number of directories is bounded by
+fs.inotify.max_user_watches
+*/
+type inotify struct {
+	/* raw descriptor for inotify_add_watch (File.Fd would make it blocking) */
+	fd   int
+	file *os.File
+
+	mu sync.Mutex
+
+	/* watched directories by watch descriptor */
+	dirs map[int]string
+}
+
+func newInotify() (*inotify, error) {
+	fd, err := unix.InotifyInit1(unix.IN_NONBLOCK | unix.IN_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("inotify_init1: %w", err)
+	}
+
+	return &inotify{
+		fd: fd,
+
+		/* non-blocking descriptors go through the runtime poller, Close unblocks Read */
+		file: os.NewFile(uintptr(fd), "inotify"),
+		dirs: make(map[int]string),
+	}, nil
+}
+
+func (n *inotify) name() string {
+	return "inotify"
+}
+
+/* watches root and every directory below it */
+func (n *inotify) add(root string) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			/* directories that vanished or cannot be read are skipped */
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		return n.watch(path)
+	})
+}
+
+func (n *inotify) watch(dir string) error {
+	wd, err := unix.InotifyAddWatch(n.fd, dir, inotifyMask)
+	if errors.Is(err, unix.ENOSPC) {
+		return fmt.Errorf("cannot watch %s, raise fs.inotify.max_user_watches: %w", dir, err)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot watch %s: %w", dir, err)
+	}
+
+	n.mu.Lock()
+	n.dirs[wd] = dir
+	n.mu.Unlock()
+	return nil
+}
+
+/* reads notifications until closed or stopped */
+func (n *inotify) run(ch chan<- notification, stop <-chan struct{}) {
+	buf := make([]byte, 64*1024)
+	for {
+		size, err := n.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				zap.L().Error("Reading inotify events failed",
+					zap.Error(err),
+				)
+			}
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= size; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			name := cString(buf[nameStart : nameStart+int(event.Len)])
+			offset = nameStart + int(event.Len)
+
+			if !n.handle(ch, stop, int(event.Wd), event.Mask, name) {
+				return
+			}
+		}
+	}
+}
+
+/* translates a single inotify event, false once stopped */
+func (n *inotify) handle(ch chan<- notification, stop <-chan struct{}, wd int, mask uint32, name string) bool {
+	n.mu.Lock()
+	dir, ok := n.dirs[wd]
+	if mask&unix.IN_IGNORED != 0 {
+		delete(n.dirs, wd)
+	}
+	n.mu.Unlock()
+
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		return deliver(ch, stop, notification{op: opOverflow})
+	}
+	if !ok {
+		return true
+	}
+
+	path := dir
+	if name != "" {
+		path = filepath.Join(dir, name)
+	}
+	isDir := mask&unix.IN_ISDIR != 0
+
+	switch {
+	case mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0:
+		/* new directories need their own watches */
+		if isDir {
+			if err := n.add(path); err != nil {
+				zap.L().Warn("Failed to watch new directory",
+					zap.String("path", path),
+					zap.Error(err),
+				)
+			}
+		}
+		return deliver(ch, stop, notification{path: path, op: opCreated, isDir: isDir})
+	case mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0:
+		return deliver(ch, stop, notification{path: path, op: opRemoved, isDir: isDir})
+	case mask&unix.IN_ATTRIB != 0:
+		return deliver(ch, stop, notification{path: path, op: opAttrib, isDir: isDir})
+	}
+	return true
+}
+
+func (n *inotify) close() error {
+	return n.file.Close()
+}
+
+/* string up to the first NUL byte */
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,476 @@
+package fswatch
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/audit"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/backend"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/events"
+)
+
+/* kind of filesystem notification */
+type op int
+
+const (
+	/* attributes (mode, owner, xattrs) of an object changed */
+	opAttrib op = iota
+
+	/* an object appeared (created or moved in) */
+	opCreated
+
+	/* an object disappeared (deleted or moved out) */
+	opRemoved
+
+	/* the kernel dropped notifications */
+	opOverflow
+)
+
+/* notification about a single object below the roots */
+type notification struct {
+	path  string
+	op    op
+	isDir bool
+}
+
+/* source of filesystem notifications (fanotify or inotify) */
+type notifier interface {
+	name() string
+
+	/* starts watching root and everything below it */
+	add(root string) error
+
+	/* sends notifications to ch until the notifier is closed or stop is closed */
+	run(ch chan<- notification, stop <-chan struct{})
+
+	close() error
+}
+
+/* watcher configuration */
+type Options struct {
+	/* directories watched recursively */
+	Roots []string
+
+	/* backend used to read the ACLs of changed objects */
+	Backend backend.Backend
+
+	/* audit log and broker receiving external changes (either may be nil) */
+	Audit  *audit.Log
+	Events *events.Broker
+
+	/* time to let a burst of notifications (setfacl -R) settle before reading ACLs */
+	Debounce time.Duration
+
+	/* time after an aclapi change during which notifications are attributed to it */
+	SuppressWindow time.Duration
+
+	/* use inotify even where fanotify is available */
+	NoFanotify bool
+
+	/* objects whose ACL is kept in memory (0 for no limit), changes of further objects go unnoticed */
+	MaxObjects int
+}
+
+/* change made by aclapi itself, its notifications are not external changes */
+type expectation struct {
+	path      string
+	recursive bool
+
+	/* changes still running */
+	running int
+
+	/* end of the suppression once the last change finished */
+	until time.Time
+}
+
+/*
+notices ACL and mode changes made below the roots outside of aclapi (setfacl,
+chmod run by hand), records them in the audit log as external changes and
+publishes them to watchers
+
+the ACL of every object below the roots is kept in memory, up to MaxObjects,
+notifications only count as changes when the ACL read afterwards differs from it
+*/
+type Watcher struct {
+	opts     Options
+	notifier notifier
+
+	mu sync.Mutex
+
+	/* last known ACL of every object below the roots, at most MaxObjects */
+	known map[string]*aclmodel.ACL
+
+	/* MaxObjects was reached, only logged the first time */
+	full bool
+
+	/* changes aclapi is making or just made */
+	expected []*expectation
+
+	/* objects notified since the last flush, true if only aclapi touched them */
+	dirty map[string]bool
+
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+/* creates a watcher, preferring fanotify and falling back to inotify */
+func New(opts Options) (*Watcher, error) {
+	w := &Watcher{
+		opts:  opts,
+		known: make(map[string]*aclmodel.ACL),
+		dirty: make(map[string]bool),
+		stop:  make(chan struct{}),
+	}
+
+	if !opts.NoFanotify {
+		n, err := newFanotify()
+		if err == nil {
+			err = addRoots(n, opts.Roots)
+		}
+		if err == nil {
+			w.notifier = n
+			return w, nil
+		}
+
+		zap.L().Info("fanotify unavailable, watching share roots with inotify",
+			zap.Error(err),
+		)
+		if n != nil {
+			n.close()
+		}
+	}
+
+	n, err := newInotify()
+	if err != nil {
+		return nil, err
+	}
+	if err := addRoots(n, opts.Roots); err != nil {
+		n.close()
+		return nil, err
+	}
+
+	w.notifier = n
+	return w, nil
+}
+
+func addRoots(n notifier, roots []string) error {
+	for _, root := range roots {
+		if err := n.add(root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* starts processing notifications and records the current ACLs in the background */
+func (w *Watcher) Start() {
+	ch := make(chan notification, 1024)
+
+	w.done.Add(3)
+	go func() {
+		defer w.done.Done()
+		w.notifier.run(ch, w.stop)
+	}()
+	go func() {
+		defer w.done.Done()
+		w.receive(ch)
+	}()
+	go func() {
+		defer w.done.Done()
+		w.scan()
+	}()
+
+	zap.L().Info("Watching share roots for external ACL changes",
+		zap.Strings("roots", w.opts.Roots),
+		zap.String("notifier", w.notifier.name()),
+	)
+}
+
+/*
+tells the watcher that aclapi is about to change path (and everything below it
+if recursive), the returned function must be called once the change is done
+
+a nil watcher expects nothing
+*/
+func (w *Watcher) Expect(path string, recursive bool) (done func()) {
+	if w == nil {
+		return func() {}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	e := &expectation{path: path, recursive: recursive, running: 1}
+	w.expected = append(w.expected, e)
+
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		/* notifications are delivered asynchronously, keep suppressing for a while */
+		e.running--
+		e.until = time.Now().Add(w.opts.SuppressWindow)
+	}
+}
+
+/* stops watching, waiting for the background work to finish */
+func (w *Watcher) Close() error {
+	close(w.stop)
+	err := w.notifier.close()
+	w.done.Wait()
+	return err
+}
+
+/* records the current ACL of every object below the roots, up to MaxObjects */
+func (w *Watcher) scan() {
+	for _, root := range w.opts.Roots {
+		filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			select {
+			case <-w.stop:
+				return filepath.SkipAll
+			default:
+			}
+
+			if err != nil || entry.Type()&fs.ModeSymlink != 0 {
+				return nil
+			}
+
+			w.mu.Lock()
+			_, ok := w.known[path]
+			full := w.fullLocked()
+			w.mu.Unlock()
+
+			/* every further read would be thrown away */
+			if full {
+				return filepath.SkipAll
+			}
+
+			/* objects notified in the meantime were already read */
+			if !ok {
+				w.refresh(path)
+			}
+			return nil
+		})
+	}
+}
+
+/*
+collects notifications and flushes them once none arrived for the debounce
+time, or after ten times that during a continuous stream of notifications
+*/
+func (w *Watcher) receive(ch <-chan notification) {
+	timer := time.NewTimer(w.opts.Debounce)
+	timer.Stop()
+
+	var first time.Time
+	for {
+		select {
+		case <-w.stop:
+			timer.Stop()
+			return
+		case n := <-ch:
+			w.note(n)
+
+			now := time.Now()
+			if first.IsZero() {
+				first = now
+			}
+			if now.Sub(first) < 10*w.opts.Debounce {
+				timer.Reset(w.opts.Debounce)
+			}
+		case <-timer.C:
+			first = time.Time{}
+			w.flush()
+		}
+	}
+}
+
+/* marks the object of a notification as dirty */
+func (w *Watcher) note(n notification) {
+	if n.op == opOverflow {
+		zap.L().Warn("Filesystem notifications were dropped, external changes may go unnoticed")
+		return
+	}
+
+	/* a directory moved in brings its whole tree along, walked before locking to not hold up Expect */
+	var tree []string
+	if n.op == opCreated && n.isDir {
+		tree = w.walk(n.path)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if n.op == opRemoved {
+		for path := range w.known {
+			if aclmodel.PathWithin(path, n.path) {
+				delete(w.known, path)
+			}
+		}
+		delete(w.dirty, n.path)
+		return
+	}
+
+	/* new objects are recorded, not reported (their ACL was inherited or set by aclapi) */
+	silent := n.op == opCreated || w.expectedLocked(n.path)
+	if wasSilent, ok := w.dirty[n.path]; ok {
+		silent = silent && wasSilent
+	}
+	w.dirty[n.path] = silent
+
+	for _, path := range tree {
+		w.dirty[path] = true
+	}
+}
+
+/* objects below dir, no more than can be recorded */
+func (w *Watcher) walk(dir string) []string {
+	var tree []string
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if w.opts.MaxObjects > 0 && len(tree) >= w.opts.MaxObjects {
+			return filepath.SkipAll
+		}
+		if err == nil && entry.Type()&fs.ModeSymlink == 0 {
+			tree = append(tree, path)
+		}
+		return nil
+	})
+	return tree
+}
+
+/* reports whether no further objects can be recorded, logging it the first time */
+func (w *Watcher) fullLocked() bool {
+	if w.opts.MaxObjects <= 0 || len(w.known) < w.opts.MaxObjects {
+		return false
+	}
+
+	if !w.full {
+		zap.L().Warn("Watcher object limit reached, changes of further objects go unnoticed",
+			zap.Int("max_objects", w.opts.MaxObjects),
+		)
+		w.full = true
+	}
+	return true
+}
+
+/* hands n to the watcher, false once it stopped and nobody receives any more */
+func deliver(ch chan<- notification, stop <-chan struct{}, n notification) bool {
+	select {
+	case ch <- n:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+/* reports whether aclapi is changing path or just did */
+func (w *Watcher) expectedLocked(path string) bool {
+	now := time.Now()
+
+	active := w.expected[:0]
+	var found bool
+	for _, e := range w.expected {
+		if e.running == 0 && now.After(e.until) {
+			continue
+		}
+		active = append(active, e)
+
+		if path == e.path || (e.recursive && aclmodel.PathWithin(path, e.path)) {
+			found = true
+		}
+	}
+	w.expected = active
+
+	return found
+}
+
+/* reads the ACLs of the dirty objects, reporting the ones that changed */
+func (w *Watcher) flush() {
+	w.mu.Lock()
+	dirty := w.dirty
+	w.dirty = make(map[string]bool)
+	w.mu.Unlock()
+
+	for path, silent := range dirty {
+		before, after, changed := w.refresh(path)
+		if changed && !silent {
+			w.report(path, before, after)
+		}
+	}
+}
+
+/*
+reads the ACL of path and records it, returning the ACL known before and
+whether it differs from the new one (never for objects seen the first time)
+new objects are neither read nor recorded once MaxObjects is reached
+*/
+func (w *Watcher) refresh(path string) (before, after *aclmodel.ACL, changed bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	w.mu.Lock()
+	_, known := w.known[path]
+	full := !known && w.fullLocked()
+	w.mu.Unlock()
+
+	/* not recorded, its ACL would be thrown away */
+	if full {
+		return nil, nil, false
+	}
+
+	after, err := w.opts.Backend.Get(ctx, path)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	before, known = w.known[path]
+	if err != nil {
+		/* vanished or unreadable, nothing to compare with */
+		delete(w.known, path)
+		return nil, nil, false
+	}
+	if !known && w.fullLocked() {
+		return nil, nil, false
+	}
+
+	w.known[path] = after
+	return before, after, known && !reflect.DeepEqual(before, after)
+}
+
+/* records and publishes an external change */
+func (w *Watcher) report(path string, before, after *aclmodel.ACL) {
+	zap.L().Warn("ACL changed outside of aclapi",
+		zap.String("path", path),
+	)
+
+	if w.opts.Audit != nil {
+		err := w.opts.Audit.Append(&audit.Record{
+			Source:  audit.SourceExternal,
+			Path:    path,
+			Before:  before,
+			After:   after,
+			Outcome: audit.OutcomeSuccess,
+		})
+		if err != nil {
+			zap.L().Error("Failed to write audit record",
+				zap.String("path", path),
+				zap.Error(err),
+			)
+		}
+	}
+
+	if w.opts.Events != nil {
+		w.opts.Events.Publish(events.Event{
+			Kind: events.KindDetected,
+			Path: path,
+			ACL:  after,
+		})
+	}
+}
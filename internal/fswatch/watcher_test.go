@@ -0,0 +1,248 @@
+package fswatch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/audit"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/backend"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/events"
+)
+
+/* key of the hash chain of test audit logs */
+var auditKey = []byte("0123456789abcdef0123456789abcdef")
+
+/* waits for the next change event, failing the test after a few seconds */
+func nextEvent(t *testing.T, sub *events.Subscription) *events.Event {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e, err := sub.Next(ctx)
+	if err != nil {
+		t.Fatalf("no change event: %v", err)
+	}
+	return e
+}
+
+/* waits until the initial scan recorded the ACL of path */
+func waitKnown(t *testing.T, w *Watcher, path string) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		w.mu.Lock()
+		_, ok := w.known[path]
+		w.mu.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("ACL of %s never recorded", path)
+}
+
+func TestDetectsExternalChanges(t *testing.T) {
+	for _, notifierName := range []string{"inotify", "fanotify"} {
+		t.Run(notifierName, func(t *testing.T) {
+			root := t.TempDir()
+			sub := filepath.Join(root, "sub")
+			file := filepath.Join(sub, "file")
+			created := filepath.Join(sub, "created")
+			if err := os.Mkdir(sub, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(file, nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			auditLog, err := audit.Open(filepath.Join(t.TempDir(), "audit.log"), audit.Options{Key: auditKey})
+			if err != nil {
+				t.Fatalf("audit.Open: %v", err)
+			}
+			defer auditLog.Close()
+
+			broker := events.NewBroker(16)
+			changes, err := broker.Subscribe(root, "")
+			if err != nil {
+				t.Fatalf("Subscribe: %v", err)
+			}
+
+			w, err := New(Options{
+				Roots:          []string{root},
+				Backend:        backend.NewXattr(),
+				Audit:          auditLog,
+				Events:         broker,
+				Debounce:       20 * time.Millisecond,
+				SuppressWindow: 500 * time.Millisecond,
+				NoFanotify:     notifierName == "inotify",
+			})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			defer w.Close()
+			if w.notifier.name() != notifierName {
+				t.Skipf("%s unavailable, got %s", notifierName, w.notifier.name())
+			}
+
+			w.Start()
+			waitKnown(t, w, file)
+			waitKnown(t, w, sub)
+
+			/* chmod rewrites the ACL entries of the owner, group and others */
+			if err := os.Chmod(file, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if e := nextEvent(t, changes); e.Kind != events.KindDetected || e.Path != file {
+				t.Fatalf("event = %+v, want detected change of %s", e, file)
+			}
+
+			/* changes announced by aclapi and new objects are not reported */
+			done := w.Expect(file, false)
+			if err := os.Chmod(file, 0o640); err != nil {
+				t.Fatal(err)
+			}
+			done()
+			if err := os.WriteFile(created, nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(sub, 0o700); err != nil {
+				t.Fatal(err)
+			}
+			if e := nextEvent(t, changes); e.Path != sub {
+				t.Fatalf("event = %+v, want detected change of %s", e, sub)
+			}
+
+			/* but later changes of new objects are */
+			time.Sleep(100 * time.Millisecond)
+			if err := os.Chmod(created, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if e := nextEvent(t, changes); e.Path != created {
+				t.Fatalf("event = %+v, want detected change of %s", e, created)
+			}
+
+			records, _, err := auditLog.Query(&audit.Query{})
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			want := []string{file, sub, created}
+			if len(records) != len(want) {
+				t.Fatalf("got %d audit records, want %d", len(records), len(want))
+			}
+			for i, record := range records {
+				if record.Source != audit.SourceExternal || record.Path != want[i] ||
+					record.Before == nil || record.After == nil {
+					t.Fatalf("audit record %d = %+v, want external change of %s", i, record, want[i])
+				}
+			}
+		})
+	}
+}
+
+/* backend counting the ACLs read */
+type countingBackend struct {
+	backend.Backend
+	gets atomic.Int64
+}
+
+func (b *countingBackend) Get(ctx context.Context, path string) (*aclmodel.ACL, error) {
+	b.gets.Add(1)
+	return b.Backend.Get(ctx, path)
+}
+
+func TestMaxObjects(t *testing.T) {
+	root := t.TempDir()
+	for i := range 5 {
+		if err := os.WriteFile(filepath.Join(root, strconv.Itoa(i)), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	counting := &countingBackend{Backend: backend.NewXattr()}
+	w, err := New(Options{
+		Roots:      []string{root},
+		Backend:    counting,
+		Debounce:   20 * time.Millisecond,
+		NoFanotify: true,
+		MaxObjects: 3,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer w.Close()
+	w.Start()
+
+	/* the initial scan stops at the limit */
+	for deadline := time.Now().Add(5 * time.Second); counting.gets.Load() < 3 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	/* a tree moved in is not read beyond the limit either */
+	tree := filepath.Join(t.TempDir(), "tree")
+	if err := os.Mkdir(tree, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 5 {
+		if err := os.WriteFile(filepath.Join(tree, strconv.Itoa(i)), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Rename(tree, filepath.Join(root, "tree")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	w.mu.Lock()
+	known := len(w.known)
+	w.mu.Unlock()
+	if known != 3 {
+		t.Fatalf("recorded %d objects, want 3", known)
+	}
+	if gets := counting.gets.Load(); gets != 3 {
+		t.Fatalf("read %d ACLs, want 3", gets)
+	}
+}
+
+func TestStoppedDelivery(t *testing.T) {
+	n, err := newInotify()
+	if err != nil {
+		t.Fatalf("newInotify: %v", err)
+	}
+	defer n.close()
+
+	root := t.TempDir()
+	if err := n.add(root); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	wd := -1
+	for descriptor := range n.dirs {
+		wd = descriptor
+	}
+
+	/* nobody receives once the watcher stopped, the notifier must not block Close */
+	ch := make(chan notification)
+	stop := make(chan struct{})
+	close(stop)
+
+	returned := make(chan bool)
+	go func() {
+		returned <- n.handle(ch, stop, wd, unix.IN_ATTRIB, "file")
+	}()
+	select {
+	case ok := <-returned:
+		if ok {
+			t.Fatal("handle reported a delivered notification")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("handle blocked after stop")
+	}
+}
@@ -0,0 +1,960 @@
+package grpcserver_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/config"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/acl"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclcore"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/audit"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/backend"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/directory"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/drift"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/events"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/grpcserver"
+	pb "github.com/PythonHacker24/linux-acl-management-aclapi/internal/grpcserver/protos"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/identity"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/pathlock"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/ratelimit"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/testutil/fakecore"
+)
+
+/* key of the hash chain of test audit logs */
+var auditKey = []byte("0123456789abcdef0123456789abcdef")
+
+/* gRPC server with the ACL service in front of a fake aclcore */
+type env struct {
+	core   *fakecore.Server
+	server *acl.ACLServer
+	client pb.ACLServiceClient
+}
+
+/* serverOpts may add interceptors, they run after the trace and logging ones */
+func newEnv(t *testing.T, opts aclcore.Options, serverOpts ...grpc.ServerOption) *env {
+	t.Helper()
+
+	core := fakecore.New(t)
+	aclBackend := backend.NewCore(core.Client(t, opts))
+
+	grpcServer := grpc.NewServer(append([]grpc.ServerOption{grpc.ChainUnaryInterceptor(
+		grpcserver.UnaryTraceInterceptor(),
+		grpcserver.UnaryServerInterceptor(),
+	)}, serverOpts...)...)
+	aclServer := acl.NewACLServer(testDirectory, aclBackend)
+	aclServer.Events = events.NewBroker(64)
+	pb.RegisterACLServiceServer(grpcServer, aclServer)
+
+	listener := bufconn.Listen(1 << 20)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return &env{core: core, server: aclServer, client: pb.NewACLServiceClient(conn)}
+}
+
+func TestApplyLegacyEntry(t *testing.T) {
+	e := newEnv(t, aclcore.Options{})
+	e.core.AddDir("/srv/share", 0o750)
+
+	resp, err := e.client.ApplyACLEntry(context.Background(), &pb.ApplyACLRequest{
+		TransactionID: "txn-1",
+		TargetPath:    "/srv/share",
+		Entry: &pb.ACLEntry{
+			EntityType:  "user",
+			Entity:      "alice",
+			Permissions: "rwX",
+			Action:      "add",
+		},
+	})
+	if err != nil {
+		t.Fatalf("ApplyACLEntry: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("ApplyACLEntry not successful: %+v", resp)
+	}
+
+	/* the principal is resolved before the request reaches aclcore */
+	applies := e.core.RequestsFor(aclcore.OpApply)
+	if len(applies) != 1 {
+		t.Fatalf("aclcore received %d apply requests, want 1", len(applies))
+	}
+	var sent aclcore.ApplyRequest
+	if err := applies[0].Decode(&sent); err != nil {
+		t.Fatalf("decoding apply request: %v", err)
+	}
+	if sent.TxnID != "txn-1" || sent.Entry.Qualifier != "alice" || sent.Entry.ID == nil || *sent.Entry.ID != 1001 {
+		t.Fatalf("aclcore received %+v", sent)
+	}
+
+	entry := findEntry(e.core.ACL("/srv/share").Access, aclmodel.TagUser, 1001)
+	if entry == nil || entry.Perms != aclmodel.PermRWX {
+		t.Fatalf("stored entry = %+v, want user 1001 rwx", entry)
+	}
+}
+
+func TestApplyRecursiveAndRemove(t *testing.T) {
+	e := newEnv(t, aclcore.Options{})
+	e.core.AddDir("/srv/share", 0o750)
+	e.core.AddFile("/srv/share/a", 0o640)
+	e.core.AddFile("/srv/other", 0o640)
+
+	apply := func(action pb.Action, recursive bool) {
+		t.Helper()
+		_, err := e.client.ApplyACLEntry(context.Background(), &pb.ApplyACLRequest{
+			TargetPath: "/srv/share",
+			Recursive:  recursive,
+			EntryV2: &pb.ACLEntryV2{
+				EntityType:  pb.EntityType_ENTITY_TYPE_GROUP,
+				Entity:      "staff",
+				Permissions: uint32(pb.Permission_PERMISSION_READ),
+				Action:      action,
+			},
+		})
+		if err != nil {
+			t.Fatalf("ApplyACLEntry(%s): %v", action, err)
+		}
+	}
+
+	apply(pb.Action_ACTION_ADD, true)
+	for _, path := range []string{"/srv/share", "/srv/share/a"} {
+		if findEntry(e.core.ACL(path).Access, aclmodel.TagGroup, 2001) == nil {
+			t.Fatalf("%s has no staff entry after recursive add", path)
+		}
+	}
+	if findEntry(e.core.ACL("/srv/other").Access, aclmodel.TagGroup, 2001) != nil {
+		t.Fatal("recursive add escaped the target tree")
+	}
+
+	apply(pb.Action_ACTION_REMOVE, false)
+	if findEntry(e.core.ACL("/srv/share").Access, aclmodel.TagGroup, 2001) != nil {
+		t.Fatal("staff entry still present after remove")
+	}
+}
+
+func TestGetACL(t *testing.T) {
+	e := newEnv(t, aclcore.Options{})
+	e.core.AddDir("/srv/share", 0o750)
+
+	/* a uid the directory does not know, set behind aclapi's back */
+	uid, unknown := uint32(1001), uint32(4242)
+	_, err := e.core.Client(t, aclcore.Options{}).Apply(context.Background(), &aclcore.ApplyRequest{
+		Action: aclmodel.ActionAdd,
+		Path:   "/srv/share",
+		Entry:  aclmodel.Entry{Tag: aclmodel.TagUser, ID: &unknown, Perms: aclmodel.PermRead},
+	})
+	if err != nil {
+		t.Fatalf("seeding ACL: %v", err)
+	}
+	_, err = e.client.ApplyACLEntry(context.Background(), &pb.ApplyACLRequest{
+		TargetPath: "/srv/share",
+		EntryV2: &pb.ACLEntryV2{
+			EntityType:  pb.EntityType_ENTITY_TYPE_USER,
+			Entity:      "alice",
+			Permissions: uint32(pb.Permission_PERMISSION_READ | pb.Permission_PERMISSION_WRITE),
+			Action:      pb.Action_ACTION_ADD,
+			IsDefault:   true,
+		},
+	})
+	if err != nil {
+		t.Fatalf("ApplyACLEntry: %v", err)
+	}
+
+	resp, err := e.client.GetACL(context.Background(), &pb.GetACLRequest{Path: "/srv/share"})
+	if err != nil {
+		t.Fatalf("GetACL: %v", err)
+	}
+
+	entities := map[string]uint32{}
+	for _, entry := range resp.Access {
+		entities[entry.EntityType.String()+":"+entry.Entity] = entry.Permissions
+	}
+	want := map[string]uint32{
+		"ENTITY_TYPE_USER:":     7,
+		"ENTITY_TYPE_USER:4242": 4,
+		"ENTITY_TYPE_GROUP:":    5,
+		"ENTITY_TYPE_MASK:":     5,
+		"ENTITY_TYPE_OTHER:":    0,
+	}
+	if len(entities) != len(want) {
+		t.Fatalf("access entries = %v, want %v", entities, want)
+	}
+	for key, perms := range want {
+		if got, ok := entities[key]; !ok || got != perms {
+			t.Fatalf("access entries = %v, want %v", entities, want)
+		}
+	}
+
+	var found bool
+	for _, entry := range resp.DefaultEntries {
+		if entry.Entity == "alice" && entry.GetId() == uid && entry.IsDefault {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("default entries %v lack alice", resp.DefaultEntries)
+	}
+}
+
+func TestExpandGroup(t *testing.T) {
+	e := newEnv(t, aclcore.Options{})
+
+	resp, err := e.client.ExpandGroup(context.Background(), &pb.ExpandGroupRequest{Group: "staff"})
+	if err != nil {
+		t.Fatalf("ExpandGroup: %v", err)
+	}
+	if resp.Gid != 2001 || len(resp.Members) != 2 {
+		t.Fatalf("ExpandGroup = %+v", resp)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	validRequest := func() *pb.ApplyACLRequest {
+		return &pb.ApplyACLRequest{
+			TargetPath: "/srv/share",
+			EntryV2: &pb.ACLEntryV2{
+				EntityType:  pb.EntityType_ENTITY_TYPE_USER,
+				Entity:      "alice",
+				Permissions: uint32(pb.Permission_PERMISSION_READ),
+				Action:      pb.Action_ACTION_ADD,
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		script  func(core *fakecore.Server)
+		request func() *pb.ApplyACLRequest
+		timeout time.Duration
+		code    codes.Code
+		reason  string
+	}{
+		{
+			name:    "invalid request",
+			request: func() *pb.ApplyACLRequest { r := validRequest(); r.TargetPath = "relative"; return r },
+			code:    codes.InvalidArgument,
+			reason:  acl.ReasonInvalidArgument,
+		},
+		{
+			name:    "unknown entity",
+			request: func() *pb.ApplyACLRequest { r := validRequest(); r.EntryV2.Entity = "mallory"; return r },
+			code:    codes.NotFound,
+			reason:  acl.ReasonEntityNotFound,
+		},
+		{
+			name:    "missing path",
+			request: func() *pb.ApplyACLRequest { r := validRequest(); r.TargetPath = "/srv/missing"; return r },
+			code:    codes.NotFound,
+			reason:  acl.ReasonPathNotFound,
+		},
+		{
+			name:   "default entry on a file",
+			script: func(core *fakecore.Server) { core.AddFile("/srv/share/file", 0o640) },
+			request: func() *pb.ApplyACLRequest {
+				r := validRequest()
+				r.TargetPath, r.EntryV2.IsDefault = "/srv/share/file", true
+				return r
+			},
+			code:   codes.InvalidArgument,
+			reason: acl.ReasonInvalidArgument,
+		},
+		{
+			name:    "permission denied",
+			script:  func(core *fakecore.Server) { core.FailErrno(aclcore.OpApply, syscall.EACCES, 1) },
+			request: validRequest,
+			code:    codes.PermissionDenied,
+			reason:  acl.ReasonPermissionDenied,
+		},
+		{
+			name:    "refused connection",
+			script:  func(core *fakecore.Server) { core.Fail(aclcore.OpHello, fakecore.FailRefuse, 0) },
+			request: validRequest,
+			code:    codes.Unavailable,
+			reason:  acl.ReasonCoreUnavailable,
+		},
+		{
+			name:    "hang",
+			script:  func(core *fakecore.Server) { core.Fail(aclcore.OpApply, fakecore.FailHang, 0) },
+			request: validRequest,
+			timeout: 200 * time.Millisecond,
+			code:    codes.DeadlineExceeded,
+			reason:  acl.ReasonCoreTimeout,
+		},
+		{
+			name:    "malformed response",
+			script:  func(core *fakecore.Server) { core.Fail(aclcore.OpApply, fakecore.FailMalformed, 0) },
+			request: validRequest,
+			code:    codes.Internal,
+			reason:  acl.ReasonCoreProtocolError,
+		},
+		{
+			name:    "oversized response",
+			script:  func(core *fakecore.Server) { core.Fail(aclcore.OpApply, fakecore.FailOversized, 0) },
+			request: validRequest,
+			code:    codes.Internal,
+			reason:  acl.ReasonCoreProtocolError,
+		},
+		{
+			name:    "missing capability",
+			script:  func(core *fakecore.Server) { core.SetCapabilities(aclcore.CapCancel) },
+			request: validRequest,
+			code:    codes.Internal,
+			reason:  acl.ReasonCoreProtocolError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEnv(t, aclcore.Options{})
+			e.core.AddDir("/srv/share", 0o750)
+			if tt.script != nil {
+				tt.script(e.core)
+			}
+
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			_, err := e.client.ApplyACLEntry(ctx, tt.request())
+			assertStatus(t, err, tt.code, tt.reason)
+		})
+	}
+}
+
+/* an abandoned request is cancelled in aclcore instead of running on */
+func TestDeadlineSendsCancel(t *testing.T) {
+	e := newEnv(t, aclcore.Options{})
+	e.core.AddDir("/srv/share", 0o750)
+	e.core.Fail(aclcore.OpApply, fakecore.FailHang, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	_, err := e.client.ApplyACLEntry(ctx, &pb.ApplyACLRequest{
+		TargetPath: "/srv/share",
+		Entry:      &pb.ACLEntry{EntityType: "other", Permissions: "r--", Action: "modify"},
+	})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("ApplyACLEntry error = %v, want DeadlineExceeded", err)
+	}
+
+	applies := e.core.RequestsFor(aclcore.OpApply)
+	if len(applies) != 1 {
+		t.Fatalf("aclcore received %d apply requests, want 1", len(applies))
+	}
+
+	/* the cancel notice is written asynchronously to the gRPC error */
+	deadline := time.Now().Add(time.Second)
+	for {
+		cancels := e.core.RequestsFor(aclcore.OpCancel)
+		if len(cancels) == 1 {
+			var notice aclcore.Cancel
+			if err := cancels[0].Decode(&notice); err != nil || notice.RequestID != applies[0].ID {
+				t.Fatalf("cancel notice = %+v (%v), want request ID %d", notice, err, applies[0].ID)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("aclcore received %d cancel notices, want 1", len(cancels))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+/* idempotent operations survive a transient failure */
+func TestRetryAfterRefusal(t *testing.T) {
+	e := newEnv(t, aclcore.Options{Retry: aclcore.RetryPolicy{MaxAttempts: 3}})
+	e.core.AddDir("/srv/share", 0o750)
+	e.core.Fail(aclcore.OpHello, fakecore.FailRefuse, 1)
+
+	_, err := e.client.ApplyACLEntry(context.Background(), &pb.ApplyACLRequest{
+		TargetPath: "/srv/share",
+		Entry:      &pb.ACLEntry{EntityType: "other", Permissions: "r--", Action: "modify"},
+	})
+	if err != nil {
+		t.Fatalf("ApplyACLEntry: %v", err)
+	}
+
+	if hellos := e.core.RequestsFor(aclcore.OpHello); len(hellos) != 2 {
+		t.Fatalf("aclcore received %d hellos, want 2", len(hellos))
+	}
+}
+
+/* the caller's trace reaches aclcore and comes back in the response headers */
+func TestTracePropagation(t *testing.T) {
+	e := newEnv(t, aclcore.Options{})
+	e.core.AddDir("/srv/share", 0o750)
+
+	const (
+		traceID   = "4bf92f3577b34da6a3ce929d0e0e4736"
+		requestID = "backend-req-42"
+	)
+	ctx := metadata.AppendToOutgoingContext(context.Background(),
+		"traceparent", "00-"+traceID+"-00f067aa0ba902b7-01",
+		"x-request-id", requestID,
+	)
+
+	var header metadata.MD
+	_, err := e.client.ApplyACLEntry(ctx, &pb.ApplyACLRequest{
+		TargetPath: "/srv/share",
+		Entry:      &pb.ACLEntry{EntityType: "other", Permissions: "r--", Action: "modify"},
+	}, grpc.Header(&header))
+	if err != nil {
+		t.Fatalf("ApplyACLEntry: %v", err)
+	}
+
+	echoed := header.Get("traceparent")
+	if len(echoed) != 1 || !strings.HasPrefix(echoed[0], "00-"+traceID+"-") || strings.Contains(echoed[0], "00f067aa0ba902b7") {
+		t.Fatalf("traceparent header = %v, want a new span of trace %s", echoed, traceID)
+	}
+	if got := header.Get("x-request-id"); len(got) != 1 || got[0] != requestID {
+		t.Fatalf("x-request-id header = %v, want %s", got, requestID)
+	}
+
+	for _, req := range e.core.Requests() {
+		if req.Traceparent != echoed[0] || req.RequestID != requestID {
+			t.Fatalf("aclcore %s request carried %q/%q, want %q/%q", req.Op, req.Traceparent, req.RequestID, echoed[0], requestID)
+		}
+	}
+
+	/* without trace context a new trace is started */
+	header = nil
+	_, err = e.client.ApplyACLEntry(context.Background(), &pb.ApplyACLRequest{
+		TargetPath: "/srv/share",
+		Entry:      &pb.ACLEntry{EntityType: "other", Permissions: "r--", Action: "modify"},
+	}, grpc.Header(&header))
+	if err != nil {
+		t.Fatalf("ApplyACLEntry: %v", err)
+	}
+	if got := header.Get("traceparent"); len(got) != 1 || strings.Contains(got[0], traceID) {
+		t.Fatalf("traceparent header = %v, want a new trace", got)
+	}
+}
+
+/* records changes in a new audit log, returning its path */
+func (e *env) enableAudit(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := audit.Open(path, audit.Options{Key: auditKey})
+	if err != nil {
+		t.Fatalf("audit.Open: %v", err)
+	}
+	t.Cleanup(func() { auditLog.Close() })
+	e.server.Audit = auditLog
+	return path
+}
+
+/* applied and failed changes end up in the audit log with the caller and both ACLs */
+func TestAuditRecordsChanges(t *testing.T) {
+	e := newEnv(t, aclcore.Options{})
+	e.core.AddDir("/srv/share", 0o750)
+
+	path := e.enableAudit(t)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), identity.EndUserKey, "carol")
+	apply := func(path string) error {
+		_, err := e.client.ApplyACLEntry(ctx, &pb.ApplyACLRequest{
+			TransactionID: "txn-audit",
+			TargetPath:    path,
+			Entry:         &pb.ACLEntry{EntityType: "user", Entity: "alice", Permissions: "rw-", Action: "add"},
+		})
+		return err
+	}
+
+	if err := apply("/srv/share"); err != nil {
+		t.Fatalf("ApplyACLEntry: %v", err)
+	}
+	e.core.FailErrno(aclcore.OpApply, syscall.EACCES, 1)
+	if err := apply("/srv/share"); err == nil {
+		t.Fatal("ApplyACLEntry succeeded despite the scripted failure")
+	}
+
+	summary, err := audit.VerifyFile(path, auditKey, "")
+	if err != nil {
+		t.Fatalf("VerifyFile: %v", err)
+	}
+	if summary.Records != 2 {
+		t.Fatalf("audit log has %d records, want 2", summary.Records)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading audit log: %v", err)
+	}
+	var records []audit.Record
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var record audit.Record
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("decoding audit record: %v", err)
+		}
+		records = append(records, record)
+	}
+
+	applied := records[0]
+	if applied.Outcome != audit.OutcomeSuccess || applied.Caller != identity.Unauthenticated || applied.EndUser != "carol" || applied.TxnID != "txn-audit" {
+		t.Fatalf("first record = %+v", applied)
+	}
+	if applied.Before == nil || findEntry(applied.Before.Access, aclmodel.TagUser, 1001) != nil {
+		t.Fatalf("before = %+v, want the ACL without alice", applied.Before)
+	}
+	if applied.After == nil || findEntry(applied.After.Access, aclmodel.TagUser, 1001) == nil {
+		t.Fatalf("after = %+v, want the ACL with alice", applied.After)
+	}
+
+	if failed := records[1]; failed.Outcome != audit.OutcomeFailure || failed.Error == "" {
+		t.Fatalf("second record = %+v, want a failure", failed)
+	}
+}
+
+func TestQueryAudit(t *testing.T) {
+	e := newEnv(t, aclcore.Options{})
+	e.core.AddDir("/srv/share", 0o750)
+	e.core.AddDir("/srv/other", 0o750)
+
+	/* audit history is not available without the audit log */
+	assertStatus(t, recvAudit(e, &pb.QueryAuditRequest{}), codes.FailedPrecondition, acl.ReasonAuditDisabled)
+
+	e.enableAudit(t)
+	for i, change := range []struct{ path, entity string }{
+		{"/srv/share", "alice"},
+		{"/srv/other", "alice"},
+		{"/srv/share", "bob"},
+		{"/srv/share", "alice"},
+	} {
+		_, err := e.client.ApplyACLEntry(context.Background(), &pb.ApplyACLRequest{
+			TransactionID: "txn-" + strconv.Itoa(i+1),
+			TargetPath:    change.path,
+			Entry:         &pb.ACLEntry{EntityType: "user", Entity: change.entity, Permissions: "r-X", Action: "modify"},
+		})
+		if err != nil {
+			t.Fatalf("ApplyACLEntry: %v", err)
+		}
+	}
+
+	/* who was given access to /srv/share, one record per page */
+	query := func(token string) ([]*pb.AuditRecord, string) {
+		t.Helper()
+
+		stream, err := e.client.QueryAudit(context.Background(), &pb.QueryAuditRequest{
+			PathPrefix: "/srv/share",
+			Entity:     "alice",
+			PageSize:   1,
+			PageToken:  token,
+		})
+		if err != nil {
+			t.Fatalf("QueryAudit: %v", err)
+		}
+
+		var records []*pb.AuditRecord
+		var next string
+		for {
+			resp, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return records, next
+			}
+			if err != nil {
+				t.Fatalf("QueryAudit: %v", err)
+			}
+			records = append(records, resp.Record)
+			next = resp.NextPageToken
+		}
+	}
+
+	first, token := query("")
+	if len(first) != 1 || first[0].TransactionId != "txn-1" || token == "" {
+		t.Fatalf("first page = %v, token %q", first, token)
+	}
+
+	record := first[0]
+	if record.Action != pb.Action_ACTION_MODIFY || !record.Success || record.Entry.GetEntity() != "alice" || record.Entry.GetId() != 1001 {
+		t.Fatalf("record = %v", record)
+	}
+	if record.Entry.Permissions != uint32(pb.Permission_PERMISSION_READ|pb.Permission_PERMISSION_CONDITIONAL_EXECUTE) {
+		t.Fatalf("record permissions = %d, want r-X", record.Entry.Permissions)
+	}
+	if len(record.Before) == 0 || len(record.After) <= len(record.Before) {
+		t.Fatalf("record ACLs before %v after %v", record.Before, record.After)
+	}
+
+	second, token := query(token)
+	if len(second) != 1 || second[0].TransactionId != "txn-4" || token != "" {
+		t.Fatalf("second page = %v, token %q", second, token)
+	}
+
+	assertStatus(t, recvAudit(e, &pb.QueryAuditRequest{PageToken: "%%%"}), codes.InvalidArgument, acl.ReasonInvalidArgument)
+}
+
+func TestWatchACLChanges(t *testing.T) {
+	e := newEnv(t, aclcore.Options{})
+	e.core.AddDir("/srv/share", 0o750)
+	e.core.AddDir("/srv/other", 0o750)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watch := func(token string) pb.ACLService_WatchACLChangesClient {
+		t.Helper()
+
+		stream, err := e.client.WatchACLChanges(ctx, &pb.WatchACLChangesRequest{
+			PathPrefix:  "/srv/share",
+			ResumeToken: token,
+		})
+		if err != nil {
+			t.Fatalf("WatchACLChanges: %v", err)
+		}
+
+		/* changes made once the headers arrived are delivered */
+		if _, err := stream.Header(); err != nil {
+			t.Fatalf("WatchACLChanges: %v", err)
+		}
+		return stream
+	}
+	apply := func(path, entity string) {
+		t.Helper()
+
+		_, err := e.client.ApplyACLEntry(context.Background(), &pb.ApplyACLRequest{
+			TransactionID: "txn-" + entity,
+			TargetPath:    path,
+			Entry:         &pb.ACLEntry{EntityType: "user", Entity: entity, Permissions: "r--", Action: "add"},
+		})
+		if err != nil {
+			t.Fatalf("ApplyACLEntry: %v", err)
+		}
+	}
+
+	stream := watch("")
+	apply("/srv/other", "bob")
+	apply("/srv/share", "alice")
+
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	if event.Kind != pb.ChangeKind_CHANGE_KIND_APPLIED || event.Path != "/srv/share" || event.TransactionId != "txn-alice" ||
+		event.Action != pb.Action_ACTION_ADD || event.Entry.GetEntity() != "alice" || event.ResumeToken == "" {
+		t.Fatalf("event = %v", event)
+	}
+
+	/* the ACL after the change is read even though auditing is disabled */
+	if !slices.ContainsFunc(event.Acl, func(entry *pb.ACLEntryInfo) bool { return entry.Entity == "alice" }) {
+		t.Fatalf("event ACL = %v, want an entry for alice", event.Acl)
+	}
+
+	/* a reconnecting watcher receives what it missed */
+	apply("/srv/share", "bob")
+	resumed := watch(event.ResumeToken)
+	missed, err := resumed.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	if missed.TransactionId != "txn-bob" || missed.Path != "/srv/share" {
+		t.Fatalf("missed event = %v", missed)
+	}
+
+	/* tokens from before a restart cannot be honoured */
+	restarted := events.NewBroker(64).Token(&events.Event{Seq: 1})
+	expired, err := e.client.WatchACLChanges(ctx, &pb.WatchACLChangesRequest{ResumeToken: restarted})
+	if err == nil {
+		_, err = expired.Recv()
+	}
+	assertStatus(t, err, codes.OutOfRange, acl.ReasonResumeTokenExpired)
+}
+
+/* without a broker watchers are turned away */
+func TestWatchEventsDisabled(t *testing.T) {
+	e := newEnv(t, aclcore.Options{})
+	e.server.Events = nil
+
+	stream, err := e.client.WatchACLChanges(context.Background(), &pb.WatchACLChangesRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	assertStatus(t, err, codes.FailedPrecondition, acl.ReasonEventsDisabled)
+}
+
+/* ACLs changed behind aclapi's back are reported, and restored in remediated shares */
+func TestDriftReport(t *testing.T) {
+	e := newEnv(t, aclcore.Options{})
+	e.core.AddDir("/srv/share", 0o750)
+	e.core.AddDir("/srv/other", 0o750)
+
+	ctx := context.Background()
+	_, err := e.client.GetDriftReport(ctx, &pb.GetDriftReportRequest{})
+	assertStatus(t, err, codes.FailedPrecondition, acl.ReasonDriftDisabled)
+
+	e.enableAudit(t)
+	for _, path := range []string{"/srv/share", "/srv/other"} {
+		_, err := e.client.ApplyACLEntry(ctx, &pb.ApplyACLRequest{
+			TransactionID: "txn-drift",
+			TargetPath:    path,
+			Entry:         &pb.ACLEntry{EntityType: "user", Entity: "alice", Permissions: "rw-", Action: "add"},
+		})
+		if err != nil {
+			t.Fatalf("ApplyACLEntry: %v", err)
+		}
+	}
+
+	e.server.Drift = drift.New(drift.Options{
+		Policy:  config.DriftReport,
+		Shares:  []config.DriftShare{{Path: "/srv/share", Policy: config.DriftRemediate}},
+		Backend: e.server.Backend,
+		Audit:   e.server.Audit,
+		Events:  e.server.Events,
+		Locks:   e.server.Locks,
+	})
+	_, err = e.client.GetDriftReport(ctx, &pb.GetDriftReportRequest{})
+	assertStatus(t, err, codes.NotFound, acl.ReasonDriftReportNotReady)
+
+	/* someone strips both ACLs without going through the API */
+	for _, path := range []string{"/srv/share", "/srv/other"} {
+		if err := e.server.Backend.Strip(ctx, path); err != nil {
+			t.Fatalf("Strip: %v", err)
+		}
+	}
+
+	report, err := e.client.GetDriftReport(ctx, &pb.GetDriftReportRequest{Rescan: true})
+	if err != nil {
+		t.Fatalf("GetDriftReport: %v", err)
+	}
+	if report.Checked != 2 || len(report.Drifts) != 2 {
+		t.Fatalf("report = %v, want 2 drifts", report)
+	}
+	other, share := report.Drifts[0], report.Drifts[1]
+	if other.Path != "/srv/other" || other.Policy != pb.DriftPolicy_DRIFT_POLICY_REPORT || other.Remediated {
+		t.Fatalf("drift = %v, want /srv/other reported only", other)
+	}
+	if share.Path != "/srv/share" || share.Policy != pb.DriftPolicy_DRIFT_POLICY_REMEDIATE || !share.Remediated || share.Seq != 1 {
+		t.Fatalf("drift = %v, want /srv/share remediated from record 1", share)
+	}
+	if findEntry(e.core.ACL("/srv/share").Access, aclmodel.TagUser, 1001) == nil {
+		t.Fatal("alice's entry on /srv/share was not restored")
+	}
+	if findEntry(e.core.ACL("/srv/other").Access, aclmodel.TagUser, 1001) != nil {
+		t.Fatal("alice's entry on /srv/other was restored despite the report policy")
+	}
+
+	/* the last report can be narrowed down without scanning again */
+	report, err = e.client.GetDriftReport(ctx, &pb.GetDriftReportRequest{PathPrefix: "/srv/other"})
+	if err != nil {
+		t.Fatalf("GetDriftReport: %v", err)
+	}
+	if len(report.Drifts) != 1 || report.Drifts[0].Path != "/srv/other" || len(report.Drifts[0].Expected) == 0 {
+		t.Fatalf("report = %v, want the drift of /srv/other", report)
+	}
+}
+
+/* callers exhausting a method budget are told when to retry, without affecting others */
+func TestRateLimit(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Budget{Rate: 100, Burst: 100}, map[string]ratelimit.Budget{
+		"ApplyACLEntry": {Rate: 0.01, Burst: 2},
+	}, ratelimit.Budget{Rate: 0.01, Burst: 4})
+	e := newEnv(t, aclcore.Options{}, grpc.ChainUnaryInterceptor(grpcserver.UnaryRateLimitInterceptor(limiter)))
+	e.core.AddDir("/srv/share", 0o750)
+
+	apply := func(endUser string) error {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), identity.EndUserKey, endUser)
+		_, err := e.client.ApplyACLEntry(ctx, &pb.ApplyACLRequest{
+			TransactionID: "txn-limit",
+			TargetPath:    "/srv/share",
+			Entry:         &pb.ACLEntry{EntityType: "user", Entity: "alice", Permissions: "r--", Action: "add"},
+		})
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := apply("carol"); err != nil {
+			t.Fatalf("ApplyACLEntry %d: %v", i+1, err)
+		}
+	}
+	err := apply("carol")
+	assertStatus(t, err, codes.ResourceExhausted, acl.ReasonRateLimited)
+
+	var retry *errdetails.RetryInfo
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			retry = info
+		}
+	}
+	if retry == nil || retry.RetryDelay.AsDuration() < 90*time.Second {
+		t.Fatalf("RetryInfo = %v, want a delay of about 100s", retry)
+	}
+
+	/* budgets are per end user and per method */
+	if err := apply("dave"); err != nil {
+		t.Fatalf("ApplyACLEntry for another end user: %v", err)
+	}
+	if _, err := e.client.GetACL(context.Background(), &pb.GetACLRequest{Path: "/srv/share"}); err != nil {
+		t.Fatalf("GetACL: %v", err)
+	}
+
+	/* but naming yet another end user does not get around the budget of the certificate */
+	assertStatus(t, apply("erin"), codes.ResourceExhausted, acl.ReasonRateLimited)
+
+	if got := len(e.core.RequestsFor(aclcore.OpApply)); got != 3 {
+		t.Fatalf("aclcore received %d apply requests, want 3", got)
+	}
+}
+
+/* changes of a path wait for the running one, recursive changes for everything below them */
+func TestPathLocks(t *testing.T) {
+	e := newEnv(t, aclcore.Options{})
+	e.core.AddDir("/srv", 0o755)
+	e.core.AddDir("/srv/share", 0o750)
+	e.core.AddDir("/srv/other", 0o750)
+	e.server.Locks = pathlock.New(pathlock.Options{WaitTimeout: 50 * time.Millisecond})
+
+	apply := func(ctx context.Context, path string, recursive bool) error {
+		_, err := e.client.ApplyACLEntry(ctx, &pb.ApplyACLRequest{
+			TransactionID: "txn-lock",
+			TargetPath:    path,
+			Recursive:     recursive,
+			Entry:         &pb.ACLEntry{EntityType: "user", Entity: "alice", Permissions: "r--", Action: "add"},
+		})
+		return err
+	}
+
+	/* aclcore never answers the first change, which keeps its lock until the deadline */
+	e.core.Fail(aclcore.OpApply, fakecore.FailHang, 1)
+	hung := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		hung <- apply(ctx, "/srv/share", false)
+	}()
+	for e.server.Locks.Stats().Held == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	assertStatus(t, apply(context.Background(), "/srv/share", false), codes.Aborted, acl.ReasonPathLocked)
+	assertStatus(t, apply(context.Background(), "/srv", true), codes.Aborted, acl.ReasonPathLocked)
+	if err := apply(context.Background(), "/srv/other", false); err != nil {
+		t.Fatalf("ApplyACLEntry on another path: %v", err)
+	}
+
+	if err := <-hung; status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("hung ApplyACLEntry error = %v, want DeadlineExceeded", err)
+	}
+	if err := apply(context.Background(), "/srv", true); err != nil {
+		t.Fatalf("ApplyACLEntry once the lock was released: %v", err)
+	}
+	if stats := e.server.Locks.Stats(); stats.Held != 0 || stats.Waiting != 0 {
+		t.Fatalf("lock stats = %+v, want no lock left", stats)
+	}
+}
+
+/* error of the first message of a QueryAudit stream */
+func recvAudit(e *env, req *pb.QueryAuditRequest) error {
+	stream, err := e.client.QueryAudit(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	_, err = stream.Recv()
+	return err
+}
+
+func assertStatus(t *testing.T, err error, code codes.Code, reason string) {
+	t.Helper()
+
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != code {
+		t.Fatalf("error = %v, want code %s", err, code)
+	}
+
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			if info.Reason != reason || info.Domain != acl.ErrorDomain {
+				t.Fatalf("ErrorInfo = %s/%s, want %s/%s", info.Domain, info.Reason, acl.ErrorDomain, reason)
+			}
+			return
+		}
+	}
+
+	/* cancellations and deadlines may be reported by gRPC itself */
+	if code != codes.DeadlineExceeded {
+		t.Fatalf("status %v carries no ErrorInfo", st)
+	}
+}
+
+func findEntry(entries []aclmodel.Entry, tag aclmodel.Tag, id uint32) *aclmodel.Entry {
+	for i, entry := range entries {
+		if entry.Tag == tag && entry.ID != nil && *entry.ID == id {
+			return &entries[i]
+		}
+	}
+	return nil
+}
+
+/* fixed principals used by the suite */
+var testDirectory = staticDirectory{
+	users:  []directory.User{{Name: "alice", UID: 1001, GID: 2001}, {Name: "bob", UID: 1002, GID: 2001}},
+	groups: []directory.Group{{Name: "staff", GID: 2001, Members: []string{"alice", "bob"}}},
+}
+
+type staticDirectory struct {
+	users  []directory.User
+	groups []directory.Group
+}
+
+func (d staticDirectory) LookupUser(ctx context.Context, name string) (*directory.User, error) {
+	for _, user := range d.users {
+		if user.Name == name || strconv.FormatUint(uint64(user.UID), 10) == name {
+			return &user, nil
+		}
+	}
+	return nil, directory.ErrNotFound
+}
+
+func (d staticDirectory) LookupGroup(ctx context.Context, name string) (*directory.Group, error) {
+	for _, group := range d.groups {
+		if group.Name == name || strconv.FormatUint(uint64(group.GID), 10) == name {
+			return &group, nil
+		}
+	}
+	return nil, directory.ErrNotFound
+}
+
+func (d staticDirectory) GroupMembers(ctx context.Context, name string) ([]string, error) {
+	group, err := d.LookupGroup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return group.Members, nil
+}
+
+func (d staticDirectory) Close() error {
+	return nil
+}
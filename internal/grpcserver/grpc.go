@@ -1,25 +1,40 @@
 package grpcserver
 
 import (
+	"crypto/x509"
 	"fmt"
 	"net"
 
 	"github.com/PythonHacker24/linux-acl-management-aclapi/config"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 
 	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/acl"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclcore"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/audit"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/backend"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/directory"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/drift"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/events"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/fswatch"
 	pb "github.com/PythonHacker24/linux-acl-management-aclapi/internal/grpcserver/protos"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/metrics"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/pathlock"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/ratelimit"
 )
 
 func InitServer() (*Server, error) {
 	/* gRPC server options */
 	var opts []grpc.ServerOption
 
+	/* server certificate, its validity is part of the health status */
+	var serverCert *x509.Certificate
+
 	/* loading TLS credentials if specified */
 	if config.APIDConfig.Server.TLSEnabled {
-		creds, err := loadMutualTLSCredentials(
+		creds, cert, err := loadMutualTLSCredentials(
 			config.APIDConfig.Server.TLSCertFile,
 			config.APIDConfig.Server.TLSKeyFile,
 			config.APIDConfig.Server.TLSCACertFile,
@@ -28,29 +43,254 @@ func InitServer() (*Server, error) {
 			return nil, fmt.Errorf("Failed to load TLS certificate and TLS Key files: %w", err)
 		}
 
+		serverCert = cert
 		opts = append(opts, grpc.Creds(creds))
 		zap.L().Info("mTLS enabled for gRPC")
 	} else {
 		zap.L().Warn("Proceeding to start gRPC without TLS")
 	}
 
+	/* principal directory used to validate entities and expand groups */
+	dir, err := directory.New(&config.APIDConfig.Directory)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to initialize principal directory: %w", err)
+	}
+
+	/* backend performing the ACL changes */
+	aclBackend, err := newBackend()
+	if err != nil {
+		dir.Close()
+		return nil, err
+	}
+
+	/* optional tamper-evident record of every ACL change */
+	var auditLog *audit.Log
+	if config.APIDConfig.Audit.Enabled {
+		auditLog, err = openAudit(&config.APIDConfig.Audit)
+		if err != nil {
+			aclBackend.Close()
+			dir.Close()
+			return nil, fmt.Errorf("Failed to open audit log: %w", err)
+		}
+		zap.L().Info("Recording ACL changes in the audit log",
+			zap.String("File", config.APIDConfig.Audit.File),
+		)
+	}
+
+	/* optional stream of ACL changes, only buffered for deployments that watch */
+	var broker *events.Broker
+	if cfg := config.APIDConfig.Events; cfg.Enabled {
+		broker = events.NewBroker(cfg.BufferSize)
+		zap.L().Info("Streaming ACL change events to watchers",
+			zap.Int("buffer_size", cfg.BufferSize),
+		)
+	}
+
+	/* optional detection of ACL changes made around aclapi */
+	var watcher *fswatch.Watcher
+	if cfg := config.APIDConfig.Watcher; cfg.Enabled {
+		watcher, err = fswatch.New(fswatch.Options{
+			Roots:          cfg.Roots,
+			Backend:        aclBackend,
+			Audit:          auditLog,
+			Events:         broker,
+			Debounce:       cfg.Debounce,
+			SuppressWindow: cfg.SuppressWindow,
+			NoFanotify:     cfg.NoFanotify,
+			MaxObjects:     cfg.MaxObjects,
+		})
+		if err != nil {
+			if auditLog != nil {
+				auditLog.Close()
+			}
+			aclBackend.Close()
+			dir.Close()
+			return nil, fmt.Errorf("Failed to watch share roots: %w", err)
+		}
+	}
+
+	/* operations on the same paths run one after the other, drift remediation included */
+	locks := pathlock.New(pathlock.Options{
+		WaitTimeout: config.APIDConfig.Locks.WaitTimeout,
+		Observer:    metrics.ObservePathLock,
+	})
+	if err := metrics.RegisterPathLocks(locks); err != nil {
+		if watcher != nil {
+			watcher.Close()
+		}
+		if auditLog != nil {
+			auditLog.Close()
+		}
+		aclBackend.Close()
+		dir.Close()
+		return nil, err
+	}
+
+	/* optional scheduled comparison with the ACLs recorded in the audit log */
+	var scanner *drift.Scanner
+	if cfg := &config.APIDConfig.Drift; cfg.Enabled {
+		scanner = drift.New(drift.Options{
+			At:       cfg.TimeOfDay(),
+			Interval: cfg.Interval,
+			Policy:   cfg.Policy,
+			Shares:   cfg.Shares,
+			Backend:  aclBackend,
+			Audit:    auditLog,
+			Events:   broker,
+			Watcher:  watcher,
+			Locks:    locks,
+		})
+	}
+
+	/* rejected requests are traced and counted, but never reach the handlers */
+	unaryInterceptors := []grpc.UnaryServerInterceptor{
+		UnaryTraceInterceptor(),
+		metrics.UnaryServerInterceptor(),
+	}
+	streamInterceptors := []grpc.StreamServerInterceptor{
+		StreamTraceInterceptor(),
+		metrics.StreamServerInterceptor(),
+	}
+	if cfg := config.APIDConfig.RateLimit; cfg.Enabled {
+		limiter := newLimiter(&cfg)
+		unaryInterceptors = append(unaryInterceptors, UnaryRateLimitInterceptor(limiter))
+		streamInterceptors = append(streamInterceptors, StreamRateLimitInterceptor(limiter))
+	}
+	unaryInterceptors = append(unaryInterceptors, UnaryServerInterceptor())
+
 	/* setting options to the gRPC server */
 	// grpcServer := grpc.NewServer(opts...)
 	grpcServer := grpc.NewServer(
-		append(opts, grpc.UnaryInterceptor(UnaryServerInterceptor()))...,
+		append(opts,
+			grpc.ChainUnaryInterceptor(unaryInterceptors...),
+			grpc.ChainStreamInterceptor(streamInterceptors...),
+		)...,
 	)
 
 	/* registering services */
 	// pb.RegisterACLServiceServer(grpcServer, &ACLServer{})
 	pb.RegisterPingServiceServer(grpcServer, &PingHandler{})
-	pb.RegisterACLServiceServer(grpcServer, &acl.ACLServer{})
+	aclServer := acl.NewACLServer(dir, aclBackend)
+	aclServer.Audit = auditLog
+	aclServer.Events = broker
+	aclServer.Watcher = watcher
+	aclServer.Drift = scanner
+	aclServer.Locks = locks
+	pb.RegisterACLServiceServer(grpcServer, aclServer)
+
+	/* standard health service for load balancers and service managers */
+	healthChecker := newHealth(aclBackend, serverCert, &config.APIDConfig.Server.Health)
+	healthpb.RegisterHealthServer(grpcServer, healthChecker.server)
 
 	/* enable reflection if daemon is in debug mode */
 	if config.APIDConfig.DConfig.DebugMode {
 		reflection.Register(grpcServer)
 	}
 
-	return &Server{GRPC: grpcServer, Config: &config.APIDConfig.Server}, nil
+	return &Server{
+		GRPC:      grpcServer,
+		Config:    &config.APIDConfig.Server,
+		Backend:   aclBackend,
+		Directory: dir,
+		Health:    healthChecker,
+		Audit:     auditLog,
+		Events:    broker,
+		Watcher:   watcher,
+		Drift:     scanner,
+	}, nil
+}
+
+/* opens the audit log, chaining records with the configured key */
+func openAudit(cfg *config.Audit) (*audit.Log, error) {
+	key, err := aclcore.LoadKey(cfg.KeyFile, cfg.CredentialName)
+	if err != nil {
+		return nil, err
+	}
+
+	return audit.Open(cfg.File, audit.Options{
+		Key:      key,
+		Observer: metrics.ObserveAudit,
+	})
+}
+
+/* creates the rate limiter with the budgets of the config */
+func newLimiter(cfg *config.RateLimit) *ratelimit.Limiter {
+	methods := make(map[string]ratelimit.Budget, len(cfg.Methods))
+	for method, budget := range cfg.Methods {
+		methods[method] = ratelimit.Budget{Rate: budget.Rate, Burst: budget.Burst}
+	}
+
+	zap.L().Info("Rate limiting requests per caller",
+		zap.Float64("default_rate", cfg.Default.Rate),
+		zap.Int("default_burst", cfg.Default.Burst),
+		zap.Int("method_budgets", len(methods)),
+		zap.Float64("caller_rate", cfg.Caller.Rate),
+		zap.Int("caller_burst", cfg.Caller.Burst),
+	)
+	return ratelimit.New(
+		ratelimit.Budget{Rate: cfg.Default.Rate, Burst: cfg.Default.Burst},
+		methods,
+		ratelimit.Budget{Rate: cfg.Caller.Rate, Burst: cfg.Caller.Burst},
+	)
+}
+
+/* creates the ACL backend selected in the daemon config */
+func newBackend() (backend.Backend, error) {
+	if config.APIDConfig.DConfig.Backend == config.BackendXattr {
+		zap.L().Warn("Editing ACL xattrs directly, aclcore is not used")
+		return backend.NewXattr(), nil
+	}
+
+	/* optional HMAC signing of every message sent to aclcore */
+	var signer *aclcore.Signer
+	if auth := config.APIDConfig.DConfig.Auth; auth.Enabled {
+		key, err := aclcore.LoadKey(auth.KeyFile, auth.CredentialName)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load aclcore signing key: %w", err)
+		}
+
+		signer, err = aclcore.NewSigner(key)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid aclcore signing key: %w", err)
+		}
+		zap.L().Info("Signing messages to aclcore")
+	}
+
+	/* long-lived aclcore client shared by all handlers */
+	core := aclcore.NewClient(aclcore.Options{
+		SocketPath:  config.APIDConfig.DConfig.SocketPath,
+		PoolSize:    config.APIDConfig.DConfig.PoolSize,
+		IdleTimeout: config.APIDConfig.DConfig.PoolIdleTimeout,
+		Timeouts:    coreTimeouts(&config.APIDConfig.DConfig.Timeouts),
+		Retry: aclcore.RetryPolicy{
+			MaxAttempts:    config.APIDConfig.DConfig.Retry.MaxAttempts,
+			InitialBackoff: config.APIDConfig.DConfig.Retry.InitialBackoff,
+			MaxBackoff:     config.APIDConfig.DConfig.Retry.MaxBackoff,
+		},
+		BreakerThreshold:     config.APIDConfig.DConfig.CircuitBreaker.FailureThreshold,
+		BreakerProbeInterval: config.APIDConfig.DConfig.CircuitBreaker.ProbeInterval,
+		Peer: aclcore.PeerPolicy{
+			Disabled:        config.APIDConfig.DConfig.Peer.Disabled,
+			UID:             config.APIDConfig.DConfig.Peer.UID,
+			GID:             config.APIDConfig.DConfig.Peer.GID,
+			CheckSocketFile: config.APIDConfig.DConfig.Peer.CheckSocketFile,
+			SocketOwnerUID:  *config.APIDConfig.DConfig.Peer.SocketOwnerUID,
+			SocketMaxMode:   config.APIDConfig.DConfig.Peer.MaxMode(),
+		},
+		Signer:   signer,
+		Observer: metrics.ObserveCore,
+	})
+	if err := metrics.RegisterCoreClient(core); err != nil {
+		core.Close()
+		return nil, err
+	}
+
+	if config.APIDConfig.DConfig.NoDirectReads {
+		return backend.NewCore(core), nil
+	}
+
+	/* reads rarely need root, keep them away from aclcore when possible */
+	return backend.WithDirectReads(backend.NewCore(core)), nil
 }
 
 /* start the gRPC server */
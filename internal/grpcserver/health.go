@@ -0,0 +1,180 @@
+package grpcserver
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/config"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/backend"
+	pb "github.com/PythonHacker24/linux-acl-management-aclapi/internal/grpcserver/protos"
+)
+
+/*
+keeps the grpc.health.v1 statuses up to date
+
+	""           overall status, same as the ACL service
+	ACLService   NOT_SERVING while aclcore is unreachable
+	PingService  only depends on the certificate and drain state
+
+everything turns NOT_SERVING when the server certificate is not valid
+(clients cannot complete a handshake) and, for good, once draining starts
+*/
+type Health struct {
+	server *health.Server
+
+	/* nil when the backend does not depend on a daemon */
+	prober backend.Prober
+
+	/* nil when TLS is disabled */
+	cert *x509.Certificate
+
+	interval time.Duration
+	timeout  time.Duration
+
+	/* the loop runs at most once, never after draining started */
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+
+	/* last reported problem, only transitions are logged */
+	lastProblem string
+}
+
+/* creates the health checker, probing the backend if it can be probed */
+func newHealth(aclBackend backend.Backend, cert *x509.Certificate, cfg *config.Health) *Health {
+	h := &Health{
+		server:   health.NewServer(),
+		cert:     cert,
+		interval: cfg.ProbeInterval,
+		timeout:  cfg.ProbeTimeout,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	if prober, ok := aclBackend.(backend.Prober); ok {
+		h.prober = prober
+	}
+
+	/* nothing is known before the first check */
+	h.setAll(healthpb.HealthCheckResponse_NOT_SERVING)
+	return h
+}
+
+/* starts the periodic checks */
+func (h *Health) Start() {
+	h.startOnce.Do(func() {
+		go h.loop()
+	})
+}
+
+/*
+marks every service NOT_SERVING for the rest of the process lifetime
+called when shutdown begins so load balancers stop sending new requests
+*/
+func (h *Health) Drain() {
+	h.stopOnce.Do(func() {
+		close(h.stop)
+
+		/* without a running loop there is nothing to wait for */
+		h.startOnce.Do(func() {
+			close(h.done)
+		})
+		<-h.done
+		h.server.Shutdown()
+		zap.L().Info("Health status set to NOT_SERVING for shutdown")
+	})
+}
+
+func (h *Health) loop() {
+	defer close(h.done)
+
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		h.check()
+
+		select {
+		case <-ticker.C:
+		case <-h.stop:
+			return
+		}
+	}
+}
+
+/* runs all checks once and publishes the resulting statuses */
+func (h *Health) check() {
+	certErr := h.checkCert(time.Now())
+	coreErr := h.probe()
+
+	ping, acl := healthpb.HealthCheckResponse_SERVING, healthpb.HealthCheckResponse_SERVING
+	problem := ""
+
+	switch {
+	case certErr != nil:
+		ping, acl = healthpb.HealthCheckResponse_NOT_SERVING, healthpb.HealthCheckResponse_NOT_SERVING
+		problem = certErr.Error()
+	case coreErr != nil:
+		acl = healthpb.HealthCheckResponse_NOT_SERVING
+		problem = coreErr.Error()
+	}
+
+	h.server.SetServingStatus("", acl)
+	h.server.SetServingStatus(pb.ACLService_ServiceDesc.ServiceName, acl)
+	h.server.SetServingStatus(pb.PingService_ServiceDesc.ServiceName, ping)
+
+	if problem != h.lastProblem {
+		if problem == "" {
+			zap.L().Info("Health status changed to SERVING")
+		} else {
+			zap.L().Warn("Health status changed to NOT_SERVING",
+				zap.String("reason", problem),
+			)
+		}
+		h.lastProblem = problem
+	}
+}
+
+/* probes the backend daemon */
+func (h *Health) probe() error {
+	if h.prober == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	if err := h.prober.Probe(ctx); err != nil {
+		return fmt.Errorf("aclcore probe failed: %w", err)
+	}
+	return nil
+}
+
+/* checks the validity period of the server certificate */
+func (h *Health) checkCert(now time.Time) error {
+	if h.cert == nil {
+		return nil
+	}
+
+	if now.Before(h.cert.NotBefore) {
+		return fmt.Errorf("server certificate is not valid before %s", h.cert.NotBefore.Format(time.RFC3339))
+	}
+	if now.After(h.cert.NotAfter) {
+		return fmt.Errorf("server certificate expired at %s", h.cert.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (h *Health) setAll(status healthpb.HealthCheckResponse_ServingStatus) {
+	h.server.SetServingStatus("", status)
+	h.server.SetServingStatus(pb.ACLService_ServiceDesc.ServiceName, status)
+	h.server.SetServingStatus(pb.PingService_ServiceDesc.ServiceName, status)
+}
@@ -0,0 +1,104 @@
+package grpcserver
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/config"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclcore"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/backend"
+	pb "github.com/PythonHacker24/linux-acl-management-aclapi/internal/grpcserver/protos"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/testutil/fakecore"
+)
+
+var healthConfig = config.Health{ProbeInterval: time.Hour, ProbeTimeout: time.Second}
+
+func servingStatus(t *testing.T, h *Health, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+
+	resp, err := h.server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatalf("Check(%q): %v", service, err)
+	}
+	return resp.Status
+}
+
+func TestHealthFollowsAclcore(t *testing.T) {
+	core := fakecore.New(t)
+	h := newHealth(backend.NewCore(core.Client(t, aclcore.Options{})), nil, &healthConfig)
+
+	h.check()
+	for _, service := range []string{"", pb.ACLService_ServiceDesc.ServiceName, pb.PingService_ServiceDesc.ServiceName} {
+		if got := servingStatus(t, h, service); got != healthpb.HealthCheckResponse_SERVING {
+			t.Fatalf("%q = %s with aclcore up, want SERVING", service, got)
+		}
+	}
+
+	core.Close()
+	h.check()
+	if got := servingStatus(t, h, pb.ACLService_ServiceDesc.ServiceName); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("ACLService = %s with aclcore down, want NOT_SERVING", got)
+	}
+	if got := servingStatus(t, h, pb.PingService_ServiceDesc.ServiceName); got != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("PingService = %s with aclcore down, want SERVING", got)
+	}
+}
+
+func TestHealthCertificate(t *testing.T) {
+	cert := &x509.Certificate{
+		NotBefore: time.Now().Add(-2 * time.Hour),
+		NotAfter:  time.Now().Add(-time.Hour),
+	}
+	h := newHealth(backend.NewXattr(), cert, &healthConfig)
+
+	h.check()
+	if got := servingStatus(t, h, ""); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("status = %s with an expired certificate, want NOT_SERVING", got)
+	}
+}
+
+func TestHealthDrain(t *testing.T) {
+	h := newHealth(backend.NewXattr(), nil, &healthConfig)
+	h.Start()
+
+	h.Drain()
+	if got := servingStatus(t, h, ""); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("status = %s after drain, want NOT_SERVING", got)
+	}
+
+	/* later checks must not bring the server back */
+	h.check()
+	if got := servingStatus(t, h, ""); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("status = %s after a check following drain, want NOT_SERVING", got)
+	}
+}
+
+func TestHealthDrainWithoutStart(t *testing.T) {
+	h := newHealth(backend.NewXattr(), nil, &healthConfig)
+
+	drained := make(chan struct{})
+	go func() {
+		h.Drain()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		t.Fatal("Drain without Start did not return")
+	}
+	if got := servingStatus(t, h, ""); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("status = %s after drain, want NOT_SERVING", got)
+	}
+
+	/* starting after the drain does not bring the checks back */
+	h.Start()
+	h.Drain()
+	if got := servingStatus(t, h, ""); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("status = %s after a start following drain, want NOT_SERVING", got)
+	}
+}
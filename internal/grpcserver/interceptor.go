@@ -5,9 +5,77 @@ import (
 
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/tracing"
 )
 
+/*
+picks up (or starts) the trace of every unary request, makes it available to
+the handler through the context and echoes it in the response headers
+*/
+func UnaryTraceInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		trace := incomingTrace(ctx)
+		grpc.SetHeader(ctx, traceHeader(trace))
+		return handler(tracing.NewContext(ctx, trace), req)
+	}
+}
+
+/* same as UnaryTraceInterceptor for streaming requests */
+func StreamTraceInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		trace := incomingTrace(stream.Context())
+		stream.SetHeader(traceHeader(trace))
+		return handler(srv, &tracedStream{
+			ServerStream: stream,
+			ctx:          tracing.NewContext(stream.Context(), trace),
+		})
+	}
+}
+
+/* server stream whose context carries the trace */
+type tracedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tracedStream) Context() context.Context {
+	return s.ctx
+}
+
+/* builds the trace from the traceparent and request ID sent by the caller */
+func incomingTrace(ctx context.Context) *tracing.Trace {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return tracing.New(first(md.Get(tracing.TraceparentKey)), first(md.Get(tracing.RequestIDKey)))
+}
+
+/* response headers echoing the trace to the caller */
+func traceHeader(trace *tracing.Trace) metadata.MD {
+	return metadata.Pairs(
+		tracing.TraceparentKey, trace.Traceparent(),
+		tracing.RequestIDKey, trace.RequestID,
+	)
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
 func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
 	return func(
 		ctx context.Context,
@@ -15,7 +83,7 @@ func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (resp interface{}, err error) {
-		logger := zap.L()
+		logger := tracing.Logger(ctx)
 
 		/* log incoming request */
 		logger.Info("Incoming gRPC request",
@@ -9,6 +9,7 @@ package protos
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -21,6 +22,274 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// kind of principal an entry applies to
+type EntityType int32
+
+const (
+	EntityType_ENTITY_TYPE_UNSPECIFIED EntityType = 0
+	EntityType_ENTITY_TYPE_USER        EntityType = 1
+	EntityType_ENTITY_TYPE_GROUP       EntityType = 2
+	EntityType_ENTITY_TYPE_MASK        EntityType = 3
+	EntityType_ENTITY_TYPE_OTHER       EntityType = 4
+)
+
+// Enum value maps for EntityType.
+var (
+	EntityType_name = map[int32]string{
+		0: "ENTITY_TYPE_UNSPECIFIED",
+		1: "ENTITY_TYPE_USER",
+		2: "ENTITY_TYPE_GROUP",
+		3: "ENTITY_TYPE_MASK",
+		4: "ENTITY_TYPE_OTHER",
+	}
+	EntityType_value = map[string]int32{
+		"ENTITY_TYPE_UNSPECIFIED": 0,
+		"ENTITY_TYPE_USER":        1,
+		"ENTITY_TYPE_GROUP":       2,
+		"ENTITY_TYPE_MASK":        3,
+		"ENTITY_TYPE_OTHER":       4,
+	}
+)
+
+func (x EntityType) Enum() *EntityType {
+	p := new(EntityType)
+	*p = x
+	return p
+}
+
+func (x EntityType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (EntityType) Descriptor() protoreflect.EnumDescriptor {
+	return file_internal_grpcserver_protos_acl_proto_enumTypes[0].Descriptor()
+}
+
+func (EntityType) Type() protoreflect.EnumType {
+	return &file_internal_grpcserver_protos_acl_proto_enumTypes[0]
+}
+
+func (x EntityType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use EntityType.Descriptor instead.
+func (EntityType) EnumDescriptor() ([]byte, []int) {
+	return file_internal_grpcserver_protos_acl_proto_rawDescGZIP(), []int{0}
+}
+
+// operation performed with an entry
+type Action int32
+
+const (
+	Action_ACTION_UNSPECIFIED Action = 0
+	Action_ACTION_ADD         Action = 1
+	Action_ACTION_MODIFY      Action = 2
+	Action_ACTION_REMOVE      Action = 3
+)
+
+// Enum value maps for Action.
+var (
+	Action_name = map[int32]string{
+		0: "ACTION_UNSPECIFIED",
+		1: "ACTION_ADD",
+		2: "ACTION_MODIFY",
+		3: "ACTION_REMOVE",
+	}
+	Action_value = map[string]int32{
+		"ACTION_UNSPECIFIED": 0,
+		"ACTION_ADD":         1,
+		"ACTION_MODIFY":      2,
+		"ACTION_REMOVE":      3,
+	}
+)
+
+func (x Action) Enum() *Action {
+	p := new(Action)
+	*p = x
+	return p
+}
+
+func (x Action) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Action) Descriptor() protoreflect.EnumDescriptor {
+	return file_internal_grpcserver_protos_acl_proto_enumTypes[1].Descriptor()
+}
+
+func (Action) Type() protoreflect.EnumType {
+	return &file_internal_grpcserver_protos_acl_proto_enumTypes[1]
+}
+
+func (x Action) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Action.Descriptor instead.
+func (Action) EnumDescriptor() ([]byte, []int) {
+	return file_internal_grpcserver_protos_acl_proto_rawDescGZIP(), []int{1}
+}
+
+// permission bits, combined into ACLEntryV2.permissions
+type Permission int32
+
+const (
+	Permission_PERMISSION_NONE                Permission = 0
+	Permission_PERMISSION_EXECUTE             Permission = 1
+	Permission_PERMISSION_WRITE               Permission = 2
+	Permission_PERMISSION_READ                Permission = 4
+	Permission_PERMISSION_CONDITIONAL_EXECUTE Permission = 8 // "X": execute on directories or already executable files
+)
+
+// Enum value maps for Permission.
+var (
+	Permission_name = map[int32]string{
+		0: "PERMISSION_NONE",
+		1: "PERMISSION_EXECUTE",
+		2: "PERMISSION_WRITE",
+		4: "PERMISSION_READ",
+		8: "PERMISSION_CONDITIONAL_EXECUTE",
+	}
+	Permission_value = map[string]int32{
+		"PERMISSION_NONE":                0,
+		"PERMISSION_EXECUTE":             1,
+		"PERMISSION_WRITE":               2,
+		"PERMISSION_READ":                4,
+		"PERMISSION_CONDITIONAL_EXECUTE": 8,
+	}
+)
+
+func (x Permission) Enum() *Permission {
+	p := new(Permission)
+	*p = x
+	return p
+}
+
+func (x Permission) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Permission) Descriptor() protoreflect.EnumDescriptor {
+	return file_internal_grpcserver_protos_acl_proto_enumTypes[2].Descriptor()
+}
+
+func (Permission) Type() protoreflect.EnumType {
+	return &file_internal_grpcserver_protos_acl_proto_enumTypes[2]
+}
+
+func (x Permission) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Permission.Descriptor instead.
+func (Permission) EnumDescriptor() ([]byte, []int) {
+	return file_internal_grpcserver_protos_acl_proto_rawDescGZIP(), []int{2}
+}
+
+// how a change came to aclapi's attention
+type ChangeKind int32
+
+const (
+	ChangeKind_CHANGE_KIND_UNSPECIFIED ChangeKind = 0
+	ChangeKind_CHANGE_KIND_APPLIED     ChangeKind = 1 // applied by aclapi
+	ChangeKind_CHANGE_KIND_REVERTED    ChangeKind = 2 // drift undone by aclapi
+	ChangeKind_CHANGE_KIND_DETECTED    ChangeKind = 3 // made outside of aclapi
+)
+
+// Enum value maps for ChangeKind.
+var (
+	ChangeKind_name = map[int32]string{
+		0: "CHANGE_KIND_UNSPECIFIED",
+		1: "CHANGE_KIND_APPLIED",
+		2: "CHANGE_KIND_REVERTED",
+		3: "CHANGE_KIND_DETECTED",
+	}
+	ChangeKind_value = map[string]int32{
+		"CHANGE_KIND_UNSPECIFIED": 0,
+		"CHANGE_KIND_APPLIED":     1,
+		"CHANGE_KIND_REVERTED":    2,
+		"CHANGE_KIND_DETECTED":    3,
+	}
+)
+
+func (x ChangeKind) Enum() *ChangeKind {
+	p := new(ChangeKind)
+	*p = x
+	return p
+}
+
+func (x ChangeKind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ChangeKind) Descriptor() protoreflect.EnumDescriptor {
+	return file_internal_grpcserver_protos_acl_proto_enumTypes[3].Descriptor()
+}
+
+func (ChangeKind) Type() protoreflect.EnumType {
+	return &file_internal_grpcserver_protos_acl_proto_enumTypes[3]
+}
+
+func (x ChangeKind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ChangeKind.Descriptor instead.
+func (ChangeKind) EnumDescriptor() ([]byte, []int) {
+	return file_internal_grpcserver_protos_acl_proto_rawDescGZIP(), []int{3}
+}
+
+// what happens to paths whose ACL drifted
+type DriftPolicy int32
+
+const (
+	DriftPolicy_DRIFT_POLICY_UNSPECIFIED DriftPolicy = 0
+	DriftPolicy_DRIFT_POLICY_REPORT      DriftPolicy = 1 // listed in the report only
+	DriftPolicy_DRIFT_POLICY_REMEDIATE   DriftPolicy = 2 // the recorded ACL is set again
+)
+
+// Enum value maps for DriftPolicy.
+var (
+	DriftPolicy_name = map[int32]string{
+		0: "DRIFT_POLICY_UNSPECIFIED",
+		1: "DRIFT_POLICY_REPORT",
+		2: "DRIFT_POLICY_REMEDIATE",
+	}
+	DriftPolicy_value = map[string]int32{
+		"DRIFT_POLICY_UNSPECIFIED": 0,
+		"DRIFT_POLICY_REPORT":      1,
+		"DRIFT_POLICY_REMEDIATE":   2,
+	}
+)
+
+func (x DriftPolicy) Enum() *DriftPolicy {
+	p := new(DriftPolicy)
+	*p = x
+	return p
+}
+
+func (x DriftPolicy) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (DriftPolicy) Descriptor() protoreflect.EnumDescriptor {
+	return file_internal_grpcserver_protos_acl_proto_enumTypes[4].Descriptor()
+}
+
+func (DriftPolicy) Type() protoreflect.EnumType {
+	return &file_internal_grpcserver_protos_acl_proto_enumTypes[4]
+}
+
+func (x DriftPolicy) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use DriftPolicy.Descriptor instead.
+func (DriftPolicy) EnumDescriptor() ([]byte, []int) {
+	return file_internal_grpcserver_protos_acl_proto_rawDescGZIP(), []int{4}
+}
+
 type ACLEntry struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	EntityType    string                 `protobuf:"bytes,1,opt,name=entity_type,json=entityType,proto3" json:"entity_type,omitempty"` // "user", "group", "mask", "other"
@@ -97,18 +366,97 @@ func (x *ACLEntry) GetIsDefault() bool {
 	return false
 }
 
+// typed replacement for ACLEntry
+type ACLEntryV2 struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EntityType    EntityType             `protobuf:"varint,1,opt,name=entity_type,json=entityType,proto3,enum=acl.EntityType" json:"entity_type,omitempty"`
+	Entity        string                 `protobuf:"bytes,2,opt,name=entity,proto3" json:"entity,omitempty"`            // user/group name or numeric id, empty for the owning user/group, mask and other
+	Permissions   uint32                 `protobuf:"varint,3,opt,name=permissions,proto3" json:"permissions,omitempty"` // bitmask of Permission values
+	Action        Action                 `protobuf:"varint,4,opt,name=action,proto3,enum=acl.Action" json:"action,omitempty"`
+	IsDefault     bool                   `protobuf:"varint,5,opt,name=is_default,json=isDefault,proto3" json:"is_default,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ACLEntryV2) Reset() {
+	*x = ACLEntryV2{}
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ACLEntryV2) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ACLEntryV2) ProtoMessage() {}
+
+func (x *ACLEntryV2) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ACLEntryV2.ProtoReflect.Descriptor instead.
+func (*ACLEntryV2) Descriptor() ([]byte, []int) {
+	return file_internal_grpcserver_protos_acl_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ACLEntryV2) GetEntityType() EntityType {
+	if x != nil {
+		return x.EntityType
+	}
+	return EntityType_ENTITY_TYPE_UNSPECIFIED
+}
+
+func (x *ACLEntryV2) GetEntity() string {
+	if x != nil {
+		return x.Entity
+	}
+	return ""
+}
+
+func (x *ACLEntryV2) GetPermissions() uint32 {
+	if x != nil {
+		return x.Permissions
+	}
+	return 0
+}
+
+func (x *ACLEntryV2) GetAction() Action {
+	if x != nil {
+		return x.Action
+	}
+	return Action_ACTION_UNSPECIFIED
+}
+
+func (x *ACLEntryV2) GetIsDefault() bool {
+	if x != nil {
+		return x.IsDefault
+	}
+	return false
+}
+
 type ApplyACLRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	TransactionID string                 `protobuf:"bytes,1,opt,name=transactionID,proto3" json:"transactionID,omitempty"`
 	TargetPath    string                 `protobuf:"bytes,2,opt,name=target_path,json=targetPath,proto3" json:"target_path,omitempty"`
-	Entry         *ACLEntry              `protobuf:"bytes,3,opt,name=entry,proto3" json:"entry,omitempty"`
+	Entry         *ACLEntry              `protobuf:"bytes,3,opt,name=entry,proto3" json:"entry,omitempty"` // legacy string entry, mutually exclusive with entry_v2
+	EntryV2       *ACLEntryV2            `protobuf:"bytes,4,opt,name=entry_v2,json=entryV2,proto3" json:"entry_v2,omitempty"`
+	Recursive     bool                   `protobuf:"varint,5,opt,name=recursive,proto3" json:"recursive,omitempty"` // apply to target_path and everything below it (symlinks are not followed)
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ApplyACLRequest) Reset() {
 	*x = ApplyACLRequest{}
-	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[1]
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -120,7 +468,7 @@ func (x *ApplyACLRequest) String() string {
 func (*ApplyACLRequest) ProtoMessage() {}
 
 func (x *ApplyACLRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[1]
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -133,7 +481,7 @@ func (x *ApplyACLRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ApplyACLRequest.ProtoReflect.Descriptor instead.
 func (*ApplyACLRequest) Descriptor() ([]byte, []int) {
-	return file_internal_grpcserver_protos_acl_proto_rawDescGZIP(), []int{1}
+	return file_internal_grpcserver_protos_acl_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *ApplyACLRequest) GetTransactionID() string {
@@ -157,6 +505,22 @@ func (x *ApplyACLRequest) GetEntry() *ACLEntry {
 	return nil
 }
 
+func (x *ApplyACLRequest) GetEntryV2() *ACLEntryV2 {
+	if x != nil {
+		return x.EntryV2
+	}
+	return nil
+}
+
+func (x *ApplyACLRequest) GetRecursive() bool {
+	if x != nil {
+		return x.Recursive
+	}
+	return false
+}
+
+// failures are returned as gRPC status errors carrying a google.rpc.ErrorInfo
+// (domain "aclapi.laclm", stable reason code, errno metadata when reported by aclcore)
 type ApplyACLResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
@@ -167,7 +531,7 @@ type ApplyACLResponse struct {
 
 func (x *ApplyACLResponse) Reset() {
 	*x = ApplyACLResponse{}
-	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[2]
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -179,7 +543,7 @@ func (x *ApplyACLResponse) String() string {
 func (*ApplyACLResponse) ProtoMessage() {}
 
 func (x *ApplyACLResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[2]
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -192,7 +556,7 @@ func (x *ApplyACLResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ApplyACLResponse.ProtoReflect.Descriptor instead.
 func (*ApplyACLResponse) Descriptor() ([]byte, []int) {
-	return file_internal_grpcserver_protos_acl_proto_rawDescGZIP(), []int{2}
+	return file_internal_grpcserver_protos_acl_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *ApplyACLResponse) GetSuccess() bool {
@@ -209,30 +573,1188 @@ func (x *ApplyACLResponse) GetMessage() string {
 	return ""
 }
 
-var File_internal_grpcserver_protos_acl_proto protoreflect.FileDescriptor
+type ExpandGroupRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Group         string                 `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"` // group name or numeric gid
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
 
-const file_internal_grpcserver_protos_acl_proto_rawDesc = "" +
-	"\n" +
-	"$internal/grpcserver/protos/acl.proto\x12\x03acl\"\x9c\x01\n" +
-	"\bACLEntry\x12\x1f\n" +
-	"\ventity_type\x18\x01 \x01(\tR\n" +
-	"entityType\x12\x16\n" +
-	"\x06entity\x18\x02 \x01(\tR\x06entity\x12 \n" +
-	"\vpermissions\x18\x03 \x01(\tR\vpermissions\x12\x16\n" +
-	"\x06action\x18\x04 \x01(\tR\x06action\x12\x1d\n" +
-	"\n" +
-	"is_default\x18\x05 \x01(\bR\tisDefault\"}\n" +
-	"\x0fApplyACLRequest\x12$\n" +
-	"\rtransactionID\x18\x01 \x01(\tR\rtransactionID\x12\x1f\n" +
-	"\vtarget_path\x18\x02 \x01(\tR\n" +
-	"targetPath\x12#\n" +
-	"\x05entry\x18\x03 \x01(\v2\r.acl.ACLEntryR\x05entry\"F\n" +
-	"\x10ApplyACLResponse\x12\x18\n" +
-	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage2J\n" +
-	"\n" +
-	"ACLService\x12<\n" +
-	"\rApplyACLEntry\x12\x14.acl.ApplyACLRequest\x1a\x15.acl.ApplyACLResponseBYZWgithub.com/PythonHacker24/linux-acl-management-aclapi/internal/grpcserver/protos;protosb\x06proto3"
+func (x *ExpandGroupRequest) Reset() {
+	*x = ExpandGroupRequest{}
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExpandGroupRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExpandGroupRequest) ProtoMessage() {}
+
+func (x *ExpandGroupRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExpandGroupRequest.ProtoReflect.Descriptor instead.
+func (*ExpandGroupRequest) Descriptor() ([]byte, []int) {
+	return file_internal_grpcserver_protos_acl_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ExpandGroupRequest) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+type ExpandGroupResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Group         string                 `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	Gid           uint32                 `protobuf:"varint,2,opt,name=gid,proto3" json:"gid,omitempty"`
+	Members       []string               `protobuf:"bytes,3,rep,name=members,proto3" json:"members,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExpandGroupResponse) Reset() {
+	*x = ExpandGroupResponse{}
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExpandGroupResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExpandGroupResponse) ProtoMessage() {}
+
+func (x *ExpandGroupResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExpandGroupResponse.ProtoReflect.Descriptor instead.
+func (*ExpandGroupResponse) Descriptor() ([]byte, []int) {
+	return file_internal_grpcserver_protos_acl_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ExpandGroupResponse) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+func (x *ExpandGroupResponse) GetGid() uint32 {
+	if x != nil {
+		return x.Gid
+	}
+	return 0
+}
+
+func (x *ExpandGroupResponse) GetMembers() []string {
+	if x != nil {
+		return x.Members
+	}
+	return nil
+}
+
+type GetACLRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetACLRequest) Reset() {
+	*x = GetACLRequest{}
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetACLRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetACLRequest) ProtoMessage() {}
+
+func (x *GetACLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetACLRequest.ProtoReflect.Descriptor instead.
+func (*GetACLRequest) Descriptor() ([]byte, []int) {
+	return file_internal_grpcserver_protos_acl_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetACLRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+// entry as stored on disk, the owner and owning group are user/group entries with an empty entity
+type ACLEntryInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EntityType    EntityType             `protobuf:"varint,1,opt,name=entity_type,json=entityType,proto3,enum=acl.EntityType" json:"entity_type,omitempty"`
+	Entity        string                 `protobuf:"bytes,2,opt,name=entity,proto3" json:"entity,omitempty"`            // user/group name (numeric id if unknown to the directory)
+	Id            *uint32                `protobuf:"varint,3,opt,name=id,proto3,oneof" json:"id,omitempty"`             // uid/gid of named user and group entries
+	Permissions   uint32                 `protobuf:"varint,4,opt,name=permissions,proto3" json:"permissions,omitempty"` // bitmask of Permission values
+	IsDefault     bool                   `protobuf:"varint,5,opt,name=is_default,json=isDefault,proto3" json:"is_default,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ACLEntryInfo) Reset() {
+	*x = ACLEntryInfo{}
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ACLEntryInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ACLEntryInfo) ProtoMessage() {}
+
+func (x *ACLEntryInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ACLEntryInfo.ProtoReflect.Descriptor instead.
+func (*ACLEntryInfo) Descriptor() ([]byte, []int) {
+	return file_internal_grpcserver_protos_acl_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ACLEntryInfo) GetEntityType() EntityType {
+	if x != nil {
+		return x.EntityType
+	}
+	return EntityType_ENTITY_TYPE_UNSPECIFIED
+}
+
+func (x *ACLEntryInfo) GetEntity() string {
+	if x != nil {
+		return x.Entity
+	}
+	return ""
+}
+
+func (x *ACLEntryInfo) GetId() uint32 {
+	if x != nil && x.Id != nil {
+		return *x.Id
+	}
+	return 0
+}
+
+func (x *ACLEntryInfo) GetPermissions() uint32 {
+	if x != nil {
+		return x.Permissions
+	}
+	return 0
+}
+
+func (x *ACLEntryInfo) GetIsDefault() bool {
+	if x != nil {
+		return x.IsDefault
+	}
+	return false
+}
+
+type GetACLResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Path           string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Access         []*ACLEntryInfo        `protobuf:"bytes,2,rep,name=access,proto3" json:"access,omitempty"`
+	DefaultEntries []*ACLEntryInfo        `protobuf:"bytes,3,rep,name=default_entries,json=defaultEntries,proto3" json:"default_entries,omitempty"` // empty for files and directories without a default ACL
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *GetACLResponse) Reset() {
+	*x = GetACLResponse{}
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetACLResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetACLResponse) ProtoMessage() {}
+
+func (x *GetACLResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetACLResponse.ProtoReflect.Descriptor instead.
+func (*GetACLResponse) Descriptor() ([]byte, []int) {
+	return file_internal_grpcserver_protos_acl_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetACLResponse) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *GetACLResponse) GetAccess() []*ACLEntryInfo {
+	if x != nil {
+		return x.Access
+	}
+	return nil
+}
+
+func (x *GetACLResponse) GetDefaultEntries() []*ACLEntryInfo {
+	if x != nil {
+		return x.DefaultEntries
+	}
+	return nil
+}
+
+// filters of the audit history, combined with AND, unset filters match every record
+type QueryAuditRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PathPrefix    string                 `protobuf:"bytes,1,opt,name=path_prefix,json=pathPrefix,proto3" json:"path_prefix,omitempty"` // changes to this path or below it, and recursive changes above it
+	Entity        string                 `protobuf:"bytes,2,opt,name=entity,proto3" json:"entity,omitempty"`                           // user/group name or numeric id of the changed entry
+	Caller        string                 `protobuf:"bytes,3,opt,name=caller,proto3" json:"caller,omitempty"`                           // common name of the client certificate
+	EndUser       string                 `protobuf:"bytes,4,opt,name=end_user,json=endUser,proto3" json:"end_user,omitempty"`          // end user the change was made for
+	TransactionId string                 `protobuf:"bytes,5,opt,name=transaction_id,json=transactionId,proto3" json:"transaction_id,omitempty"`
+	Since         *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=since,proto3" json:"since,omitempty"`                          // inclusive
+	Until         *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=until,proto3" json:"until,omitempty"`                          // exclusive
+	PageSize      uint32                 `protobuf:"varint,8,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`   // records per call (default 100, at most 1000)
+	PageToken     string                 `protobuf:"bytes,9,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"` // next_page_token of the previous call with the same filters
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryAuditRequest) Reset() {
+	*x = QueryAuditRequest{}
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryAuditRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryAuditRequest) ProtoMessage() {}
+
+func (x *QueryAuditRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryAuditRequest.ProtoReflect.Descriptor instead.
+func (*QueryAuditRequest) Descriptor() ([]byte, []int) {
+	return file_internal_grpcserver_protos_acl_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *QueryAuditRequest) GetPathPrefix() string {
+	if x != nil {
+		return x.PathPrefix
+	}
+	return ""
+}
+
+func (x *QueryAuditRequest) GetEntity() string {
+	if x != nil {
+		return x.Entity
+	}
+	return ""
+}
+
+func (x *QueryAuditRequest) GetCaller() string {
+	if x != nil {
+		return x.Caller
+	}
+	return ""
+}
+
+func (x *QueryAuditRequest) GetEndUser() string {
+	if x != nil {
+		return x.EndUser
+	}
+	return ""
+}
+
+func (x *QueryAuditRequest) GetTransactionId() string {
+	if x != nil {
+		return x.TransactionId
+	}
+	return ""
+}
+
+func (x *QueryAuditRequest) GetSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Since
+	}
+	return nil
+}
+
+func (x *QueryAuditRequest) GetUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Until
+	}
+	return nil
+}
+
+func (x *QueryAuditRequest) GetPageSize() uint32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *QueryAuditRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+// ACL change recorded in the audit log
+type AuditRecord struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Seq           uint64                 `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"` // position in the audit log
+	Time          *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=time,proto3" json:"time,omitempty"`
+	Caller        string                 `protobuf:"bytes,3,opt,name=caller,proto3" json:"caller,omitempty"` // common name of the client certificate ("unauthenticated" without mTLS)
+	CertSubject   string                 `protobuf:"bytes,4,opt,name=cert_subject,json=certSubject,proto3" json:"cert_subject,omitempty"`
+	EndUser       string                 `protobuf:"bytes,5,opt,name=end_user,json=endUser,proto3" json:"end_user,omitempty"`
+	Peer          string                 `protobuf:"bytes,6,opt,name=peer,proto3" json:"peer,omitempty"`
+	TransactionId string                 `protobuf:"bytes,7,opt,name=transaction_id,json=transactionId,proto3" json:"transaction_id,omitempty"`
+	RequestId     string                 `protobuf:"bytes,8,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	Path          string                 `protobuf:"bytes,9,opt,name=path,proto3" json:"path,omitempty"`
+	Action        Action                 `protobuf:"varint,10,opt,name=action,proto3,enum=acl.Action" json:"action,omitempty"`
+	Entry         *ACLEntryInfo          `protobuf:"bytes,11,opt,name=entry,proto3" json:"entry,omitempty"`
+	Recursive     bool                   `protobuf:"varint,12,opt,name=recursive,proto3" json:"recursive,omitempty"`
+	Before        []*ACLEntryInfo        `protobuf:"bytes,13,rep,name=before,proto3" json:"before,omitempty"` // access and default entries before the change (empty if unknown)
+	After         []*ACLEntryInfo        `protobuf:"bytes,14,rep,name=after,proto3" json:"after,omitempty"`   // access and default entries after the change (empty if unknown)
+	Success       bool                   `protobuf:"varint,15,opt,name=success,proto3" json:"success,omitempty"`
+	Error         string                 `protobuf:"bytes,16,opt,name=error,proto3" json:"error,omitempty"`   // failure reported by the backend
+	Hash          string                 `protobuf:"bytes,17,opt,name=hash,proto3" json:"hash,omitempty"`     // hash chaining the record to the audit log
+	Source        string                 `protobuf:"bytes,18,opt,name=source,proto3" json:"source,omitempty"` // "external" for changes made outside of aclapi, "remediation" for reverted drift, empty for API requests
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AuditRecord) Reset() {
+	*x = AuditRecord{}
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AuditRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuditRecord) ProtoMessage() {}
+
+func (x *AuditRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuditRecord.ProtoReflect.Descriptor instead.
+func (*AuditRecord) Descriptor() ([]byte, []int) {
+	return file_internal_grpcserver_protos_acl_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *AuditRecord) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *AuditRecord) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *AuditRecord) GetCaller() string {
+	if x != nil {
+		return x.Caller
+	}
+	return ""
+}
+
+func (x *AuditRecord) GetCertSubject() string {
+	if x != nil {
+		return x.CertSubject
+	}
+	return ""
+}
+
+func (x *AuditRecord) GetEndUser() string {
+	if x != nil {
+		return x.EndUser
+	}
+	return ""
+}
+
+func (x *AuditRecord) GetPeer() string {
+	if x != nil {
+		return x.Peer
+	}
+	return ""
+}
+
+func (x *AuditRecord) GetTransactionId() string {
+	if x != nil {
+		return x.TransactionId
+	}
+	return ""
+}
+
+func (x *AuditRecord) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *AuditRecord) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *AuditRecord) GetAction() Action {
+	if x != nil {
+		return x.Action
+	}
+	return Action_ACTION_UNSPECIFIED
+}
+
+func (x *AuditRecord) GetEntry() *ACLEntryInfo {
+	if x != nil {
+		return x.Entry
+	}
+	return nil
+}
+
+func (x *AuditRecord) GetRecursive() bool {
+	if x != nil {
+		return x.Recursive
+	}
+	return false
+}
+
+func (x *AuditRecord) GetBefore() []*ACLEntryInfo {
+	if x != nil {
+		return x.Before
+	}
+	return nil
+}
+
+func (x *AuditRecord) GetAfter() []*ACLEntryInfo {
+	if x != nil {
+		return x.After
+	}
+	return nil
+}
+
+func (x *AuditRecord) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *AuditRecord) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *AuditRecord) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+func (x *AuditRecord) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+type QueryAuditResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Record        *AuditRecord           `protobuf:"bytes,1,opt,name=record,proto3" json:"record,omitempty"`
+	NextPageToken string                 `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"` // set on the last record of a page when more records match
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryAuditResponse) Reset() {
+	*x = QueryAuditResponse{}
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryAuditResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryAuditResponse) ProtoMessage() {}
+
+func (x *QueryAuditResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryAuditResponse.ProtoReflect.Descriptor instead.
+func (*QueryAuditResponse) Descriptor() ([]byte, []int) {
+	return file_internal_grpcserver_protos_acl_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *QueryAuditResponse) GetRecord() *AuditRecord {
+	if x != nil {
+		return x.Record
+	}
+	return nil
+}
+
+func (x *QueryAuditResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type WatchACLChangesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PathPrefix    string                 `protobuf:"bytes,1,opt,name=path_prefix,json=pathPrefix,proto3" json:"path_prefix,omitempty"`    // changes to this path or below it, and recursive changes above it (all paths if empty)
+	ResumeToken   string                 `protobuf:"bytes,2,opt,name=resume_token,json=resumeToken,proto3" json:"resume_token,omitempty"` // resume_token of the last event received, to get the events missed since
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchACLChangesRequest) Reset() {
+	*x = WatchACLChangesRequest{}
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchACLChangesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchACLChangesRequest) ProtoMessage() {}
+
+func (x *WatchACLChangesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchACLChangesRequest.ProtoReflect.Descriptor instead.
+func (*WatchACLChangesRequest) Descriptor() ([]byte, []int) {
+	return file_internal_grpcserver_protos_acl_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *WatchACLChangesRequest) GetPathPrefix() string {
+	if x != nil {
+		return x.PathPrefix
+	}
+	return ""
+}
+
+func (x *WatchACLChangesRequest) GetResumeToken() string {
+	if x != nil {
+		return x.ResumeToken
+	}
+	return ""
+}
+
+// the stream fails with OUT_OF_RANGE (reason RESUME_TOKEN_EXPIRED) when events were missed,
+// clients then reload the ACLs they display and watch again without a token
+type ACLChangeEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ResumeToken   string                 `protobuf:"bytes,1,opt,name=resume_token,json=resumeToken,proto3" json:"resume_token,omitempty"`
+	Time          *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=time,proto3" json:"time,omitempty"`
+	Kind          ChangeKind             `protobuf:"varint,3,opt,name=kind,proto3,enum=acl.ChangeKind" json:"kind,omitempty"`
+	Path          string                 `protobuf:"bytes,4,opt,name=path,proto3" json:"path,omitempty"`
+	Recursive     bool                   `protobuf:"varint,5,opt,name=recursive,proto3" json:"recursive,omitempty"`
+	Action        Action                 `protobuf:"varint,6,opt,name=action,proto3,enum=acl.Action" json:"action,omitempty"` // unspecified for detected changes
+	Entry         *ACLEntryInfo          `protobuf:"bytes,7,opt,name=entry,proto3" json:"entry,omitempty"`                    // unset for detected changes
+	Caller        string                 `protobuf:"bytes,8,opt,name=caller,proto3" json:"caller,omitempty"`
+	EndUser       string                 `protobuf:"bytes,9,opt,name=end_user,json=endUser,proto3" json:"end_user,omitempty"`
+	TransactionId string                 `protobuf:"bytes,10,opt,name=transaction_id,json=transactionId,proto3" json:"transaction_id,omitempty"`
+	Acl           []*ACLEntryInfo        `protobuf:"bytes,11,rep,name=acl,proto3" json:"acl,omitempty"` // access and default entries after the change (empty if not read)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ACLChangeEvent) Reset() {
+	*x = ACLChangeEvent{}
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ACLChangeEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ACLChangeEvent) ProtoMessage() {}
+
+func (x *ACLChangeEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ACLChangeEvent.ProtoReflect.Descriptor instead.
+func (*ACLChangeEvent) Descriptor() ([]byte, []int) {
+	return file_internal_grpcserver_protos_acl_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ACLChangeEvent) GetResumeToken() string {
+	if x != nil {
+		return x.ResumeToken
+	}
+	return ""
+}
+
+func (x *ACLChangeEvent) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *ACLChangeEvent) GetKind() ChangeKind {
+	if x != nil {
+		return x.Kind
+	}
+	return ChangeKind_CHANGE_KIND_UNSPECIFIED
+}
+
+func (x *ACLChangeEvent) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *ACLChangeEvent) GetRecursive() bool {
+	if x != nil {
+		return x.Recursive
+	}
+	return false
+}
+
+func (x *ACLChangeEvent) GetAction() Action {
+	if x != nil {
+		return x.Action
+	}
+	return Action_ACTION_UNSPECIFIED
+}
+
+func (x *ACLChangeEvent) GetEntry() *ACLEntryInfo {
+	if x != nil {
+		return x.Entry
+	}
+	return nil
+}
+
+func (x *ACLChangeEvent) GetCaller() string {
+	if x != nil {
+		return x.Caller
+	}
+	return ""
+}
+
+func (x *ACLChangeEvent) GetEndUser() string {
+	if x != nil {
+		return x.EndUser
+	}
+	return ""
+}
+
+func (x *ACLChangeEvent) GetTransactionId() string {
+	if x != nil {
+		return x.TransactionId
+	}
+	return ""
+}
+
+func (x *ACLChangeEvent) GetAcl() []*ACLEntryInfo {
+	if x != nil {
+		return x.Acl
+	}
+	return nil
+}
+
+type GetDriftReportRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Rescan        bool                   `protobuf:"varint,1,opt,name=rescan,proto3" json:"rescan,omitempty"`                          // scan now (applying the policies) instead of returning the last scheduled report
+	PathPrefix    string                 `protobuf:"bytes,2,opt,name=path_prefix,json=pathPrefix,proto3" json:"path_prefix,omitempty"` // drifts of this path or below it (all paths if empty)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetDriftReportRequest) Reset() {
+	*x = GetDriftReportRequest{}
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetDriftReportRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDriftReportRequest) ProtoMessage() {}
+
+func (x *GetDriftReportRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDriftReportRequest.ProtoReflect.Descriptor instead.
+func (*GetDriftReportRequest) Descriptor() ([]byte, []int) {
+	return file_internal_grpcserver_protos_acl_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *GetDriftReportRequest) GetRescan() bool {
+	if x != nil {
+		return x.Rescan
+	}
+	return false
+}
+
+func (x *GetDriftReportRequest) GetPathPrefix() string {
+	if x != nil {
+		return x.PathPrefix
+	}
+	return ""
+}
+
+// path whose ACL differs from the one recorded after its last change through aclapi
+type PathDrift struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Seq           uint64                 `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"` // audit record of the change that set the expected ACL
+	Expected      []*ACLEntryInfo        `protobuf:"bytes,3,rep,name=expected,proto3" json:"expected,omitempty"`
+	Actual        []*ACLEntryInfo        `protobuf:"bytes,4,rep,name=actual,proto3" json:"actual,omitempty"` // empty if the ACL could not be read
+	Policy        DriftPolicy            `protobuf:"varint,5,opt,name=policy,proto3,enum=acl.DriftPolicy" json:"policy,omitempty"`
+	Remediated    bool                   `protobuf:"varint,6,opt,name=remediated,proto3" json:"remediated,omitempty"`
+	Error         string                 `protobuf:"bytes,7,opt,name=error,proto3" json:"error,omitempty"` // why the path could not be read or remediated
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PathDrift) Reset() {
+	*x = PathDrift{}
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PathDrift) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PathDrift) ProtoMessage() {}
+
+func (x *PathDrift) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PathDrift.ProtoReflect.Descriptor instead.
+func (*PathDrift) Descriptor() ([]byte, []int) {
+	return file_internal_grpcserver_protos_acl_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *PathDrift) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *PathDrift) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *PathDrift) GetExpected() []*ACLEntryInfo {
+	if x != nil {
+		return x.Expected
+	}
+	return nil
+}
+
+func (x *PathDrift) GetActual() []*ACLEntryInfo {
+	if x != nil {
+		return x.Actual
+	}
+	return nil
+}
+
+func (x *PathDrift) GetPolicy() DriftPolicy {
+	if x != nil {
+		return x.Policy
+	}
+	return DriftPolicy_DRIFT_POLICY_UNSPECIFIED
+}
+
+func (x *PathDrift) GetRemediated() bool {
+	if x != nil {
+		return x.Remediated
+	}
+	return false
+}
+
+func (x *PathDrift) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type DriftReport struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Started       *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=started,proto3" json:"started,omitempty"`
+	Finished      *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=finished,proto3" json:"finished,omitempty"`
+	Checked       uint32                 `protobuf:"varint,3,opt,name=checked,proto3" json:"checked,omitempty"` // recorded paths that still exist
+	Drifts        []*PathDrift           `protobuf:"bytes,4,rep,name=drifts,proto3" json:"drifts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DriftReport) Reset() {
+	*x = DriftReport{}
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DriftReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DriftReport) ProtoMessage() {}
+
+func (x *DriftReport) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpcserver_protos_acl_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DriftReport.ProtoReflect.Descriptor instead.
+func (*DriftReport) Descriptor() ([]byte, []int) {
+	return file_internal_grpcserver_protos_acl_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *DriftReport) GetStarted() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Started
+	}
+	return nil
+}
+
+func (x *DriftReport) GetFinished() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Finished
+	}
+	return nil
+}
+
+func (x *DriftReport) GetChecked() uint32 {
+	if x != nil {
+		return x.Checked
+	}
+	return 0
+}
+
+func (x *DriftReport) GetDrifts() []*PathDrift {
+	if x != nil {
+		return x.Drifts
+	}
+	return nil
+}
+
+var File_internal_grpcserver_protos_acl_proto protoreflect.FileDescriptor
+
+const file_internal_grpcserver_protos_acl_proto_rawDesc = "" +
+	"\n" +
+	"$internal/grpcserver/protos/acl.proto\x12\x03acl\x1a\x1fgoogle/protobuf/timestamp.proto\"\x9c\x01\n" +
+	"\bACLEntry\x12\x1f\n" +
+	"\ventity_type\x18\x01 \x01(\tR\n" +
+	"entityType\x12\x16\n" +
+	"\x06entity\x18\x02 \x01(\tR\x06entity\x12 \n" +
+	"\vpermissions\x18\x03 \x01(\tR\vpermissions\x12\x16\n" +
+	"\x06action\x18\x04 \x01(\tR\x06action\x12\x1d\n" +
+	"\n" +
+	"is_default\x18\x05 \x01(\bR\tisDefault\"\xbc\x01\n" +
+	"\n" +
+	"ACLEntryV2\x120\n" +
+	"\ventity_type\x18\x01 \x01(\x0e2\x0f.acl.EntityTypeR\n" +
+	"entityType\x12\x16\n" +
+	"\x06entity\x18\x02 \x01(\tR\x06entity\x12 \n" +
+	"\vpermissions\x18\x03 \x01(\rR\vpermissions\x12#\n" +
+	"\x06action\x18\x04 \x01(\x0e2\v.acl.ActionR\x06action\x12\x1d\n" +
+	"\n" +
+	"is_default\x18\x05 \x01(\bR\tisDefault\"\xc7\x01\n" +
+	"\x0fApplyACLRequest\x12$\n" +
+	"\rtransactionID\x18\x01 \x01(\tR\rtransactionID\x12\x1f\n" +
+	"\vtarget_path\x18\x02 \x01(\tR\n" +
+	"targetPath\x12#\n" +
+	"\x05entry\x18\x03 \x01(\v2\r.acl.ACLEntryR\x05entry\x12*\n" +
+	"\bentry_v2\x18\x04 \x01(\v2\x0f.acl.ACLEntryV2R\aentryV2\x12\x1c\n" +
+	"\trecursive\x18\x05 \x01(\bR\trecursive\"F\n" +
+	"\x10ApplyACLResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"*\n" +
+	"\x12ExpandGroupRequest\x12\x14\n" +
+	"\x05group\x18\x01 \x01(\tR\x05group\"W\n" +
+	"\x13ExpandGroupResponse\x12\x14\n" +
+	"\x05group\x18\x01 \x01(\tR\x05group\x12\x10\n" +
+	"\x03gid\x18\x02 \x01(\rR\x03gid\x12\x18\n" +
+	"\amembers\x18\x03 \x03(\tR\amembers\"#\n" +
+	"\rGetACLRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\"\xb5\x01\n" +
+	"\fACLEntryInfo\x120\n" +
+	"\ventity_type\x18\x01 \x01(\x0e2\x0f.acl.EntityTypeR\n" +
+	"entityType\x12\x16\n" +
+	"\x06entity\x18\x02 \x01(\tR\x06entity\x12\x13\n" +
+	"\x02id\x18\x03 \x01(\rH\x00R\x02id\x88\x01\x01\x12 \n" +
+	"\vpermissions\x18\x04 \x01(\rR\vpermissions\x12\x1d\n" +
+	"\n" +
+	"is_default\x18\x05 \x01(\bR\tisDefaultB\x05\n" +
+	"\x03_id\"\x8b\x01\n" +
+	"\x0eGetACLResponse\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12)\n" +
+	"\x06access\x18\x02 \x03(\v2\x11.acl.ACLEntryInfoR\x06access\x12:\n" +
+	"\x0fdefault_entries\x18\x03 \x03(\v2\x11.acl.ACLEntryInfoR\x0edefaultEntries\"\xc6\x02\n" +
+	"\x11QueryAuditRequest\x12\x1f\n" +
+	"\vpath_prefix\x18\x01 \x01(\tR\n" +
+	"pathPrefix\x12\x16\n" +
+	"\x06entity\x18\x02 \x01(\tR\x06entity\x12\x16\n" +
+	"\x06caller\x18\x03 \x01(\tR\x06caller\x12\x19\n" +
+	"\bend_user\x18\x04 \x01(\tR\aendUser\x12%\n" +
+	"\x0etransaction_id\x18\x05 \x01(\tR\rtransactionId\x120\n" +
+	"\x05since\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\x05since\x120\n" +
+	"\x05until\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\x05until\x12\x1b\n" +
+	"\tpage_size\x18\b \x01(\rR\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\t \x01(\tR\tpageToken\"\xaf\x04\n" +
+	"\vAuditRecord\x12\x10\n" +
+	"\x03seq\x18\x01 \x01(\x04R\x03seq\x12.\n" +
+	"\x04time\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x16\n" +
+	"\x06caller\x18\x03 \x01(\tR\x06caller\x12!\n" +
+	"\fcert_subject\x18\x04 \x01(\tR\vcertSubject\x12\x19\n" +
+	"\bend_user\x18\x05 \x01(\tR\aendUser\x12\x12\n" +
+	"\x04peer\x18\x06 \x01(\tR\x04peer\x12%\n" +
+	"\x0etransaction_id\x18\a \x01(\tR\rtransactionId\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\b \x01(\tR\trequestId\x12\x12\n" +
+	"\x04path\x18\t \x01(\tR\x04path\x12#\n" +
+	"\x06action\x18\n" +
+	" \x01(\x0e2\v.acl.ActionR\x06action\x12'\n" +
+	"\x05entry\x18\v \x01(\v2\x11.acl.ACLEntryInfoR\x05entry\x12\x1c\n" +
+	"\trecursive\x18\f \x01(\bR\trecursive\x12)\n" +
+	"\x06before\x18\r \x03(\v2\x11.acl.ACLEntryInfoR\x06before\x12'\n" +
+	"\x05after\x18\x0e \x03(\v2\x11.acl.ACLEntryInfoR\x05after\x12\x18\n" +
+	"\asuccess\x18\x0f \x01(\bR\asuccess\x12\x14\n" +
+	"\x05error\x18\x10 \x01(\tR\x05error\x12\x12\n" +
+	"\x04hash\x18\x11 \x01(\tR\x04hash\x12\x16\n" +
+	"\x06source\x18\x12 \x01(\tR\x06source\"f\n" +
+	"\x12QueryAuditResponse\x12(\n" +
+	"\x06record\x18\x01 \x01(\v2\x10.acl.AuditRecordR\x06record\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"\\\n" +
+	"\x16WatchACLChangesRequest\x12\x1f\n" +
+	"\vpath_prefix\x18\x01 \x01(\tR\n" +
+	"pathPrefix\x12!\n" +
+	"\fresume_token\x18\x02 \x01(\tR\vresumeToken\"\x87\x03\n" +
+	"\x0eACLChangeEvent\x12!\n" +
+	"\fresume_token\x18\x01 \x01(\tR\vresumeToken\x12.\n" +
+	"\x04time\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12#\n" +
+	"\x04kind\x18\x03 \x01(\x0e2\x0f.acl.ChangeKindR\x04kind\x12\x12\n" +
+	"\x04path\x18\x04 \x01(\tR\x04path\x12\x1c\n" +
+	"\trecursive\x18\x05 \x01(\bR\trecursive\x12#\n" +
+	"\x06action\x18\x06 \x01(\x0e2\v.acl.ActionR\x06action\x12'\n" +
+	"\x05entry\x18\a \x01(\v2\x11.acl.ACLEntryInfoR\x05entry\x12\x16\n" +
+	"\x06caller\x18\b \x01(\tR\x06caller\x12\x19\n" +
+	"\bend_user\x18\t \x01(\tR\aendUser\x12%\n" +
+	"\x0etransaction_id\x18\n" +
+	" \x01(\tR\rtransactionId\x12#\n" +
+	"\x03acl\x18\v \x03(\v2\x11.acl.ACLEntryInfoR\x03acl\"P\n" +
+	"\x15GetDriftReportRequest\x12\x16\n" +
+	"\x06rescan\x18\x01 \x01(\bR\x06rescan\x12\x1f\n" +
+	"\vpath_prefix\x18\x02 \x01(\tR\n" +
+	"pathPrefix\"\xeb\x01\n" +
+	"\tPathDrift\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x10\n" +
+	"\x03seq\x18\x02 \x01(\x04R\x03seq\x12-\n" +
+	"\bexpected\x18\x03 \x03(\v2\x11.acl.ACLEntryInfoR\bexpected\x12)\n" +
+	"\x06actual\x18\x04 \x03(\v2\x11.acl.ACLEntryInfoR\x06actual\x12(\n" +
+	"\x06policy\x18\x05 \x01(\x0e2\x10.acl.DriftPolicyR\x06policy\x12\x1e\n" +
+	"\n" +
+	"remediated\x18\x06 \x01(\bR\n" +
+	"remediated\x12\x14\n" +
+	"\x05error\x18\a \x01(\tR\x05error\"\xbd\x01\n" +
+	"\vDriftReport\x124\n" +
+	"\astarted\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\astarted\x126\n" +
+	"\bfinished\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\bfinished\x12\x18\n" +
+	"\achecked\x18\x03 \x01(\rR\achecked\x12&\n" +
+	"\x06drifts\x18\x04 \x03(\v2\x0e.acl.PathDriftR\x06drifts*\x83\x01\n" +
+	"\n" +
+	"EntityType\x12\x1b\n" +
+	"\x17ENTITY_TYPE_UNSPECIFIED\x10\x00\x12\x14\n" +
+	"\x10ENTITY_TYPE_USER\x10\x01\x12\x15\n" +
+	"\x11ENTITY_TYPE_GROUP\x10\x02\x12\x14\n" +
+	"\x10ENTITY_TYPE_MASK\x10\x03\x12\x15\n" +
+	"\x11ENTITY_TYPE_OTHER\x10\x04*V\n" +
+	"\x06Action\x12\x16\n" +
+	"\x12ACTION_UNSPECIFIED\x10\x00\x12\x0e\n" +
+	"\n" +
+	"ACTION_ADD\x10\x01\x12\x11\n" +
+	"\rACTION_MODIFY\x10\x02\x12\x11\n" +
+	"\rACTION_REMOVE\x10\x03*\x88\x01\n" +
+	"\n" +
+	"Permission\x12\x13\n" +
+	"\x0fPERMISSION_NONE\x10\x00\x12\x16\n" +
+	"\x12PERMISSION_EXECUTE\x10\x01\x12\x14\n" +
+	"\x10PERMISSION_WRITE\x10\x02\x12\x13\n" +
+	"\x0fPERMISSION_READ\x10\x04\x12\"\n" +
+	"\x1ePERMISSION_CONDITIONAL_EXECUTE\x10\b*v\n" +
+	"\n" +
+	"ChangeKind\x12\x1b\n" +
+	"\x17CHANGE_KIND_UNSPECIFIED\x10\x00\x12\x17\n" +
+	"\x13CHANGE_KIND_APPLIED\x10\x01\x12\x18\n" +
+	"\x14CHANGE_KIND_REVERTED\x10\x02\x12\x18\n" +
+	"\x14CHANGE_KIND_DETECTED\x10\x03*`\n" +
+	"\vDriftPolicy\x12\x1c\n" +
+	"\x18DRIFT_POLICY_UNSPECIFIED\x10\x00\x12\x17\n" +
+	"\x13DRIFT_POLICY_REPORT\x10\x01\x12\x1a\n" +
+	"\x16DRIFT_POLICY_REMEDIATE\x10\x022\x87\x03\n" +
+	"\n" +
+	"ACLService\x12<\n" +
+	"\rApplyACLEntry\x12\x14.acl.ApplyACLRequest\x1a\x15.acl.ApplyACLResponse\x12@\n" +
+	"\vExpandGroup\x12\x17.acl.ExpandGroupRequest\x1a\x18.acl.ExpandGroupResponse\x121\n" +
+	"\x06GetACL\x12\x12.acl.GetACLRequest\x1a\x13.acl.GetACLResponse\x12?\n" +
+	"\n" +
+	"QueryAudit\x12\x16.acl.QueryAuditRequest\x1a\x17.acl.QueryAuditResponse0\x01\x12E\n" +
+	"\x0fWatchACLChanges\x12\x1b.acl.WatchACLChangesRequest\x1a\x13.acl.ACLChangeEvent0\x01\x12>\n" +
+	"\x0eGetDriftReport\x12\x1a.acl.GetDriftReportRequest\x1a\x10.acl.DriftReportBYZWgithub.com/PythonHacker24/linux-acl-management-aclapi/internal/grpcserver/protos;protosb\x06proto3"
 
 var (
 	file_internal_grpcserver_protos_acl_proto_rawDescOnce sync.Once
@@ -246,21 +1768,77 @@ func file_internal_grpcserver_protos_acl_proto_rawDescGZIP() []byte {
 	return file_internal_grpcserver_protos_acl_proto_rawDescData
 }
 
-var file_internal_grpcserver_protos_acl_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_internal_grpcserver_protos_acl_proto_enumTypes = make([]protoimpl.EnumInfo, 5)
+var file_internal_grpcserver_protos_acl_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
 var file_internal_grpcserver_protos_acl_proto_goTypes = []any{
-	(*ACLEntry)(nil),         // 0: acl.ACLEntry
-	(*ApplyACLRequest)(nil),  // 1: acl.ApplyACLRequest
-	(*ApplyACLResponse)(nil), // 2: acl.ApplyACLResponse
+	(EntityType)(0),                // 0: acl.EntityType
+	(Action)(0),                    // 1: acl.Action
+	(Permission)(0),                // 2: acl.Permission
+	(ChangeKind)(0),                // 3: acl.ChangeKind
+	(DriftPolicy)(0),               // 4: acl.DriftPolicy
+	(*ACLEntry)(nil),               // 5: acl.ACLEntry
+	(*ACLEntryV2)(nil),             // 6: acl.ACLEntryV2
+	(*ApplyACLRequest)(nil),        // 7: acl.ApplyACLRequest
+	(*ApplyACLResponse)(nil),       // 8: acl.ApplyACLResponse
+	(*ExpandGroupRequest)(nil),     // 9: acl.ExpandGroupRequest
+	(*ExpandGroupResponse)(nil),    // 10: acl.ExpandGroupResponse
+	(*GetACLRequest)(nil),          // 11: acl.GetACLRequest
+	(*ACLEntryInfo)(nil),           // 12: acl.ACLEntryInfo
+	(*GetACLResponse)(nil),         // 13: acl.GetACLResponse
+	(*QueryAuditRequest)(nil),      // 14: acl.QueryAuditRequest
+	(*AuditRecord)(nil),            // 15: acl.AuditRecord
+	(*QueryAuditResponse)(nil),     // 16: acl.QueryAuditResponse
+	(*WatchACLChangesRequest)(nil), // 17: acl.WatchACLChangesRequest
+	(*ACLChangeEvent)(nil),         // 18: acl.ACLChangeEvent
+	(*GetDriftReportRequest)(nil),  // 19: acl.GetDriftReportRequest
+	(*PathDrift)(nil),              // 20: acl.PathDrift
+	(*DriftReport)(nil),            // 21: acl.DriftReport
+	(*timestamppb.Timestamp)(nil),  // 22: google.protobuf.Timestamp
 }
 var file_internal_grpcserver_protos_acl_proto_depIdxs = []int32{
-	0, // 0: acl.ApplyACLRequest.entry:type_name -> acl.ACLEntry
-	1, // 1: acl.ACLService.ApplyACLEntry:input_type -> acl.ApplyACLRequest
-	2, // 2: acl.ACLService.ApplyACLEntry:output_type -> acl.ApplyACLResponse
-	2, // [2:3] is the sub-list for method output_type
-	1, // [1:2] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	0,  // 0: acl.ACLEntryV2.entity_type:type_name -> acl.EntityType
+	1,  // 1: acl.ACLEntryV2.action:type_name -> acl.Action
+	5,  // 2: acl.ApplyACLRequest.entry:type_name -> acl.ACLEntry
+	6,  // 3: acl.ApplyACLRequest.entry_v2:type_name -> acl.ACLEntryV2
+	0,  // 4: acl.ACLEntryInfo.entity_type:type_name -> acl.EntityType
+	12, // 5: acl.GetACLResponse.access:type_name -> acl.ACLEntryInfo
+	12, // 6: acl.GetACLResponse.default_entries:type_name -> acl.ACLEntryInfo
+	22, // 7: acl.QueryAuditRequest.since:type_name -> google.protobuf.Timestamp
+	22, // 8: acl.QueryAuditRequest.until:type_name -> google.protobuf.Timestamp
+	22, // 9: acl.AuditRecord.time:type_name -> google.protobuf.Timestamp
+	1,  // 10: acl.AuditRecord.action:type_name -> acl.Action
+	12, // 11: acl.AuditRecord.entry:type_name -> acl.ACLEntryInfo
+	12, // 12: acl.AuditRecord.before:type_name -> acl.ACLEntryInfo
+	12, // 13: acl.AuditRecord.after:type_name -> acl.ACLEntryInfo
+	15, // 14: acl.QueryAuditResponse.record:type_name -> acl.AuditRecord
+	22, // 15: acl.ACLChangeEvent.time:type_name -> google.protobuf.Timestamp
+	3,  // 16: acl.ACLChangeEvent.kind:type_name -> acl.ChangeKind
+	1,  // 17: acl.ACLChangeEvent.action:type_name -> acl.Action
+	12, // 18: acl.ACLChangeEvent.entry:type_name -> acl.ACLEntryInfo
+	12, // 19: acl.ACLChangeEvent.acl:type_name -> acl.ACLEntryInfo
+	12, // 20: acl.PathDrift.expected:type_name -> acl.ACLEntryInfo
+	12, // 21: acl.PathDrift.actual:type_name -> acl.ACLEntryInfo
+	4,  // 22: acl.PathDrift.policy:type_name -> acl.DriftPolicy
+	22, // 23: acl.DriftReport.started:type_name -> google.protobuf.Timestamp
+	22, // 24: acl.DriftReport.finished:type_name -> google.protobuf.Timestamp
+	20, // 25: acl.DriftReport.drifts:type_name -> acl.PathDrift
+	7,  // 26: acl.ACLService.ApplyACLEntry:input_type -> acl.ApplyACLRequest
+	9,  // 27: acl.ACLService.ExpandGroup:input_type -> acl.ExpandGroupRequest
+	11, // 28: acl.ACLService.GetACL:input_type -> acl.GetACLRequest
+	14, // 29: acl.ACLService.QueryAudit:input_type -> acl.QueryAuditRequest
+	17, // 30: acl.ACLService.WatchACLChanges:input_type -> acl.WatchACLChangesRequest
+	19, // 31: acl.ACLService.GetDriftReport:input_type -> acl.GetDriftReportRequest
+	8,  // 32: acl.ACLService.ApplyACLEntry:output_type -> acl.ApplyACLResponse
+	10, // 33: acl.ACLService.ExpandGroup:output_type -> acl.ExpandGroupResponse
+	13, // 34: acl.ACLService.GetACL:output_type -> acl.GetACLResponse
+	16, // 35: acl.ACLService.QueryAudit:output_type -> acl.QueryAuditResponse
+	18, // 36: acl.ACLService.WatchACLChanges:output_type -> acl.ACLChangeEvent
+	21, // 37: acl.ACLService.GetDriftReport:output_type -> acl.DriftReport
+	32, // [32:38] is the sub-list for method output_type
+	26, // [26:32] is the sub-list for method input_type
+	26, // [26:26] is the sub-list for extension type_name
+	26, // [26:26] is the sub-list for extension extendee
+	0,  // [0:26] is the sub-list for field type_name
 }
 
 func init() { file_internal_grpcserver_protos_acl_proto_init() }
@@ -268,18 +1846,20 @@ func file_internal_grpcserver_protos_acl_proto_init() {
 	if File_internal_grpcserver_protos_acl_proto != nil {
 		return
 	}
+	file_internal_grpcserver_protos_acl_proto_msgTypes[7].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_grpcserver_protos_acl_proto_rawDesc), len(file_internal_grpcserver_protos_acl_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   3,
+			NumEnums:      5,
+			NumMessages:   17,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_internal_grpcserver_protos_acl_proto_goTypes,
 		DependencyIndexes: file_internal_grpcserver_protos_acl_proto_depIdxs,
+		EnumInfos:         file_internal_grpcserver_protos_acl_proto_enumTypes,
 		MessageInfos:      file_internal_grpcserver_protos_acl_proto_msgTypes,
 	}.Build()
 	File_internal_grpcserver_protos_acl_proto = out.File
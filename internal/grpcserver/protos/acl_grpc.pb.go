@@ -19,7 +19,12 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	ACLService_ApplyACLEntry_FullMethodName = "/acl.ACLService/ApplyACLEntry"
+	ACLService_ApplyACLEntry_FullMethodName   = "/acl.ACLService/ApplyACLEntry"
+	ACLService_ExpandGroup_FullMethodName     = "/acl.ACLService/ExpandGroup"
+	ACLService_GetACL_FullMethodName          = "/acl.ACLService/GetACL"
+	ACLService_QueryAudit_FullMethodName      = "/acl.ACLService/QueryAudit"
+	ACLService_WatchACLChanges_FullMethodName = "/acl.ACLService/WatchACLChanges"
+	ACLService_GetDriftReport_FullMethodName  = "/acl.ACLService/GetDriftReport"
 )
 
 // ACLServiceClient is the client API for ACLService service.
@@ -27,6 +32,11 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type ACLServiceClient interface {
 	ApplyACLEntry(ctx context.Context, in *ApplyACLRequest, opts ...grpc.CallOption) (*ApplyACLResponse, error)
+	ExpandGroup(ctx context.Context, in *ExpandGroupRequest, opts ...grpc.CallOption) (*ExpandGroupResponse, error)
+	GetACL(ctx context.Context, in *GetACLRequest, opts ...grpc.CallOption) (*GetACLResponse, error)
+	QueryAudit(ctx context.Context, in *QueryAuditRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[QueryAuditResponse], error)
+	WatchACLChanges(ctx context.Context, in *WatchACLChangesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ACLChangeEvent], error)
+	GetDriftReport(ctx context.Context, in *GetDriftReportRequest, opts ...grpc.CallOption) (*DriftReport, error)
 }
 
 type aCLServiceClient struct {
@@ -47,11 +57,84 @@ func (c *aCLServiceClient) ApplyACLEntry(ctx context.Context, in *ApplyACLReques
 	return out, nil
 }
 
+func (c *aCLServiceClient) ExpandGroup(ctx context.Context, in *ExpandGroupRequest, opts ...grpc.CallOption) (*ExpandGroupResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExpandGroupResponse)
+	err := c.cc.Invoke(ctx, ACLService_ExpandGroup_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aCLServiceClient) GetACL(ctx context.Context, in *GetACLRequest, opts ...grpc.CallOption) (*GetACLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetACLResponse)
+	err := c.cc.Invoke(ctx, ACLService_GetACL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aCLServiceClient) QueryAudit(ctx context.Context, in *QueryAuditRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[QueryAuditResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ACLService_ServiceDesc.Streams[0], ACLService_QueryAudit_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[QueryAuditRequest, QueryAuditResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ACLService_QueryAuditClient = grpc.ServerStreamingClient[QueryAuditResponse]
+
+func (c *aCLServiceClient) WatchACLChanges(ctx context.Context, in *WatchACLChangesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ACLChangeEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ACLService_ServiceDesc.Streams[1], ACLService_WatchACLChanges_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchACLChangesRequest, ACLChangeEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ACLService_WatchACLChangesClient = grpc.ServerStreamingClient[ACLChangeEvent]
+
+func (c *aCLServiceClient) GetDriftReport(ctx context.Context, in *GetDriftReportRequest, opts ...grpc.CallOption) (*DriftReport, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DriftReport)
+	err := c.cc.Invoke(ctx, ACLService_GetDriftReport_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ACLServiceServer is the server API for ACLService service.
 // All implementations must embed UnimplementedACLServiceServer
 // for forward compatibility.
 type ACLServiceServer interface {
 	ApplyACLEntry(context.Context, *ApplyACLRequest) (*ApplyACLResponse, error)
+	ExpandGroup(context.Context, *ExpandGroupRequest) (*ExpandGroupResponse, error)
+	GetACL(context.Context, *GetACLRequest) (*GetACLResponse, error)
+	QueryAudit(*QueryAuditRequest, grpc.ServerStreamingServer[QueryAuditResponse]) error
+	WatchACLChanges(*WatchACLChangesRequest, grpc.ServerStreamingServer[ACLChangeEvent]) error
+	GetDriftReport(context.Context, *GetDriftReportRequest) (*DriftReport, error)
 	mustEmbedUnimplementedACLServiceServer()
 }
 
@@ -65,6 +148,21 @@ type UnimplementedACLServiceServer struct{}
 func (UnimplementedACLServiceServer) ApplyACLEntry(context.Context, *ApplyACLRequest) (*ApplyACLResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ApplyACLEntry not implemented")
 }
+func (UnimplementedACLServiceServer) ExpandGroup(context.Context, *ExpandGroupRequest) (*ExpandGroupResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExpandGroup not implemented")
+}
+func (UnimplementedACLServiceServer) GetACL(context.Context, *GetACLRequest) (*GetACLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetACL not implemented")
+}
+func (UnimplementedACLServiceServer) QueryAudit(*QueryAuditRequest, grpc.ServerStreamingServer[QueryAuditResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method QueryAudit not implemented")
+}
+func (UnimplementedACLServiceServer) WatchACLChanges(*WatchACLChangesRequest, grpc.ServerStreamingServer[ACLChangeEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchACLChanges not implemented")
+}
+func (UnimplementedACLServiceServer) GetDriftReport(context.Context, *GetDriftReportRequest) (*DriftReport, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDriftReport not implemented")
+}
 func (UnimplementedACLServiceServer) mustEmbedUnimplementedACLServiceServer() {}
 func (UnimplementedACLServiceServer) testEmbeddedByValue()                    {}
 
@@ -104,6 +202,82 @@ func _ACLService_ApplyACLEntry_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ACLService_ExpandGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExpandGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ACLServiceServer).ExpandGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ACLService_ExpandGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ACLServiceServer).ExpandGroup(ctx, req.(*ExpandGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ACLService_GetACL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetACLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ACLServiceServer).GetACL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ACLService_GetACL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ACLServiceServer).GetACL(ctx, req.(*GetACLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ACLService_QueryAudit_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(QueryAuditRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ACLServiceServer).QueryAudit(m, &grpc.GenericServerStream[QueryAuditRequest, QueryAuditResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ACLService_QueryAuditServer = grpc.ServerStreamingServer[QueryAuditResponse]
+
+func _ACLService_WatchACLChanges_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchACLChangesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ACLServiceServer).WatchACLChanges(m, &grpc.GenericServerStream[WatchACLChangesRequest, ACLChangeEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ACLService_WatchACLChangesServer = grpc.ServerStreamingServer[ACLChangeEvent]
+
+func _ACLService_GetDriftReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDriftReportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ACLServiceServer).GetDriftReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ACLService_GetDriftReport_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ACLServiceServer).GetDriftReport(ctx, req.(*GetDriftReportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ACLService_ServiceDesc is the grpc.ServiceDesc for ACLService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -115,7 +289,30 @@ var ACLService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ApplyACLEntry",
 			Handler:    _ACLService_ApplyACLEntry_Handler,
 		},
+		{
+			MethodName: "ExpandGroup",
+			Handler:    _ACLService_ExpandGroup_Handler,
+		},
+		{
+			MethodName: "GetACL",
+			Handler:    _ACLService_GetACL_Handler,
+		},
+		{
+			MethodName: "GetDriftReport",
+			Handler:    _ACLService_GetDriftReport_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "QueryAudit",
+			Handler:       _ACLService_QueryAudit_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchACLChanges",
+			Handler:       _ACLService_WatchACLChanges_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "internal/grpcserver/protos/acl.proto",
 }
@@ -0,0 +1,98 @@
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/acl"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/identity"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/ratelimit"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/tracing"
+)
+
+/* services never limited: load balancers probe health, reflection is for debugging */
+var unlimitedServices = []string{
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.",
+}
+
+/*
+rejects unary requests of callers (client certificate and end user) that
+exhausted their budget for the method, or whose client certificate exhausted
+the budget shared by all its end users
+*/
+func UnaryRateLimitInterceptor(limiter *ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if err := rateLimit(ctx, limiter, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+/* same as UnaryRateLimitInterceptor for streaming requests, counted when opened */
+func StreamRateLimitInterceptor(limiter *ratelimit.Limiter) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if err := rateLimit(stream.Context(), limiter, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
+
+/* takes a token for the caller, building the RESOURCE_EXHAUSTED error when there is none */
+func rateLimit(ctx context.Context, limiter *ratelimit.Limiter, method string) error {
+	for _, prefix := range unlimitedServices {
+		if strings.HasPrefix(method, prefix) {
+			return nil
+		}
+	}
+
+	caller := identity.FromContext(ctx)
+	ok, wait := limiter.Allow(caller.Caller, caller.EndUser, method)
+	if ok {
+		return nil
+	}
+
+	/* floods would flood the logs too, rejections are counted by the RPC metrics */
+	tracing.Logger(ctx).Debug("Rate limit exceeded",
+		zap.String("method", method),
+		zap.String("caller", caller.Key()),
+		zap.Duration("retry_after", wait),
+	)
+
+	st := status.New(codes.ResourceExhausted, fmt.Sprintf("rate limit exceeded, retry in %s", wait))
+	detailed, err := st.WithDetails(
+		&errdetails.ErrorInfo{
+			Reason: acl.ReasonRateLimited,
+			Domain: acl.ErrorDomain,
+			Metadata: map[string]string{
+				"method":      method,
+				"retry_after": wait.String(),
+			},
+		},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(wait)},
+	)
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
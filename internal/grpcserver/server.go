@@ -4,10 +4,37 @@ import (
 	"google.golang.org/grpc"
 
 	"github.com/PythonHacker24/linux-acl-management-aclapi/config"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/audit"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/backend"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/directory"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/drift"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/events"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/fswatch"
 )
 
 /* server struct for gRPC server */
 type Server struct {
 	GRPC   *grpc.Server
 	Config *config.Server
+
+	/* ACL backend shared by the handlers (closed on shutdown) */
+	Backend backend.Backend
+
+	/* principal directory shared by the handlers (closed on shutdown) */
+	Directory directory.Directory
+
+	/* grpc.health.v1 status (drained on shutdown) */
+	Health *Health
+
+	/* audit log of ACL changes, nil if disabled (closed on shutdown) */
+	Audit *audit.Log
+
+	/* ACL change events, nil if disabled (closed on shutdown to end the watch streams) */
+	Events *events.Broker
+
+	/* watcher of external ACL changes, nil if disabled (started with the server, closed on shutdown) */
+	Watcher *fswatch.Watcher
+
+	/* drift scanner, nil if disabled (started with the server, closed on shutdown) */
+	Drift *drift.Scanner
 }
@@ -5,27 +5,31 @@ import (
 	"crypto/x509"
 	"fmt"
 	"os"
+	"time"
 
 	"google.golang.org/grpc/credentials"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/config"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclcore"
 )
 
-/* load tls config for gRPC server */
-func loadMutualTLSCredentials(TLSCertFile, TLSKeyFile, TLSCACertFile string) (credentials.TransportCredentials, error) {
+/* load tls config for gRPC server, also returning the server certificate for health checks */
+func loadMutualTLSCredentials(TLSCertFile, TLSKeyFile, TLSCACertFile string) (credentials.TransportCredentials, *x509.Certificate, error) {
 	/* load TLS certificate and keys */
 	cert, err := tls.LoadX509KeyPair(TLSCertFile, TLSKeyFile)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	/* load CA certificate file */
 	caCert, err := os.ReadFile(TLSCACertFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read CA cert: %w", err)
+		return nil, nil, fmt.Errorf("failed to read CA cert: %w", err)
 	}
 	/* create a new certificate pool and append CA certificate */
 	certPool := x509.NewCertPool()
 	if ok := certPool.AppendCertsFromPEM(caCert); !ok {
-		return nil, fmt.Errorf("failed to add CA cert to pool")
+		return nil, nil, fmt.Errorf("failed to add CA cert to pool")
 	}
 
 	/* build a TLS config */
@@ -37,5 +41,20 @@ func loadMutualTLSCredentials(TLSCertFile, TLSKeyFile, TLSCACertFile string) (cr
 	}
 
 	/* return TLS credentials */
-	return credentials.NewTLS(tlsConfig), nil
+	return credentials.NewTLS(tlsConfig), cert.Leaf, nil
+}
+
+/* converts the configured aclcore timeouts for the aclcore client */
+func coreTimeouts(cfg *config.CoreTimeouts) aclcore.Timeouts {
+	timeouts := aclcore.Timeouts{
+		Connect:    cfg.Connect,
+		Default:    cfg.Default,
+		Operations: make(map[aclcore.Op]time.Duration, len(cfg.Operations)),
+	}
+
+	for op, timeout := range cfg.Operations {
+		timeouts.Operations[aclcore.Op(op)] = timeout
+	}
+
+	return timeouts
 }
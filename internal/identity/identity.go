@@ -0,0 +1,88 @@
+package identity
+
+import (
+	"context"
+	"crypto/x509"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/tracing"
+)
+
+/* metadata key carrying the end user on whose behalf the backend calls aclapi */
+const EndUserKey = "x-end-user"
+
+/* caller name used when the connection carries no client certificate */
+const Unauthenticated = "unauthenticated"
+
+/* longest end user name accepted from a caller, longer ones are dropped */
+const maxEndUserLength = 256
+
+/*
+who is calling aclapi: the service authenticated by its client certificate and
+the end user it acts for (as claimed by that service, never verified here)
+*/
+type Identity struct {
+	/* common name of the client certificate, Unauthenticated without mTLS */
+	Caller string
+
+	/* full subject DN of the client certificate */
+	CertSubject string
+
+	/* end user sent in the x-end-user metadata */
+	EndUser string
+
+	/* remote address of the connection */
+	Peer string
+}
+
+/* extracts the identity of the caller of the request handled under ctx */
+func FromContext(ctx context.Context) *Identity {
+	id := &Identity{Caller: Unauthenticated}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		if p.Addr != nil {
+			id.Peer = p.Addr.String()
+		}
+		if cert := clientCertificate(p); cert != nil {
+			id.Caller = cert.Subject.CommonName
+			id.CertSubject = cert.Subject.String()
+		}
+	}
+
+	/* end user names end up in logs and the audit trail */
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(EndUserKey); len(values) > 0 && tracing.Printable(values[0], maxEndUserLength) {
+			id.EndUser = values[0]
+		}
+	}
+
+	return id
+}
+
+/*
+key identifying the caller and end user together, used to tell apart the
+users of a shared backend
+*/
+func (i *Identity) Key() string {
+	if i.EndUser == "" {
+		return i.Caller
+	}
+	return i.Caller + "/" + i.EndUser
+}
+
+/* verified leaf certificate presented by the client, nil without mTLS */
+func clientCertificate(p *peer.Peer) *x509.Certificate {
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil
+	}
+
+	/* only trust certificates that were verified against the client CA */
+	if len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return info.State.VerifiedChains[0][0]
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+/* interceptor counting unary RPCs and measuring their latency */
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		done := begin(info.FullMethod)
+		resp, err := handler(ctx, req)
+		done(err)
+		return resp, err
+	}
+}
+
+/* interceptor counting streaming RPCs and measuring how long they stay open */
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		done := begin(info.FullMethod)
+		err := handler(srv, stream)
+		done(err)
+		return err
+	}
+}
+
+/* marks an RPC as in flight, the returned function records its outcome */
+func begin(method string) func(err error) {
+	start := time.Now()
+	inFlight := grpcInFlight.WithLabelValues(method)
+	inFlight.Inc()
+
+	return func(err error) {
+		inFlight.Dec()
+		grpcRequests.WithLabelValues(method, status.Code(err).String()).Inc()
+		grpcDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,315 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclcore"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/pathlock"
+)
+
+/* prefix of every metric exported by aclapi */
+const namespace = "aclapi"
+
+/* registry served on the metrics endpoint */
+var Registry = prometheus.NewRegistry()
+
+var (
+	/* finished RPCs by method and status code */
+	grpcRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "requests_total",
+		Help:      "gRPC requests handled, by method and status code.",
+	}, []string{"method", "code"})
+
+	/* RPC latency by method */
+	grpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "request_duration_seconds",
+		Help:      "gRPC request latency, by method.",
+		Buckets:   []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"method"})
+
+	/* RPCs being handled right now */
+	grpcInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "in_flight_requests",
+		Help:      "gRPC requests currently being handled, by method.",
+	}, []string{"method"})
+
+	/* aclcore attempts by operation and error class */
+	coreRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "aclcore",
+		Name:      "requests_total",
+		Help:      "aclcore request attempts (retries included), by operation and result.",
+	}, []string{"op", "result"})
+
+	/* aclcore round trip latency by operation */
+	coreDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "aclcore",
+		Name:      "request_duration_seconds",
+		Help:      "aclcore round trip latency of request attempts, by operation.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 5, 30},
+	}, []string{"op"})
+
+	/* path lock attempts by kind and result */
+	lockRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "pathlock",
+		Name:      "requests_total",
+		Help:      "Path lock attempts, by kind (path or subtree) and result (free, waited, timeout or canceled).",
+	}, []string{"kind", "result"})
+
+	/* time spent waiting for conflicting operations */
+	lockWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "pathlock",
+		Name:      "wait_seconds",
+		Help:      "Time contended path lock attempts waited, by kind.",
+		Buckets:   []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"kind"})
+
+	/* audit records written or lost */
+	auditAppends = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "audit",
+		Name:      "appends_total",
+		Help:      "Audit records appended, by result (ok or failed). Failed records are changes missing from the audit log.",
+	}, []string{"result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		grpcRequests,
+		grpcDuration,
+		grpcInFlight,
+		coreRequests,
+		coreDuration,
+		lockRequests,
+		lockWait,
+		auditAppends,
+		newBuildInfo(),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+/* collectors registered per server, keyed by subsystem */
+var (
+	registeredMu sync.Mutex
+	registered   = map[string]prometheus.Collector{}
+)
+
+/* registers the collector of a subsystem, unregistering the one of an earlier server */
+func replace(subsystem string, collector prometheus.Collector) error {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+
+	if previous, ok := registered[subsystem]; ok {
+		Registry.Unregister(previous)
+		delete(registered, subsystem)
+	}
+
+	if err := Registry.Register(collector); err != nil {
+		return fmt.Errorf("failed to register %s metrics: %w", subsystem, err)
+	}
+	registered[subsystem] = collector
+
+	return nil
+}
+
+/* results of aclcore request attempts */
+const (
+	ResultOK             = "ok"
+	ResultOperationError = "operation_error"
+	ResultUnavailable    = "unavailable"
+	ResultCircuitOpen    = "circuit_open"
+	ResultTimeout        = "timeout"
+	ResultCanceled       = "canceled"
+	ResultProtocolError  = "protocol_error"
+	ResultUntrusted      = "untrusted"
+	ResultOther          = "other"
+)
+
+/* classifies the outcome of an aclcore request attempt */
+func CoreResult(err error) string {
+	var opErr *aclcore.OperationError
+	var protoErr *aclcore.ProtocolError
+	var peerErr *aclcore.PeerError
+
+	switch {
+	case err == nil:
+		return ResultOK
+	case errors.Is(err, aclcore.ErrCircuitOpen):
+		return ResultCircuitOpen
+	case errors.Is(err, context.DeadlineExceeded):
+		return ResultTimeout
+	case errors.Is(err, context.Canceled):
+		return ResultCanceled
+	case errors.As(err, &opErr):
+		return ResultOperationError
+	case errors.As(err, &peerErr):
+		return ResultUntrusted
+	case errors.As(err, &protoErr):
+		return ResultProtocolError
+	case errors.Is(err, aclcore.ErrUnavailable):
+		return ResultUnavailable
+	}
+	return ResultOther
+}
+
+/* aclcore.Observer recording attempt counts and latency */
+func ObserveCore(op aclcore.Op, elapsed time.Duration, err error) {
+	coreRequests.WithLabelValues(string(op), CoreResult(err)).Inc()
+
+	/* attempts rejected by the breaker never reached aclcore */
+	if !errors.Is(err, aclcore.ErrCircuitOpen) {
+		coreDuration.WithLabelValues(string(op)).Observe(elapsed.Seconds())
+	}
+}
+
+/*
+exports the state of an aclcore client on every scrape
+the collector of a previously registered client is replaced
+*/
+func RegisterCoreClient(client *aclcore.Client) error {
+	return replace("aclcore", &coreCollector{client: client})
+}
+
+/* reads pool and breaker state when scraped */
+type coreCollector struct {
+	client *aclcore.Client
+}
+
+var (
+	poolSizeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "aclcore", "pool_size"),
+		"Maximum number of connections to aclcore.",
+		nil, nil,
+	)
+	poolConnsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "aclcore", "pool_connections"),
+		"Open connections to aclcore, by state (in_use or idle).",
+		[]string{"state"}, nil,
+	)
+	breakerDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "aclcore", "circuit_breaker_state"),
+		"State of the aclcore circuit breaker (0 closed, 1 open, 2 half-open).",
+		nil, nil,
+	)
+)
+
+func (c *coreCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolSizeDesc
+	ch <- poolConnsDesc
+	ch <- breakerDesc
+}
+
+func (c *coreCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.client.PoolStats()
+	ch <- prometheus.MustNewConstMetric(poolSizeDesc, prometheus.GaugeValue, float64(stats.Size))
+	ch <- prometheus.MustNewConstMetric(poolConnsDesc, prometheus.GaugeValue, float64(stats.InUse), "in_use")
+	ch <- prometheus.MustNewConstMetric(poolConnsDesc, prometheus.GaugeValue, float64(stats.Idle), "idle")
+	ch <- prometheus.MustNewConstMetric(breakerDesc, prometheus.GaugeValue, float64(c.client.BreakerState()))
+}
+
+/* pathlock.Observer recording lock contention */
+func ObservePathLock(subtree bool, waited time.Duration, err error) {
+	kind := "path"
+	if subtree {
+		kind = "subtree"
+	}
+
+	result := "free"
+	switch {
+	case errors.Is(err, pathlock.ErrTimeout):
+		result = "timeout"
+	case err != nil:
+		result = "canceled"
+	case waited > 0:
+		result = "waited"
+	}
+	lockRequests.WithLabelValues(kind, result).Inc()
+
+	/* free locks did not wait */
+	if result != "free" {
+		lockWait.WithLabelValues(kind).Observe(waited.Seconds())
+	}
+}
+
+/* audit.Log observer counting written and lost records */
+func ObserveAudit(err error) {
+	result := "ok"
+	if err != nil {
+		result = "failed"
+	}
+	auditAppends.WithLabelValues(result).Inc()
+}
+
+/*
+exports the number of held and waiting path locks on every scrape
+the collector of a previously registered manager is replaced
+*/
+func RegisterPathLocks(locks *pathlock.Manager) error {
+	return replace("pathlock", &lockCollector{locks: locks})
+}
+
+/* reads the lock manager state when scraped */
+type lockCollector struct {
+	locks *pathlock.Manager
+}
+
+var lockStateDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "pathlock", "locks"),
+	"Path locks, by state (held or waiting).",
+	[]string{"state"}, nil,
+)
+
+func (c *lockCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- lockStateDesc
+}
+
+func (c *lockCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.locks.Stats()
+	ch <- prometheus.MustNewConstMetric(lockStateDesc, prometheus.GaugeValue, float64(stats.Held), "held")
+	ch <- prometheus.MustNewConstMetric(lockStateDesc, prometheus.GaugeValue, float64(stats.Waiting), "waiting")
+}
+
+/* constant aclapi_build_info metric labelled with version, revision and Go version */
+func newBuildInfo() prometheus.Collector {
+	version, revision := "unknown", "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		version = info.Main.Version
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				revision = setting.Value
+			}
+		}
+	}
+
+	buildInfo := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "build_info",
+		Help:      "Build information of the running aclapi binary, always 1.",
+		ConstLabels: prometheus.Labels{
+			"version":    version,
+			"revision":   revision,
+			"go_version": runtime.Version(),
+		},
+	})
+	buildInfo.Set(1)
+	return buildInfo
+}
@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclcore"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/pathlock"
+)
+
+func TestCoreResult(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{err: nil, want: ResultOK},
+		{err: aclcore.ErrCircuitOpen, want: ResultCircuitOpen},
+		{err: fmt.Errorf("aclcore get interrupted: %w", context.DeadlineExceeded), want: ResultTimeout},
+		{err: fmt.Errorf("aclcore get interrupted: %w", context.Canceled), want: ResultCanceled},
+		{err: &aclcore.OperationError{Errno: syscall.EACCES}, want: ResultOperationError},
+		{err: &aclcore.PeerError{Reason: "wrong uid"}, want: ResultUntrusted},
+		{err: &aclcore.ProtocolError{Reason: "bad frame"}, want: ResultProtocolError},
+		{err: fmt.Errorf("dial: %w", aclcore.ErrUnavailable), want: ResultUnavailable},
+		{err: errors.New("something else"), want: ResultOther},
+	}
+
+	for _, test := range tests {
+		if got := CoreResult(test.err); got != test.want {
+			t.Errorf("CoreResult(%v) = %s, want %s", test.err, got, test.want)
+		}
+	}
+}
+
+func TestObserveCore(t *testing.T) {
+	coreRequests.Reset()
+	coreDuration.Reset()
+
+	ObserveCore(aclcore.OpGet, time.Millisecond, nil)
+	ObserveCore(aclcore.OpGet, time.Millisecond, nil)
+	ObserveCore(aclcore.OpApply, time.Millisecond, &aclcore.OperationError{Errno: syscall.EPERM})
+	ObserveCore(aclcore.OpApply, 0, aclcore.ErrCircuitOpen)
+
+	expected := `
+		# HELP aclapi_aclcore_requests_total aclcore request attempts (retries included), by operation and result.
+		# TYPE aclapi_aclcore_requests_total counter
+		aclapi_aclcore_requests_total{op="apply",result="circuit_open"} 1
+		aclapi_aclcore_requests_total{op="apply",result="operation_error"} 1
+		aclapi_aclcore_requests_total{op="get",result="ok"} 2
+	`
+	if err := testutil.CollectAndCompare(coreRequests, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+
+	/* attempts rejected by the breaker have no latency */
+	if got := testutil.CollectAndCount(coreDuration); got != 2 {
+		t.Fatalf("latency series = %d, want 2", got)
+	}
+	for op, want := range map[string]int{"get": 2, "apply": 1} {
+		if got := sampleCount(t, op); got != want {
+			t.Fatalf("%s latency samples = %d, want %d", op, got, want)
+		}
+	}
+}
+
+/* number of latency observations of an aclcore operation */
+func sampleCount(t *testing.T, op string) int {
+	t.Helper()
+
+	families, err := Registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+
+	for _, family := range families {
+		if family.GetName() != "aclapi_aclcore_request_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "op" && label.GetValue() == op {
+					return int(metric.GetHistogram().GetSampleCount())
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestCoreCollector(t *testing.T) {
+	client := aclcore.NewClient(aclcore.Options{SocketPath: "/nonexistent/aclcore.sock", PoolSize: 4})
+	defer client.Close()
+
+	expected := `
+		# HELP aclapi_aclcore_circuit_breaker_state State of the aclcore circuit breaker (0 closed, 1 open, 2 half-open).
+		# TYPE aclapi_aclcore_circuit_breaker_state gauge
+		aclapi_aclcore_circuit_breaker_state 0
+		# HELP aclapi_aclcore_pool_connections Open connections to aclcore, by state (in_use or idle).
+		# TYPE aclapi_aclcore_pool_connections gauge
+		aclapi_aclcore_pool_connections{state="idle"} 0
+		aclapi_aclcore_pool_connections{state="in_use"} 0
+		# HELP aclapi_aclcore_pool_size Maximum number of connections to aclcore.
+		# TYPE aclapi_aclcore_pool_size gauge
+		aclapi_aclcore_pool_size 4
+	`
+	if err := testutil.CollectAndCompare(&coreCollector{client: client}, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	/* a second server (tests, in-process restarts) replaces the collectors of the first */
+	for _, size := range []int{2, 8} {
+		client := aclcore.NewClient(aclcore.Options{SocketPath: "/nonexistent/aclcore.sock", PoolSize: size})
+		defer client.Close()
+
+		if err := RegisterCoreClient(client); err != nil {
+			t.Fatalf("RegisterCoreClient: %v", err)
+		}
+		if err := RegisterPathLocks(pathlock.New(pathlock.Options{})); err != nil {
+			t.Fatalf("RegisterPathLocks: %v", err)
+		}
+	}
+
+	expected := `
+		# HELP aclapi_aclcore_pool_size Maximum number of connections to aclcore.
+		# TYPE aclapi_aclcore_pool_size gauge
+		aclapi_aclcore_pool_size 8
+		# HELP aclapi_pathlock_locks Path locks, by state (held or waiting).
+		# TYPE aclapi_pathlock_locks gauge
+		aclapi_pathlock_locks{state="held"} 0
+		aclapi_pathlock_locks{state="waiting"} 0
+	`
+	err := testutil.GatherAndCompare(Registry, strings.NewReader(expected), "aclapi_aclcore_pool_size", "aclapi_pathlock_locks")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestObserveAudit(t *testing.T) {
+	auditAppends.Reset()
+
+	ObserveAudit(nil)
+	ObserveAudit(nil)
+	ObserveAudit(errors.New("disk full"))
+
+	expected := `
+		# HELP aclapi_audit_appends_total Audit records appended, by result (ok or failed). Failed records are changes missing from the audit log.
+		# TYPE aclapi_audit_appends_total counter
+		aclapi_audit_appends_total{result="failed"} 1
+		aclapi_audit_appends_total{result="ok"} 2
+	`
+	if err := testutil.CollectAndCompare(auditAppends, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
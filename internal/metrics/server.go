@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+)
+
+/* HTTP listener serving the Prometheus text format on /metrics */
+type Server struct {
+	http     *http.Server
+	listener net.Listener
+}
+
+/* binds the metrics listener to address */
+func Listen(address string) (*Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(Registry, promhttp.HandlerOpts{
+		ErrorLog: zap.NewStdLog(zap.L()),
+	}))
+
+	return &Server{
+		http: &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+		listener: listener,
+	}, nil
+}
+
+/* serves scrapes until Shutdown is called */
+func (s *Server) Serve() {
+	zap.L().Info("Metrics endpoint is listening",
+		zap.String("Address", s.listener.Addr().String()),
+	)
+
+	if err := s.http.Serve(s.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		zap.L().Error("Metrics server error",
+			zap.Error(err),
+		)
+	}
+}
+
+/* stops the listener, waiting for running scrapes to finish */
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.http.Shutdown(ctx)
+}
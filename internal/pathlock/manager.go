@@ -0,0 +1,187 @@
+package pathlock
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+)
+
+/* returned by Lock when a conflicting operation held the lock for too long */
+var ErrTimeout = errors.New("timed out waiting for a conflicting operation on the path")
+
+/* lock manager configuration */
+type Options struct {
+	/* longest wait for a lock, on top of the caller's context (0 waits as long as the context) */
+	WaitTimeout time.Duration
+
+	/* called after every attempt to take a lock (optional) */
+	Observer Observer
+}
+
+/*
+receives the outcome of every attempt to take a lock and how long it waited,
+zero when the lock was free
+*/
+type Observer func(subtree bool, waited time.Duration, err error)
+
+/* snapshot of the locks */
+type Stats struct {
+	Held    int
+	Waiting int
+}
+
+/*
+serializes operations on the same path
+
+a lock covers a single path, a subtree lock covers a path and everything below
+it: two locks conflict when they are on the same path or when one of them is
+a subtree lock containing the other. locks are granted in arrival order, so a
+subtree lock is not starved by a stream of operations below it
+*/
+type Manager struct {
+	timeout  time.Duration
+	observer Observer
+
+	mu      sync.Mutex
+	held    []*request
+	waiting []*request
+}
+
+type request struct {
+	path    string
+	subtree bool
+
+	/* closed once granted */
+	granted chan struct{}
+}
+
+/* creates a lock manager */
+func New(opts Options) *Manager {
+	return &Manager{timeout: opts.WaitTimeout, observer: opts.Observer}
+}
+
+/*
+locks path (and everything below it if subtree), waiting for the conflicting
+locks taken before; the returned function releases the lock
+*/
+func (m *Manager) Lock(ctx context.Context, path string, subtree bool) (unlock func(), err error) {
+	r := &request{path: filepath.Clean(path), subtree: subtree, granted: make(chan struct{})}
+
+	m.mu.Lock()
+	if !conflictsAny(r, m.held) && !conflictsAny(r, m.waiting) {
+		m.held = append(m.held, r)
+		m.mu.Unlock()
+
+		m.observe(subtree, 0, nil)
+		return m.unlocker(r), nil
+	}
+	m.waiting = append(m.waiting, r)
+	m.mu.Unlock()
+
+	start := time.Now()
+	var timeout <-chan time.Time
+	if m.timeout > 0 {
+		timer := time.NewTimer(m.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-r.granted:
+	case <-timeout:
+		err = ErrTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		m.mu.Lock()
+		select {
+		case <-r.granted:
+			/* granted while giving up, take it after all */
+			err = nil
+		default:
+			m.waiting = remove(m.waiting, r)
+
+			/* requests queued behind this one may have been waiting for it only */
+			m.grantLocked()
+		}
+		m.mu.Unlock()
+	}
+
+	m.observe(subtree, time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+	return m.unlocker(r), nil
+}
+
+/* current number of held and waiting locks */
+func (m *Manager) Stats() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return Stats{Held: len(m.held), Waiting: len(m.waiting)}
+}
+
+/* function releasing a held lock, calling it more than once is harmless */
+func (m *Manager) unlocker(r *request) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+
+			m.held = remove(m.held, r)
+			m.grantLocked()
+		})
+	}
+}
+
+/* grants, in arrival order, the waiting locks that conflict neither with held nor with earlier waiting ones */
+func (m *Manager) grantLocked() {
+	waiting := make([]*request, 0, len(m.waiting))
+	for _, r := range m.waiting {
+		if conflictsAny(r, m.held) || conflictsAny(r, waiting) {
+			waiting = append(waiting, r)
+			continue
+		}
+		m.held = append(m.held, r)
+		close(r.granted)
+	}
+	m.waiting = waiting
+}
+
+func (m *Manager) observe(subtree bool, waited time.Duration, err error) {
+	if m.observer != nil {
+		m.observer(subtree, waited, err)
+	}
+}
+
+/* reports whether two locks cannot be held at the same time */
+func (r *request) conflicts(other *request) bool {
+	return r.path == other.path ||
+		(r.subtree && aclmodel.PathWithin(other.path, r.path)) ||
+		(other.subtree && aclmodel.PathWithin(r.path, other.path))
+}
+
+func conflictsAny(r *request, others []*request) bool {
+	for _, other := range others {
+		if r.conflicts(other) {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(requests []*request, r *request) []*request {
+	for i, other := range requests {
+		if other == r {
+			return append(requests[:i], requests[i+1:]...)
+		}
+	}
+	return requests
+}
@@ -0,0 +1,160 @@
+package pathlock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+/* takes a lock in the background, the channel receives the unlock function once granted */
+func lockAsync(m *Manager, path string, subtree bool) <-chan func() {
+	ch := make(chan func(), 1)
+	go func() {
+		unlock, err := m.Lock(context.Background(), path, subtree)
+		if err == nil {
+			ch <- unlock
+		}
+	}()
+	return ch
+}
+
+func mustLock(t *testing.T, m *Manager, path string, subtree bool) func() {
+	t.Helper()
+
+	unlock, err := m.Lock(context.Background(), path, subtree)
+	if err != nil {
+		t.Fatalf("Lock(%s, %v): %v", path, subtree, err)
+	}
+	return unlock
+}
+
+/* waits until n locks are queued */
+func waitQueued(t *testing.T, m *Manager, n int) {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		if m.Stats().Waiting == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%d locks waiting, want %d", m.Stats().Waiting, n)
+}
+
+func granted(ch <-chan func()) func() {
+	select {
+	case unlock := <-ch:
+		return unlock
+	case <-time.After(time.Second):
+		return nil
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	tests := []struct {
+		held, other   string
+		heldSubtree   bool
+		otherSubtree  bool
+		wantConflicts bool
+	}{
+		{"/srv/a", "/srv/a", false, false, true},
+		{"/srv/a", "/srv/a/b", false, false, false},
+		{"/srv/a", "/srv/a/b", true, false, true},
+		{"/srv/a/b", "/srv/a", false, true, true},
+		{"/srv/a", "/srv/ab", true, false, false},
+		{"/srv/a", "/srv/b", true, true, false},
+		{"/", "/srv", true, false, true},
+	}
+
+	for _, test := range tests {
+		held := &request{path: test.held, subtree: test.heldSubtree}
+		other := &request{path: test.other, subtree: test.otherSubtree}
+		if got := held.conflicts(other); got != test.wantConflicts {
+			t.Errorf("%+v conflicts with %+v = %v, want %v", held, other, got, test.wantConflicts)
+		}
+	}
+}
+
+func TestLockSerializes(t *testing.T) {
+	m := New(Options{})
+
+	unlock := mustLock(t, m, "/srv/a", false)
+	free := mustLock(t, m, "/srv/b", false)
+	defer free()
+
+	second := lockAsync(m, "/srv/a/", false)
+	waitQueued(t, m, 1)
+
+	unlock()
+	unlock()
+	if granted(second) == nil {
+		t.Fatal("lock not granted once released")
+	}
+	if stats := m.Stats(); stats.Held != 2 || stats.Waiting != 0 {
+		t.Fatalf("stats = %+v, want 2 held", stats)
+	}
+}
+
+func TestSubtreeLocksInOrder(t *testing.T) {
+	m := New(Options{})
+
+	below := mustLock(t, m, "/srv/a/b", false)
+
+	/* the recursive operation waits for the one below it, later ones wait behind it */
+	tree := lockAsync(m, "/srv/a", true)
+	waitQueued(t, m, 1)
+	sibling := lockAsync(m, "/srv/a/c", false)
+	waitQueued(t, m, 2)
+
+	/* unrelated paths do not queue */
+	mustLock(t, m, "/srv/other", true)()
+
+	below()
+	unlockTree := granted(tree)
+	if unlockTree == nil {
+		t.Fatal("subtree lock not granted")
+	}
+	select {
+	case <-sibling:
+		t.Fatal("lock below a held subtree lock granted")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	unlockTree()
+	if granted(sibling) == nil {
+		t.Fatal("lock not granted once the subtree lock was released")
+	}
+}
+
+func TestLockTimeout(t *testing.T) {
+	var observed []error
+	m := New(Options{
+		WaitTimeout: 20 * time.Millisecond,
+		Observer: func(subtree bool, waited time.Duration, err error) {
+			observed = append(observed, err)
+		},
+	})
+
+	unlock := mustLock(t, m, "/srv/a", false)
+	defer unlock()
+
+	if _, err := m.Lock(context.Background(), "/srv", true); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Lock error = %v, want ErrTimeout", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := m.Lock(ctx, "/srv/a", false); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Lock error = %v, want context.Canceled", err)
+	}
+
+	/* given up locks leave the queue */
+	if stats := m.Stats(); stats.Held != 1 || stats.Waiting != 0 {
+		t.Fatalf("stats = %+v, want only the first lock held", stats)
+	}
+
+	if len(observed) != 3 || observed[0] != nil || !errors.Is(observed[1], ErrTimeout) || !errors.Is(observed[2], context.Canceled) {
+		t.Fatalf("observed %v", observed)
+	}
+}
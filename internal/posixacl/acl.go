@@ -0,0 +1,191 @@
+package posixacl
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+)
+
+/* kernel tag values of the structured model tags */
+var tagValues = map[aclmodel.Tag]uint16{
+	aclmodel.TagUserObj:  TagUserObj,
+	aclmodel.TagUser:     TagUser,
+	aclmodel.TagGroupObj: TagGroupObj,
+	aclmodel.TagGroup:    TagGroup,
+	aclmodel.TagMask:     TagMask,
+	aclmodel.TagOther:    TagOther,
+}
+
+/* structured model tags of the kernel tag values */
+var modelTags = map[uint16]aclmodel.Tag{
+	TagUserObj:  aclmodel.TagUserObj,
+	TagUser:     aclmodel.TagUser,
+	TagGroupObj: aclmodel.TagGroupObj,
+	TagGroup:    aclmodel.TagGroup,
+	TagMask:     aclmodel.TagMask,
+	TagOther:    aclmodel.TagOther,
+}
+
+/* decodes and validates an xattr value into structured entries (qualifiers are left empty) */
+func Unmarshal(data []byte, isDefault bool) ([]aclmodel.Entry, error) {
+	raw, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Validate(raw); err != nil {
+		return nil, err
+	}
+
+	return ToEntries(raw, isDefault), nil
+}
+
+/* sorts, validates and encodes structured entries into an xattr value */
+func Marshal(entries []aclmodel.Entry) ([]byte, error) {
+	raw, err := FromEntries(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Validate(raw); err != nil {
+		return nil, err
+	}
+
+	return Encode(raw), nil
+}
+
+/* converts validated raw entries to the structured model */
+func ToEntries(raw []RawEntry, isDefault bool) []aclmodel.Entry {
+	entries := make([]aclmodel.Entry, 0, len(raw))
+	for _, r := range raw {
+		entry := aclmodel.Entry{
+			Tag:     modelTags[r.Tag],
+			Perms:   aclmodel.Perm(r.Perm) & aclmodel.PermRWX,
+			Default: isDefault,
+		}
+		if entry.Tag.Named() {
+			id := r.ID
+			entry.ID = &id
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+/*
+converts structured entries to raw entries in kernel order
+named entries need a resolved ID and X must already be resolved to x or nothing
+*/
+func FromEntries(entries []aclmodel.Entry) ([]RawEntry, error) {
+	raw := make([]RawEntry, 0, len(entries))
+	for _, entry := range entries {
+		tag, ok := tagValues[entry.Tag]
+		if !ok {
+			return nil, &FormatError{Reason: fmt.Sprintf("unknown tag %q", entry.Tag)}
+		}
+
+		if entry.Perms&^aclmodel.PermRWX != 0 {
+			return nil, &FormatError{Reason: fmt.Sprintf("%s entry has unresolved permissions %s", entry.Tag, entry.Perms)}
+		}
+
+		id := uint32(UndefinedID)
+		if entry.Tag.Named() {
+			if entry.ID == nil {
+				return nil, &FormatError{Reason: fmt.Sprintf("%s entry %q has no resolved ID", entry.Tag, entry.Qualifier)}
+			}
+			id = *entry.ID
+		}
+
+		raw = append(raw, RawEntry{Tag: tag, Perm: uint16(entry.Perms), ID: id})
+	}
+
+	Sort(raw)
+	return raw, nil
+}
+
+/* sorts raw entries by tag and then by ID, the order the kernel requires */
+func Sort(raw []RawEntry) {
+	slices.SortStableFunc(raw, func(a, b RawEntry) int {
+		return cmp.Or(cmp.Compare(a.Tag, b.Tag), cmp.Compare(a.ID, b.ID))
+	})
+}
+
+/* union of the group class permissions (owning group and named entries) */
+func MaskPerms(entries []aclmodel.Entry) aclmodel.Perm {
+	var mask aclmodel.Perm
+	for _, entry := range entries {
+		if entry.Tag.Named() || entry.Tag == aclmodel.TagGroupObj {
+			mask |= entry.Perms
+		}
+	}
+	return mask & aclmodel.PermRWX
+}
+
+/*
+recalculates the mask entry like setfacl does: the mask is set to the union of
+the group class permissions if named entries exist, and dropped otherwise
+*/
+func CalcMask(entries []aclmodel.Entry) []aclmodel.Entry {
+	maskIndex := slices.IndexFunc(entries, func(e aclmodel.Entry) bool {
+		return e.Tag == aclmodel.TagMask
+	})
+
+	named := slices.ContainsFunc(entries, func(e aclmodel.Entry) bool {
+		return e.Tag.Named()
+	})
+
+	if !named {
+		if maskIndex >= 0 {
+			return slices.Delete(entries, maskIndex, maskIndex+1)
+		}
+		return entries
+	}
+
+	if maskIndex >= 0 {
+		entries[maskIndex].Perms = MaskPerms(entries)
+		return entries
+	}
+
+	isDefault := len(entries) > 0 && entries[0].Default
+	return append(entries, aclmodel.Entry{Tag: aclmodel.TagMask, Perms: MaskPerms(entries), Default: isDefault})
+}
+
+/* reports whether an access ACL is fully described by the mode bits */
+func IsMinimal(entries []aclmodel.Entry) bool {
+	return !slices.ContainsFunc(entries, func(e aclmodel.Entry) bool {
+		return e.Tag.Named() || e.Tag == aclmodel.TagMask
+	})
+}
+
+/* access ACL equivalent to the mode bits of a file without an ACL */
+func FromMode(mode os.FileMode) []aclmodel.Entry {
+	perm := mode.Perm()
+	return []aclmodel.Entry{
+		{Tag: aclmodel.TagUserObj, Perms: aclmodel.Perm(perm>>6) & aclmodel.PermRWX},
+		{Tag: aclmodel.TagGroupObj, Perms: aclmodel.Perm(perm>>3) & aclmodel.PermRWX},
+		{Tag: aclmodel.TagOther, Perms: aclmodel.Perm(perm) & aclmodel.PermRWX},
+	}
+}
+
+/*
+mode bits of a minimal access ACL
+the file type and the setuid, setgid and sticky bits of mode are kept
+*/
+func ToMode(mode os.FileMode, entries []aclmodel.Entry) os.FileMode {
+	mode &^= os.ModePerm
+	for _, entry := range entries {
+		perms := os.FileMode(entry.Perms & aclmodel.PermRWX)
+		switch entry.Tag {
+		case aclmodel.TagUserObj:
+			mode |= perms << 6
+		case aclmodel.TagGroupObj:
+			mode |= perms << 3
+		case aclmodel.TagOther:
+			mode |= perms
+		}
+	}
+	return mode
+}
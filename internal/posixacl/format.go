@@ -0,0 +1,135 @@
+package posixacl
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+/*
+layout of the kernel ACL xattr value (include/uapi/linux/posix_acl_xattr.h)
+
+	posix_acl_xattr_header { __le32 a_version; }
+	posix_acl_xattr_entry  { __le16 e_tag; __le16 e_perm; __le32 e_id; }
+*/
+const (
+	Version     = 2
+	HeaderSize  = 4
+	EntrySize   = 8
+	UndefinedID = 0xffffffff
+)
+
+/* kernel tag values (include/uapi/linux/posix_acl.h) */
+const (
+	TagUserObj  uint16 = 0x01
+	TagUser     uint16 = 0x02
+	TagGroupObj uint16 = 0x04
+	TagGroup    uint16 = 0x08
+	TagMask     uint16 = 0x10
+	TagOther    uint16 = 0x20
+)
+
+/* permission bits valid in e_perm */
+const PermMask = 0x7
+
+/* entry exactly as stored in the xattr value */
+type RawEntry struct {
+	Tag  uint16
+	Perm uint16
+	ID   uint32
+}
+
+/* malformed or invalid ACL xattr value */
+type FormatError struct {
+	Reason string
+}
+
+func (e *FormatError) Error() string {
+	return "invalid POSIX ACL: " + e.Reason
+}
+
+/* splits an xattr value into its entries without validating them */
+func Decode(data []byte) ([]RawEntry, error) {
+	if len(data) < HeaderSize {
+		return nil, &FormatError{Reason: fmt.Sprintf("value of %d bytes is shorter than the header", len(data))}
+	}
+
+	if version := binary.LittleEndian.Uint32(data); version != Version {
+		return nil, &FormatError{Reason: fmt.Sprintf("unsupported version %d", version)}
+	}
+
+	data = data[HeaderSize:]
+	if len(data)%EntrySize != 0 {
+		return nil, &FormatError{Reason: fmt.Sprintf("%d trailing bytes after the last entry", len(data)%EntrySize)}
+	}
+
+	raw := make([]RawEntry, 0, len(data)/EntrySize)
+	for ; len(data) > 0; data = data[EntrySize:] {
+		raw = append(raw, RawEntry{
+			Tag:  binary.LittleEndian.Uint16(data),
+			Perm: binary.LittleEndian.Uint16(data[2:]),
+			ID:   binary.LittleEndian.Uint32(data[4:]),
+		})
+	}
+
+	return raw, nil
+}
+
+/* builds an xattr value from entries, in the given order */
+func Encode(raw []RawEntry) []byte {
+	data := make([]byte, 0, HeaderSize+len(raw)*EntrySize)
+	data = binary.LittleEndian.AppendUint32(data, Version)
+	for _, entry := range raw {
+		data = binary.LittleEndian.AppendUint16(data, entry.Tag)
+		data = binary.LittleEndian.AppendUint16(data, entry.Perm)
+		data = binary.LittleEndian.AppendUint32(data, entry.ID)
+	}
+	return data
+}
+
+/*
+checks the rules the kernel enforces (posix_acl_valid):
+entries sorted by tag and named entries by ID without duplicates, exactly one
+owner, owning group and other entry, and a mask whenever named entries exist
+*/
+func Validate(raw []RawEntry) error {
+	var counts = map[uint16]int{}
+	for i, entry := range raw {
+		if entry.Perm&^PermMask != 0 {
+			return &FormatError{Reason: fmt.Sprintf("entry %d has invalid permissions %#o", i, entry.Perm)}
+		}
+
+		switch entry.Tag {
+		case TagUser, TagGroup:
+		case TagUserObj, TagGroupObj, TagMask, TagOther:
+			if entry.ID != UndefinedID {
+				return &FormatError{Reason: fmt.Sprintf("entry %d (tag %#x) must not carry an ID", i, entry.Tag)}
+			}
+		default:
+			return &FormatError{Reason: fmt.Sprintf("entry %d has unknown tag %#x", i, entry.Tag)}
+		}
+
+		if i > 0 {
+			prev := raw[i-1]
+			if entry.Tag < prev.Tag {
+				return &FormatError{Reason: fmt.Sprintf("entry %d is out of order", i)}
+			}
+			if entry.Tag == prev.Tag && entry.ID <= prev.ID {
+				return &FormatError{Reason: fmt.Sprintf("entry %d is out of order or duplicated", i)}
+			}
+		}
+
+		counts[entry.Tag]++
+	}
+
+	for _, tag := range []uint16{TagUserObj, TagGroupObj, TagOther} {
+		if counts[tag] != 1 {
+			return &FormatError{Reason: fmt.Sprintf("expected exactly one entry with tag %#x, found %d", tag, counts[tag])}
+		}
+	}
+
+	if counts[TagUser]+counts[TagGroup] > 0 && counts[TagMask] == 0 {
+		return &FormatError{Reason: "named entries require a mask entry"}
+	}
+
+	return nil
+}
@@ -0,0 +1,265 @@
+package posixacl
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+)
+
+func id(v uint32) *uint32 {
+	return &v
+}
+
+/* user::rw- user:1000:r-x group::r-- group:2000:rw- mask::rwx other::--- */
+var sampleEntries = []aclmodel.Entry{
+	{Tag: aclmodel.TagUserObj, Perms: aclmodel.PermRead | aclmodel.PermWrite},
+	{Tag: aclmodel.TagUser, ID: id(1000), Perms: aclmodel.PermRead | aclmodel.PermExecute},
+	{Tag: aclmodel.TagGroupObj, Perms: aclmodel.PermRead},
+	{Tag: aclmodel.TagGroup, ID: id(2000), Perms: aclmodel.PermRead | aclmodel.PermWrite},
+	{Tag: aclmodel.TagMask, Perms: aclmodel.PermRWX},
+	{Tag: aclmodel.TagOther},
+}
+
+func TestMarshalLayout(t *testing.T) {
+	data, err := Marshal(sampleEntries)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	want := []byte{
+		0x02, 0x00, 0x00, 0x00,
+		0x01, 0x00, 0x06, 0x00, 0xff, 0xff, 0xff, 0xff,
+		0x02, 0x00, 0x05, 0x00, 0xe8, 0x03, 0x00, 0x00,
+		0x04, 0x00, 0x04, 0x00, 0xff, 0xff, 0xff, 0xff,
+		0x08, 0x00, 0x06, 0x00, 0xd0, 0x07, 0x00, 0x00,
+		0x10, 0x00, 0x07, 0x00, 0xff, 0xff, 0xff, 0xff,
+		0x20, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff,
+	}
+	if !reflect.DeepEqual(data, want) {
+		t.Fatalf("Marshal = % x, want % x", data, want)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	/* input order must not matter, output is in kernel order */
+	shuffled := []aclmodel.Entry{
+		sampleEntries[5], sampleEntries[3], sampleEntries[0],
+		sampleEntries[4], sampleEntries[1], sampleEntries[2],
+	}
+
+	for _, isDefault := range []bool{false, true} {
+		data, err := Marshal(shuffled)
+		if err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+
+		got, err := Unmarshal(data, isDefault)
+		if err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+
+		want := withDefault(sampleEntries, isDefault)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("round trip (default=%t) = %+v, want %+v", isDefault, got, want)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	valid := Encode([]RawEntry{
+		{Tag: TagUserObj, Perm: 6, ID: UndefinedID},
+		{Tag: TagGroupObj, Perm: 4, ID: UndefinedID},
+		{Tag: TagOther, Perm: 4, ID: UndefinedID},
+	})
+
+	tests := map[string][]byte{
+		"empty":            {},
+		"short header":     {0x02, 0x00},
+		"wrong version":    append([]byte{0x01, 0x00, 0x00, 0x00}, valid[HeaderSize:]...),
+		"truncated entry":  valid[:len(valid)-3],
+		"no entries":       valid[:HeaderSize],
+		"missing other":    valid[:len(valid)-EntrySize],
+		"trailing garbage": append(append([]byte{}, valid...), 0x00),
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Unmarshal(data, false)
+			var formatErr *FormatError
+			if !errors.As(err, &formatErr) {
+				t.Fatalf("Unmarshal error = %v, want *FormatError", err)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	base := func(extra ...RawEntry) []RawEntry {
+		raw := append([]RawEntry{
+			{Tag: TagUserObj, Perm: 7, ID: UndefinedID},
+			{Tag: TagGroupObj, Perm: 5, ID: UndefinedID},
+			{Tag: TagOther, Perm: 0, ID: UndefinedID},
+		}, extra...)
+		Sort(raw)
+		return raw
+	}
+
+	tests := []struct {
+		name  string
+		raw   []RawEntry
+		valid bool
+	}{
+		{"minimal", base(), true},
+		{"mask without named entries", base(RawEntry{Tag: TagMask, Perm: 5, ID: UndefinedID}), true},
+		{"named entries with mask", base(
+			RawEntry{Tag: TagUser, Perm: 7, ID: 10},
+			RawEntry{Tag: TagUser, Perm: 7, ID: 11},
+			RawEntry{Tag: TagMask, Perm: 7, ID: UndefinedID},
+		), true},
+		{"named entry without mask", base(RawEntry{Tag: TagUser, Perm: 7, ID: 10}), false},
+		{"duplicate named entry", base(
+			RawEntry{Tag: TagGroup, Perm: 7, ID: 10},
+			RawEntry{Tag: TagGroup, Perm: 5, ID: 10},
+			RawEntry{Tag: TagMask, Perm: 7, ID: UndefinedID},
+		), false},
+		{"duplicate owner", base(RawEntry{Tag: TagUserObj, Perm: 7, ID: UndefinedID}), false},
+		{"missing owner", base()[1:], false},
+		{"unknown tag", base(RawEntry{Tag: 0x40, Perm: 7, ID: UndefinedID}), false},
+		{"invalid permissions", base(RawEntry{Tag: TagMask, Perm: 8, ID: UndefinedID}), false},
+		{"ID on base entry", base(RawEntry{Tag: TagMask, Perm: 7, ID: 0}), false},
+		{"out of order", []RawEntry{
+			{Tag: TagGroupObj, Perm: 5, ID: UndefinedID},
+			{Tag: TagUserObj, Perm: 7, ID: UndefinedID},
+			{Tag: TagOther, Perm: 0, ID: UndefinedID},
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.raw)
+			if (err == nil) != tt.valid {
+				t.Fatalf("Validate = %v, want valid=%t", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestCalcMask(t *testing.T) {
+	entries := []aclmodel.Entry{
+		{Tag: aclmodel.TagUserObj, Perms: aclmodel.PermRWX},
+		{Tag: aclmodel.TagUser, ID: id(1000), Perms: aclmodel.PermRead},
+		{Tag: aclmodel.TagGroupObj, Perms: aclmodel.PermExecute},
+		{Tag: aclmodel.TagOther},
+	}
+
+	entries = CalcMask(entries)
+	if got := entries[len(entries)-1]; got.Tag != aclmodel.TagMask || got.Perms != aclmodel.PermRead|aclmodel.PermExecute {
+		t.Fatalf("mask = %+v, want r-x", got)
+	}
+
+	/* without named entries the mask goes away */
+	entries = CalcMask(append(entries[:1], entries[2:]...))
+	if !IsMinimal(entries) {
+		t.Fatalf("CalcMask kept a mask without named entries: %+v", entries)
+	}
+}
+
+func TestMode(t *testing.T) {
+	mode := os.ModeDir | os.ModeSetgid | 0o750
+	entries := FromMode(mode)
+	if got := ToMode(mode, entries); got != mode {
+		t.Fatalf("ToMode(FromMode(%v)) = %v", mode, got)
+	}
+}
+
+/* real xattrs on tmpfs, the kernel must accept what we encode and return it unchanged */
+func TestXattrRoundTrip(t *testing.T) {
+	dir := tmpfsDir(t)
+
+	if err := Write(dir, false, sampleEntries); err != nil {
+		if errors.Is(err, unix.EOPNOTSUPP) {
+			t.Skip("tmpfs mounted without POSIX ACL support")
+		}
+		t.Fatalf("Write access ACL: %v", err)
+	}
+
+	defaults := withDefault(sampleEntries, true)
+	if err := Write(dir, true, defaults); err != nil {
+		t.Fatalf("Write default ACL: %v", err)
+	}
+
+	access, err := Read(dir, false)
+	if err != nil {
+		t.Fatalf("Read access ACL: %v", err)
+	}
+	if !reflect.DeepEqual(access, sampleEntries) {
+		t.Fatalf("access ACL = %+v, want %+v", access, sampleEntries)
+	}
+
+	got, err := Read(dir, true)
+	if err != nil {
+		t.Fatalf("Read default ACL: %v", err)
+	}
+	if !reflect.DeepEqual(got, defaults) {
+		t.Fatalf("default ACL = %+v, want %+v", got, defaults)
+	}
+
+	/* the kernel keeps the group mode bits in sync with the mask */
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o670 {
+		t.Fatalf("mode = %#o, want 0670", perm)
+	}
+
+	/* byte for byte, what the kernel returns is what we encode */
+	raw, err := ReadXattr(dir, XattrAccess)
+	if err != nil {
+		t.Fatalf("ReadXattr: %v", err)
+	}
+	want, _ := Marshal(sampleEntries)
+	if !reflect.DeepEqual(raw, want) {
+		t.Fatalf("kernel xattr = % x, want % x", raw, want)
+	}
+
+	/* removing the default ACL leaves nothing behind */
+	if err := Write(dir, true, nil); err != nil {
+		t.Fatalf("Write empty default ACL: %v", err)
+	}
+	if got, err := Read(dir, true); err != nil || got != nil {
+		t.Fatalf("Read removed default ACL = %+v, %v", got, err)
+	}
+}
+
+/* creates a directory on tmpfs (/dev/shm), skipping the test if there is none */
+func tmpfsDir(t *testing.T) string {
+	t.Helper()
+
+	var fs unix.Statfs_t
+	if err := unix.Statfs("/dev/shm", &fs); err != nil || fs.Type != unix.TMPFS_MAGIC {
+		t.Skip("/dev/shm is not a tmpfs")
+	}
+
+	dir, err := os.MkdirTemp("/dev/shm", "posixacl-test-")
+	if err != nil {
+		t.Skipf("cannot create a directory on tmpfs: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return dir
+}
+
+func withDefault(entries []aclmodel.Entry, isDefault bool) []aclmodel.Entry {
+	out := make([]aclmodel.Entry, len(entries))
+	for i, entry := range entries {
+		entry.Default = isDefault
+		out[i] = entry
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package posixacl
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+)
+
+/* extended attributes holding the POSIX ACLs of a file */
+const (
+	XattrAccess  = "system.posix_acl_access"
+	XattrDefault = "system.posix_acl_default"
+)
+
+/* name of the xattr holding the access or default ACL */
+func xattrName(isDefault bool) string {
+	if isDefault {
+		return XattrDefault
+	}
+	return XattrAccess
+}
+
+/*
+reads the access or default ACL of path
+returns nil without an error if the ACL is not set, a missing access ACL
+means the mode bits describe the permissions (see FromMode)
+*/
+func Read(path string, isDefault bool) ([]aclmodel.Entry, error) {
+	name := xattrName(isDefault)
+
+	data, err := ReadXattr(path, name)
+	if errors.Is(err, unix.ENODATA) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := Unmarshal(data, isDefault)
+	if err != nil {
+		return nil, &os.PathError{Op: "decode " + name, Path: path, Err: err}
+	}
+	return entries, nil
+}
+
+/* writes the access or default ACL of path, no entries remove the ACL */
+func Write(path string, isDefault bool, entries []aclmodel.Entry) error {
+	name := xattrName(isDefault)
+
+	if len(entries) == 0 {
+		return RemoveXattr(path, name)
+	}
+
+	data, err := Marshal(entries)
+	if err != nil {
+		return &os.PathError{Op: "encode " + name, Path: path, Err: err}
+	}
+	return WriteXattr(path, name, data)
+}
+
+/* reads a raw extended attribute, retrying if it grew in between */
+func ReadXattr(path, name string) ([]byte, error) {
+	for {
+		size, err := unix.Getxattr(path, name, nil)
+		if err != nil {
+			return nil, &os.PathError{Op: "getxattr", Path: path, Err: err}
+		}
+
+		buf := make([]byte, size)
+		n, err := unix.Getxattr(path, name, buf)
+		if errors.Is(err, unix.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, &os.PathError{Op: "getxattr", Path: path, Err: err}
+		}
+		return buf[:n], nil
+	}
+}
+
+/* writes a raw extended attribute */
+func WriteXattr(path, name string, data []byte) error {
+	if err := unix.Setxattr(path, name, data, 0); err != nil {
+		return &os.PathError{Op: "setxattr", Path: path, Err: err}
+	}
+	return nil
+}
+
+/* removes an extended attribute, a missing attribute is not an error */
+func RemoveXattr(path, name string) error {
+	if err := unix.Removexattr(path, name); err != nil && !errors.Is(err, unix.ENODATA) {
+		return &os.PathError{Op: "removexattr", Path: path, Err: err}
+	}
+	return nil
+}
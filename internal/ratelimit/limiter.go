@@ -0,0 +1,173 @@
+package ratelimit
+
+import (
+	"math"
+	"path"
+	"sync"
+	"time"
+)
+
+/* buckets left untouched for this long are forgotten once full again */
+const sweepInterval = time.Minute
+
+/* buckets held before a sweep runs ahead of the interval */
+const minSweepSize = 10000
+
+/* request budget: a sustained rate and the burst allowed on top of it */
+type Budget struct {
+	/* requests per second */
+	Rate float64
+
+	/* requests accepted at once after a quiet period */
+	Burst int
+}
+
+/*
+token-bucket rate limiter with one bucket per caller, end user and method, and
+one per caller shared by all its end users and methods
+
+end users are named by the callers themselves, a caller inventing a new one
+for every request still runs out of its shared bucket
+
+a bucket starts full with Burst tokens and refills at Rate tokens per second,
+each request takes one token from both buckets
+*/
+type Limiter struct {
+	defaults Budget
+
+	/* budgets by method name or full method name */
+	methods map[string]Budget
+
+	/* budget shared by everything a caller sends, none if the rate is 0 */
+	shared Budget
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+
+	/* number of buckets sweeping early, twice what the last sweep kept (at least minSweepSize) */
+	sweepAt int
+
+	/* clock, replaced in tests */
+	now func() time.Time
+}
+
+/* bucket of a caller, end user and method, the shared bucket has neither end user nor method */
+type bucketKey struct {
+	caller  string
+	endUser string
+	method  string
+}
+
+type bucket struct {
+	budget  Budget
+	tokens  float64
+	updated time.Time
+}
+
+/*
+creates a limiter applying the budget of methods to the methods listed in it,
+by name (ApplyACLEntry) or full name (/acl.ACLService/ApplyACLEntry), and
+defaults to the others, on top of the shared budget of each caller
+*/
+func New(defaults Budget, methods map[string]Budget, shared Budget) *Limiter {
+	return &Limiter{
+		defaults: defaults,
+		methods:  methods,
+		shared:   shared,
+		buckets:  make(map[bucketKey]*bucket),
+		sweepAt:  minSweepSize,
+		now:      time.Now,
+	}
+}
+
+/*
+takes a token from the bucket of caller and end user for the full method name
+and from the shared bucket of caller, returning false and the time until both
+have a token when either is empty (no token is taken then)
+*/
+func (l *Limiter) Allow(caller, endUser, method string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	buckets := []*bucket{l.bucket(bucketKey{caller: caller, endUser: endUser, method: method}, l.budget(method), now)}
+	if l.shared.Rate > 0 {
+		buckets = append(buckets, l.bucket(bucketKey{caller: caller}, l.shared, now))
+	}
+
+	var wait time.Duration
+	for _, b := range buckets {
+		wait = max(wait, b.wait())
+	}
+	if wait > 0 {
+		return false, wait
+	}
+
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return true, 0
+}
+
+/* bucket of key, created full if needed and refilled */
+func (l *Limiter) bucket(key bucketKey, budget Budget, now time.Time) *bucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{budget: budget, tokens: float64(budget.Burst), updated: now}
+		l.buckets[key] = b
+	}
+	b.refill(now)
+	return b
+}
+
+/* budget of a full method name */
+func (l *Limiter) budget(method string) Budget {
+	if budget, ok := l.methods[method]; ok {
+		return budget
+	}
+	if budget, ok := l.methods[path.Base(method)]; ok {
+		return budget
+	}
+	return l.defaults
+}
+
+/*
+forgets the buckets that refilled completely, they would start full anyway
+
+callers naming a new end user in every request create buckets faster than
+the interval forgets them, so the sweep also runs once they grew too many
+*/
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval && len(l.buckets) < l.sweepAt {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.budget.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+	l.sweepAt = max(minSweepSize, 2*len(l.buckets))
+}
+
+/* time until the bucket has a token, 0 if it has one */
+func (b *bucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration(math.Ceil((1 - b.tokens) / b.budget.Rate * float64(time.Second)))
+}
+
+/* adds the tokens earned since the last update */
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(b.budget.Burst), b.tokens+elapsed*b.budget.Rate)
+		b.updated = now
+	}
+}
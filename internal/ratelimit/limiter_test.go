@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+/* limiter with a clock moved by the test */
+func newTestLimiter(defaults Budget, methods map[string]Budget, shared Budget) (*Limiter, *time.Time) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	l := New(defaults, methods, shared)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestAllow(t *testing.T) {
+	const apply = "/acl.ACLService/ApplyACLEntry"
+	const get = "/acl.ACLService/GetACL"
+
+	l, now := newTestLimiter(Budget{Rate: 10, Burst: 10}, map[string]Budget{
+		"ApplyACLEntry": {Rate: 2, Burst: 3},
+	}, Budget{})
+
+	/* the burst goes through, then requests wait for the refill */
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("backend", "", apply); !ok {
+			t.Fatalf("request %d of the burst rejected", i+1)
+		}
+	}
+	ok, wait := l.Allow("backend", "", apply)
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("Allow after the burst = %v, %v, want rejected for 500ms", ok, wait)
+	}
+
+	/* other callers and methods have their own buckets */
+	if ok, _ := l.Allow("backend", "carol", apply); !ok {
+		t.Fatal("another end user was rejected")
+	}
+	if ok, _ := l.Allow("backend", "", get); !ok {
+		t.Fatal("another method was rejected")
+	}
+
+	*now = now.Add(250 * time.Millisecond)
+	if ok, wait := l.Allow("backend", "", apply); ok || wait != 250*time.Millisecond {
+		t.Fatalf("Allow after 250ms = %v, %v, want rejected for 250ms", ok, wait)
+	}
+	*now = now.Add(250 * time.Millisecond)
+	if ok, _ := l.Allow("backend", "", apply); !ok {
+		t.Fatal("request rejected after the refill")
+	}
+}
+
+func TestSweep(t *testing.T) {
+	const method = "/acl.ACLService/GetACL"
+	l, now := newTestLimiter(Budget{Rate: 1, Burst: 2}, nil, Budget{})
+
+	l.Allow("idle", "", method)
+	*now = now.Add(sweepInterval - time.Second)
+	l.Allow("busy", "", method)
+	l.Allow("busy", "", method)
+
+	/* the idle bucket refilled and goes away, the busy one is still used */
+	*now = now.Add(time.Second)
+	l.Allow("new", "", method)
+
+	if _, ok := l.buckets[bucketKey{caller: "idle", method: method}]; ok {
+		t.Fatal("refilled bucket was kept")
+	}
+	if b, ok := l.buckets[bucketKey{caller: "busy", method: method}]; !ok || b.tokens != 1 {
+		t.Fatalf("bucket in use = %+v, want it kept with 1 token", b)
+	}
+}
+
+func TestSweepByCount(t *testing.T) {
+	const method = "/acl.ACLService/GetACL"
+	l, now := newTestLimiter(Budget{Rate: 10, Burst: 10}, nil, Budget{Rate: 1e6, Burst: 1e6})
+
+	/* a caller naming a new end user in every request, well within the interval */
+	for i := 0; i < 3*minSweepSize; i++ {
+		*now = now.Add(time.Millisecond)
+		l.Allow("backend", strconv.Itoa(i), method)
+	}
+
+	/* only the buckets that did not refill yet are left */
+	if len(l.buckets) >= minSweepSize {
+		t.Fatalf("%d buckets held, want fewer than %d", len(l.buckets), minSweepSize)
+	}
+	if _, ok := l.buckets[bucketKey{caller: "backend", endUser: strconv.Itoa(3*minSweepSize - 1), method: method}]; !ok {
+		t.Fatal("bucket in use was forgotten")
+	}
+}
+
+func TestSharedBudget(t *testing.T) {
+	const apply = "/acl.ACLService/ApplyACLEntry"
+	const get = "/acl.ACLService/GetACL"
+
+	l, now := newTestLimiter(Budget{Rate: 10, Burst: 10}, nil, Budget{Rate: 1, Burst: 3})
+
+	/* end users and methods of a caller draw from its shared bucket */
+	for _, request := range []struct{ endUser, method string }{{"carol", apply}, {"dave", apply}, {"erin", get}} {
+		if ok, _ := l.Allow("backend", request.endUser, request.method); !ok {
+			t.Fatalf("request of %s to %s rejected", request.endUser, request.method)
+		}
+	}
+	ok, wait := l.Allow("backend", "frank", apply)
+	if ok || wait != time.Second {
+		t.Fatalf("Allow after the shared burst = %v, %v, want rejected for 1s", ok, wait)
+	}
+
+	/* other callers have their own */
+	if ok, _ := l.Allow("frontend", "carol", apply); !ok {
+		t.Fatal("another caller was rejected")
+	}
+
+	/* rejected requests take no token from the bucket of the end user */
+	*now = now.Add(time.Second)
+	if ok, _ := l.Allow("backend", "frank", apply); !ok {
+		t.Fatal("request rejected after the refill")
+	}
+	if b := l.buckets[bucketKey{caller: "backend", endUser: "frank", method: apply}]; b.tokens != 9 {
+		t.Fatalf("end user bucket has %v tokens, want 9", b.tokens)
+	}
+}
+
+func TestEmptyEndUserBucket(t *testing.T) {
+	const apply = "/acl.ACLService/ApplyACLEntry"
+	l, _ := newTestLimiter(Budget{Rate: 1, Burst: 1}, nil, Budget{Rate: 1, Burst: 5})
+
+	/* an empty bucket of the end user rejects without taking a shared token */
+	l.Allow("backend", "carol", apply)
+	if ok, wait := l.Allow("backend", "carol", apply); ok || wait != time.Second {
+		t.Fatalf("Allow = %v, %v, want rejected for 1s", ok, wait)
+	}
+	if b := l.buckets[bucketKey{caller: "backend"}]; b.tokens != 4 {
+		t.Fatalf("shared bucket has %v tokens, want 4", b.tokens)
+	}
+}
@@ -0,0 +1,121 @@
+package fakecore
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclcore"
+)
+
+/* way a scripted request fails */
+type Failure int
+
+const (
+	/* closes the connection without answering (on hello: aclcore refuses clients) */
+	FailRefuse Failure = iota
+
+	/* never answers, the connection stays open until the client gives up */
+	FailHang
+
+	/* answers with a frame that is not valid JSON */
+	FailMalformed
+
+	/* announces a frame larger than aclcore.MaxFrameSize */
+	FailOversized
+
+	/* answers with a failed response carrying an errno (see FailErrno) */
+	failErrno
+)
+
+/* scripted failure of an operation */
+type rule struct {
+	op      aclcore.Op
+	failure Failure
+	errno   syscall.Errno
+
+	/* remaining number of requests to fail, 0 fails forever */
+	times int
+}
+
+/*
+makes the next times requests for op fail, times 0 fails all of them
+rules are matched in the order they were added
+*/
+func (s *Server) Fail(op aclcore.Op, failure Failure, times int) {
+	s.addRule(&rule{op: op, failure: failure, times: times})
+}
+
+/* makes the next times requests for op report errno, times 0 fails all of them */
+func (s *Server) FailErrno(op aclcore.Op, errno syscall.Errno, times int) {
+	s.addRule(&rule{op: op, failure: failErrno, errno: errno, times: times})
+}
+
+/* removes all scripted failures */
+func (s *Server) ClearFailures() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = nil
+}
+
+func (s *Server) addRule(r *rule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, r)
+}
+
+/* returns (and consumes one use of) the first rule matching op */
+func (s *Server) takeRule(op aclcore.Op) *rule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, r := range s.rules {
+		if r.op != op {
+			continue
+		}
+
+		if r.times > 0 {
+			r.times--
+			if r.times == 0 {
+				s.rules = append(s.rules[:i], s.rules[i+1:]...)
+			}
+		}
+		return r
+	}
+
+	return nil
+}
+
+/* performs a scripted failure, reporting whether the connection stays usable */
+func (s *Server) fail(netConn net.Conn, req *aclcore.Request, r *rule) bool {
+	switch r.failure {
+	case FailHang:
+		/* keep reading so cancel notices are still recorded */
+		s.drain(netConn)
+		return false
+	case FailMalformed:
+		writeRaw(netConn, 9, []byte("{not json"))
+		return true
+	case FailOversized:
+		writeRaw(netConn, aclcore.MaxFrameSize+1, nil)
+		return false
+	case failErrno:
+		err := fmt.Errorf("scripted %s failure: %w", req.Op, r.errno)
+		return aclcore.WriteFrame(netConn, errorResponse(req.ID, err)) == nil
+	}
+
+	/* FailRefuse */
+	return false
+}
+
+/* records the frames of a hanging connection until the client closes it */
+func (s *Server) drain(netConn net.Conn) {
+	for {
+		var req aclcore.Request
+		if err := aclcore.ReadFrame(netConn, &req); err != nil {
+			return
+		}
+
+		s.record(&req)
+	}
+}
@@ -0,0 +1,317 @@
+/*
+in-process stand-in for the aclcore root daemon, for tests only
+
+the fake listens on a unix socket in a temporary directory, speaks the aclcore
+wire protocol, keeps ACLs in memory, records every request it receives and can
+be scripted to fail in the ways a real daemon does
+*/
+package fakecore
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclcore"
+)
+
+/* capabilities announced by default, everything but message signing */
+var DefaultCapabilities = []string{
+	aclcore.CapStructuredEntries,
+	aclcore.CapCancel,
+	aclcore.CapRecursive,
+	aclcore.CapStreaming,
+}
+
+/* request as received by the fake */
+type Recorded struct {
+	ID          uint64
+	Op          aclcore.Op
+	Payload     json.RawMessage
+	Traceparent string
+	RequestID   string
+}
+
+/* decodes the payload of a recorded request into v */
+func (r *Recorded) Decode(v any) error {
+	return json.Unmarshal(r.Payload, v)
+}
+
+/* fake aclcore daemon */
+type Server struct {
+	/* socket the fake listens on */
+	SocketPath string
+
+	listener net.Listener
+	closed   chan struct{}
+	wg       sync.WaitGroup
+
+	mu           sync.Mutex
+	capabilities []string
+	files        map[string]*file
+	rules        []*rule
+	requests     []Recorded
+	conns        map[net.Conn]struct{}
+}
+
+/*
+starts a fake aclcore on a socket in a temporary directory
+the fake is closed when the test finishes
+*/
+func New(tb testing.TB) *Server {
+	tb.Helper()
+
+	socketPath := filepath.Join(tb.TempDir(), "aclcore.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		tb.Fatalf("fakecore: failed to listen on %s: %v", socketPath, err)
+	}
+
+	s := &Server{
+		SocketPath:   socketPath,
+		listener:     listener,
+		closed:       make(chan struct{}),
+		capabilities: slices.Clone(DefaultCapabilities),
+		files:        map[string]*file{},
+		conns:        map[net.Conn]struct{}{},
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	tb.Cleanup(s.Close)
+	return s
+}
+
+/*
+creates an aclcore client connected to the fake
+peer checks are disabled and timeouts kept short, opts may override the rest
+*/
+func (s *Server) Client(tb testing.TB, opts aclcore.Options) *aclcore.Client {
+	tb.Helper()
+
+	opts.SocketPath = s.SocketPath
+	opts.Peer = aclcore.PeerPolicy{Disabled: true}
+	if opts.Timeouts.Connect == 0 {
+		opts.Timeouts.Connect = time.Second
+	}
+	if opts.Timeouts.Default == 0 {
+		opts.Timeouts.Default = 5 * time.Second
+	}
+	if opts.Retry.InitialBackoff == 0 {
+		opts.Retry.InitialBackoff = time.Millisecond
+	}
+
+	client := aclcore.NewClient(opts)
+	tb.Cleanup(client.Close)
+	return client
+}
+
+/* stops listening and closes every open connection */
+func (s *Server) Close() {
+	select {
+	case <-s.closed:
+		return
+	default:
+	}
+
+	close(s.closed)
+	s.listener.Close()
+
+	s.mu.Lock()
+	for netConn := range s.conns {
+		netConn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+/* replaces the capabilities announced to connections opened from now on */
+func (s *Server) SetCapabilities(capabilities ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.capabilities = slices.Clone(capabilities)
+}
+
+/* every request received so far, hello and cancel frames included */
+func (s *Server) Requests() []Recorded {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.requests)
+}
+
+/* requests received so far for a single operation */
+func (s *Server) RequestsFor(op aclcore.Op) []Recorded {
+	var matching []Recorded
+	for _, req := range s.Requests() {
+		if req.Op == op {
+			matching = append(matching, req)
+		}
+	}
+	return matching
+}
+
+/* appends req to the requests seen by the fake */
+func (s *Server) record(req *aclcore.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, Recorded{
+		ID:          req.ID,
+		Op:          req.Op,
+		Payload:     req.Payload,
+		Traceparent: req.Traceparent,
+		RequestID:   req.RequestID,
+	})
+}
+
+/* accepts connections until the fake is closed */
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		netConn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[netConn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(netConn)
+
+			s.mu.Lock()
+			delete(s.conns, netConn)
+			s.mu.Unlock()
+			netConn.Close()
+		}()
+	}
+}
+
+/* answers the requests of a single connection */
+func (s *Server) handle(netConn net.Conn) {
+	for {
+		var req aclcore.Request
+		if err := aclcore.ReadFrame(netConn, &req); err != nil {
+			return
+		}
+
+		s.record(&req)
+
+		/* cancel notices are not answered */
+		if req.Op == aclcore.OpCancel {
+			continue
+		}
+
+		if r := s.takeRule(req.Op); r != nil {
+			if !s.fail(netConn, &req, r) {
+				return
+			}
+			continue
+		}
+
+		for _, resp := range s.dispatch(&req) {
+			if err := aclcore.WriteFrame(netConn, resp); err != nil {
+				return
+			}
+		}
+	}
+}
+
+/* computes the response frames of a request */
+func (s *Server) dispatch(req *aclcore.Request) []*aclcore.Response {
+	var (
+		payload any
+		err     error
+	)
+
+	switch req.Op {
+	case aclcore.OpHello:
+		payload = s.hello()
+	case aclcore.OpApply:
+		var apply aclcore.ApplyRequest
+		if err = json.Unmarshal(req.Payload, &apply); err == nil {
+			var message string
+			message, err = s.apply(&apply)
+			if err == nil {
+				return []*aclcore.Response{{ID: req.ID, Success: true, Message: message}}
+			}
+		}
+	case aclcore.OpGet:
+		var get aclcore.PathRequest
+		if err = json.Unmarshal(req.Payload, &get); err == nil {
+			payload, err = s.get(get.Path)
+		}
+	case aclcore.OpSet:
+		var set aclcore.SetRequest
+		if err = json.Unmarshal(req.Payload, &set); err == nil {
+			err = s.set(set.Path, &set.ACL)
+		}
+	case aclcore.OpStrip:
+		var strip aclcore.PathRequest
+		if err = json.Unmarshal(req.Payload, &strip); err == nil {
+			err = s.strip(strip.Path)
+		}
+	case aclcore.OpWalk:
+		var walk aclcore.PathRequest
+		if err = json.Unmarshal(req.Payload, &walk); err == nil {
+			return s.walk(req.ID, walk.Path)
+		}
+	default:
+		err = syscall.ENOSYS
+	}
+
+	if err != nil {
+		return []*aclcore.Response{errorResponse(req.ID, err)}
+	}
+
+	data, _ := json.Marshal(payload)
+	return []*aclcore.Response{{ID: req.ID, Success: true, Payload: data}}
+}
+
+/* builds the hello reply */
+func (s *Server) hello() *aclcore.HelloReply {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return &aclcore.HelloReply{
+		Server:       "fakecore",
+		Version:      aclcore.MaxProtocolVersion,
+		Capabilities: slices.Clone(s.capabilities),
+	}
+}
+
+/* builds the failed response for err, reporting its errno if it has one */
+func errorResponse(id uint64, err error) *aclcore.Response {
+	resp := &aclcore.Response{ID: id, Message: err.Error()}
+
+	/* like aclcore, the errno is reported on its own and not repeated in the message */
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		resp.Errno = int(errno)
+		resp.Message = strings.TrimSuffix(resp.Message, ": "+errno.Error())
+	}
+
+	return resp
+}
+
+/* writes a raw frame of arbitrary (possibly invalid) content */
+func writeRaw(w io.Writer, size uint32, data []byte) error {
+	frame := binary.BigEndian.AppendUint32(nil, size)
+	_, err := w.Write(append(frame, data...))
+	return err
+}
@@ -0,0 +1,224 @@
+package fakecore
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclcore"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/aclmodel"
+	"github.com/PythonHacker24/linux-acl-management-aclapi/internal/posixacl"
+)
+
+/* file known to the in-memory store */
+type file struct {
+	isDir bool
+	acl   aclmodel.ACL
+}
+
+/* adds a file whose access ACL is derived from mode */
+func (s *Server) AddFile(path string, mode os.FileMode) {
+	s.add(path, false, mode)
+}
+
+/* adds a directory whose access ACL is derived from mode */
+func (s *Server) AddDir(path string, mode os.FileMode) {
+	s.add(path, true, mode)
+}
+
+func (s *Server) add(path string, isDir bool, mode os.FileMode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[path] = &file{isDir: isDir, acl: aclmodel.ACL{Access: posixacl.FromMode(mode)}}
+}
+
+/* current ACL of a stored path (nil if unknown) */
+func (s *Server) ACL(path string) *aclmodel.ACL {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.files[path]
+	if !ok {
+		return nil
+	}
+	return cloneACL(&f.acl)
+}
+
+/* applies an entry change to a path, or to every stored path below it */
+func (s *Server) apply(req *aclcore.ApplyRequest) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.files[req.Path]; !ok {
+		return "", fmt.Errorf("%s: %w", req.Path, syscall.ENOENT)
+	}
+
+	paths := []string{req.Path}
+	if req.Recursive {
+		paths = s.below(req.Path)
+	}
+
+	var count int
+	for _, path := range paths {
+		f := s.files[path]
+		if req.Entry.Default && !f.isDir {
+			if !req.Recursive {
+				return "", fmt.Errorf("%s: %w", path, syscall.ENOTDIR)
+			}
+			continue
+		}
+
+		if err := applyEntry(f, req.Action, req.Entry); err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		count++
+	}
+
+	return fmt.Sprintf("%s %s entry on %d paths", req.Action, req.Entry.Tag, count), nil
+}
+
+/* upserts or removes a single entry like setfacl -m / -x */
+func applyEntry(f *file, action aclmodel.Action, entry aclmodel.Entry) error {
+	if entry.Tag.Named() && entry.ID == nil {
+		return syscall.EINVAL
+	}
+
+	entries := &f.acl.Access
+	if entry.Default {
+		if len(f.acl.Default) == 0 {
+			for _, base := range f.acl.Access {
+				if !base.Tag.Named() && base.Tag != aclmodel.TagMask {
+					base.Default = true
+					f.acl.Default = append(f.acl.Default, base)
+				}
+			}
+		}
+		entries = &f.acl.Default
+	}
+
+	/* X behaves like x on directories, the fake knows no executable files */
+	if entry.Perms&aclmodel.PermConditionalExecute != 0 {
+		entry.Perms &^= aclmodel.PermConditionalExecute
+		if f.isDir {
+			entry.Perms |= aclmodel.PermExecute
+		}
+	}
+	entry.Qualifier = ""
+
+	index := slices.IndexFunc(*entries, func(e aclmodel.Entry) bool {
+		return e.SamePrincipal(&entry)
+	})
+
+	switch action {
+	case aclmodel.ActionAdd, aclmodel.ActionModify:
+		if index >= 0 {
+			(*entries)[index].Perms = entry.Perms
+		} else {
+			*entries = append(*entries, entry)
+		}
+	case aclmodel.ActionRemove:
+		if !entry.Tag.Named() && entry.Tag != aclmodel.TagMask {
+			return syscall.EINVAL
+		}
+		if index >= 0 {
+			*entries = slices.Delete(*entries, index, index+1)
+		}
+	default:
+		return syscall.EINVAL
+	}
+
+	if entry.Tag != aclmodel.TagMask || action == aclmodel.ActionRemove {
+		*entries = posixacl.CalcMask(*entries)
+	}
+	return nil
+}
+
+/* returns the ACL of a stored path */
+func (s *Server) get(path string) (*aclmodel.ACL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.files[path]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", path, syscall.ENOENT)
+	}
+	return cloneACL(&f.acl), nil
+}
+
+/* replaces the ACL of a stored path */
+func (s *Server) set(path string, acl *aclmodel.ACL) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.files[path]
+	if !ok {
+		return fmt.Errorf("%s: %w", path, syscall.ENOENT)
+	}
+	if len(acl.Default) > 0 && !f.isDir {
+		return fmt.Errorf("%s: %w", path, syscall.ENOTDIR)
+	}
+
+	f.acl = *cloneACL(acl)
+	return nil
+}
+
+/* removes the extended entries and the default ACL of a stored path */
+func (s *Server) strip(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.files[path]
+	if !ok {
+		return fmt.Errorf("%s: %w", path, syscall.ENOENT)
+	}
+
+	f.acl.Access = slices.DeleteFunc(f.acl.Access, func(e aclmodel.Entry) bool {
+		return e.Tag.Named() || e.Tag == aclmodel.TagMask
+	})
+	f.acl.Default = nil
+	return nil
+}
+
+/* streams the stored paths below root, one frame each */
+func (s *Server) walk(id uint64, root string) []*aclcore.Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.files[root]; !ok {
+		return []*aclcore.Response{errorResponse(id, fmt.Errorf("%s: %w", root, syscall.ENOENT))}
+	}
+
+	var frames []*aclcore.Response
+	for _, path := range s.below(root) {
+		payload, _ := json.Marshal(&aclcore.WalkEntry{Path: path, IsDir: s.files[path].isDir})
+		frames = append(frames, &aclcore.Response{ID: id, Success: true, Payload: payload, More: true})
+	}
+
+	/* the stream ends with an empty frame */
+	return append(frames, &aclcore.Response{ID: id, Success: true})
+}
+
+/* stored paths at or below root in lexical order, the caller holds s.mu */
+func (s *Server) below(root string) []string {
+	prefix := strings.TrimSuffix(root, "/") + "/"
+
+	var paths []string
+	for path := range s.files {
+		if path == root || strings.HasPrefix(path, prefix) {
+			paths = append(paths, path)
+		}
+	}
+
+	slices.Sort(paths)
+	return paths
+}
+
+func cloneACL(acl *aclmodel.ACL) *aclmodel.ACL {
+	return &aclmodel.ACL{
+		Access:  slices.Clone(acl.Access),
+		Default: slices.Clone(acl.Default),
+	}
+}
@@ -0,0 +1,164 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+/* metadata keys carrying the correlation of a request */
+const (
+	/* W3C trace context header */
+	TraceparentKey = "traceparent"
+
+	/* plain request ID for callers without trace context support */
+	RequestIDKey = "x-request-id"
+)
+
+/* longest request ID accepted from a caller, longer ones are replaced */
+const maxRequestIDLength = 128
+
+/*
+correlation of a single request across the backend, aclapi and aclcore
+trace and span IDs follow W3C trace context, aclapi is a new span of the
+caller's trace
+*/
+type Trace struct {
+	TraceID string
+	SpanID  string
+
+	/* span of the caller, empty if the trace started here */
+	ParentID string
+
+	/* trace flags (sampled bit) as two hex digits */
+	Flags string
+
+	/* request ID sent by the caller, the trace ID otherwise */
+	RequestID string
+}
+
+/*
+builds the trace of an incoming request from the traceparent and request ID
+sent by the caller (either may be empty), starting a new trace when there is
+no valid traceparent
+*/
+func New(traceparent, requestID string) *Trace {
+	t := &Trace{SpanID: randomHex(8), Flags: "01"}
+
+	if traceID, parentID, flags, ok := parseTraceparent(traceparent); ok {
+		t.TraceID, t.ParentID, t.Flags = traceID, parentID, flags
+	} else {
+		t.TraceID = randomHex(16)
+	}
+
+	t.RequestID = t.TraceID
+	if Printable(requestID, maxRequestIDLength) {
+		t.RequestID = requestID
+	}
+
+	return t
+}
+
+/* traceparent to hand to the next hop (aclcore, or the caller in response headers) */
+func (t *Trace) Traceparent() string {
+	return "00-" + t.TraceID + "-" + t.SpanID + "-" + t.Flags
+}
+
+/* log fields identifying the request */
+func (t *Trace) Fields() []zap.Field {
+	return []zap.Field{
+		zap.String("trace_id", t.TraceID),
+		zap.String("span_id", t.SpanID),
+		zap.String("request_id", t.RequestID),
+	}
+}
+
+/* parses a version 00 traceparent header */
+func parseTraceparent(header string) (traceID, parentID, flags string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || parts[0] != "00" {
+		return "", "", "", false
+	}
+
+	traceID, parentID, flags = parts[1], parts[2], parts[3]
+	if !isHex(traceID, 32) || !isHex(parentID, 16) || !isHex(flags, 2) {
+		return "", "", "", false
+	}
+
+	/* all-zero IDs are invalid */
+	if strings.Trim(traceID, "0") == "" || strings.Trim(parentID, "0") == "" {
+		return "", "", "", false
+	}
+
+	return traceID, parentID, flags, true
+}
+
+/* reports whether s is made of n lower-case hex digits */
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+reports whether a value sent by a caller can go into logs, headers and the
+audit trail as is: non-empty printable ASCII without spaces, at most maxLength bytes
+*/
+func Printable(value string, maxLength int) bool {
+	if value == "" || len(value) > maxLength {
+		return false
+	}
+	for _, c := range value {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+type contextKey struct{}
+
+/* request scoped values stored in the context */
+type scope struct {
+	trace  *Trace
+	logger *zap.Logger
+}
+
+/* returns a context carrying t and a logger annotated with it */
+func NewContext(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, contextKey{}, &scope{
+		trace:  t,
+		logger: zap.L().With(t.Fields()...),
+	})
+}
+
+/* trace of the request handled under ctx (nil outside of requests) */
+func FromContext(ctx context.Context) *Trace {
+	if s, ok := ctx.Value(contextKey{}).(*scope); ok {
+		return s.trace
+	}
+	return nil
+}
+
+/* logger annotated with the trace of ctx, the global logger outside of requests */
+func Logger(ctx context.Context) *zap.Logger {
+	if s, ok := ctx.Value(contextKey{}).(*scope); ok {
+		return s.logger
+	}
+	return zap.L()
+}
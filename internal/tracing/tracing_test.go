@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	traceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentID = "00f067aa0ba902b7"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		ok     bool
+	}{
+		{name: "valid", header: "00-" + traceID + "-" + parentID + "-01", ok: true},
+		{name: "not sampled", header: "00-" + traceID + "-" + parentID + "-00", ok: true},
+		{name: "surrounding spaces", header: " 00-" + traceID + "-" + parentID + "-01 ", ok: true},
+		{name: "empty", header: ""},
+		{name: "uppercase trace ID", header: "00-" + strings.ToUpper(traceID) + "-" + parentID + "-01"},
+		{name: "uppercase parent ID", header: "00-" + traceID + "-00F067AA0BA902B7-01"},
+		{name: "uppercase flags", header: "00-" + traceID + "-" + parentID + "-0A"},
+		{name: "all-zero trace ID", header: "00-" + strings.Repeat("0", 32) + "-" + parentID + "-01"},
+		{name: "all-zero parent ID", header: "00-" + traceID + "-" + strings.Repeat("0", 16) + "-01"},
+		{name: "version ff", header: "ff-" + traceID + "-" + parentID + "-01"},
+		{name: "unknown version", header: "01-" + traceID + "-" + parentID + "-01"},
+		{name: "short trace ID", header: "00-" + traceID[1:] + "-" + parentID + "-01"},
+		{name: "long trace ID", header: "00-" + traceID + "0-" + parentID + "-01"},
+		{name: "short parent ID", header: "00-" + traceID + "-" + parentID[1:] + "-01"},
+		{name: "long parent ID", header: "00-" + traceID + "-" + parentID + "0-01"},
+		{name: "short flags", header: "00-" + traceID + "-" + parentID + "-1"},
+		{name: "long version", header: "000-" + traceID + "-" + parentID + "-01"},
+		{name: "extra field", header: "00-" + traceID + "-" + parentID + "-01-extra"},
+		{name: "missing field", header: "00-" + traceID + "-" + parentID},
+		{name: "not hex", header: "00-" + traceID[:31] + "g-" + parentID + "-01"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gotTrace, gotParent, gotFlags, ok := parseTraceparent(test.header)
+			if ok != test.ok {
+				t.Fatalf("parseTraceparent(%q) ok = %t, want %t", test.header, ok, test.ok)
+			}
+			if ok && (gotTrace != traceID || gotParent != parentID || len(gotFlags) != 2) {
+				t.Fatalf("parseTraceparent(%q) = %s, %s, %s", test.header, gotTrace, gotParent, gotFlags)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	/* the caller's trace is continued in a new span */
+	trace := New("00-"+traceID+"-"+parentID+"-01", "req-42")
+	if trace.TraceID != traceID || trace.ParentID != parentID || trace.SpanID == parentID || trace.RequestID != "req-42" {
+		t.Fatalf("New = %+v, want a new span of the caller's trace", trace)
+	}
+	if want := "00-" + traceID + "-" + trace.SpanID + "-01"; trace.Traceparent() != want {
+		t.Fatalf("Traceparent = %s, want %s", trace.Traceparent(), want)
+	}
+
+	/* invalid headers start a new trace identified by its trace ID */
+	trace = New("00-"+strings.ToUpper(traceID)+"-"+parentID+"-01", "req 42")
+	if trace.TraceID == traceID || trace.ParentID != "" || trace.RequestID != trace.TraceID {
+		t.Fatalf("New = %+v, want a new trace", trace)
+	}
+	if _, _, _, ok := parseTraceparent(trace.Traceparent()); !ok {
+		t.Fatalf("generated traceparent %s is invalid", trace.Traceparent())
+	}
+}
+
+func TestPrintable(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{value: "req-42", want: true},
+		{value: "alice@example.org", want: true},
+		{value: strings.Repeat("a", 17), want: true},
+		{value: strings.Repeat("a", 18)},
+		{value: ""},
+		{value: "with space"},
+		{value: "line\nbreak"},
+		{value: "tab\t"},
+		{value: "del\x7f"},
+		{value: "ünïcode"},
+	}
+
+	for _, test := range tests {
+		if got := Printable(test.value, 17); got != test.want {
+			t.Errorf("Printable(%q, 17) = %t, want %t", test.value, got, test.want)
+		}
+	}
+}